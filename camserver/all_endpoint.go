@@ -12,12 +12,13 @@ import (
 )
 
 type camera_info struct {
-	Name    string `json:"name"`
-	File    string `json:"file"`
-	Driver  string `json:"driver"`
-	Card    string `json:"card"`
-	Businfo string `json:"bus_info"`
-	Version uint32 `json:"version"`
+	Name     string `json:"name"`
+	File     string `json:"file"`
+	Driver   string `json:"driver"`
+	Card     string `json:"card"`
+	Businfo  string `json:"bus_info"`
+	Version  uint32 `json:"version"`
+	IOMethod string `json:"io_method"`
 }
 
 func allCamerasHandler(writer http.ResponseWriter, request *http.Request) {
@@ -82,6 +83,7 @@ func readCameraInfo(file params.VideoFile, group *sync.WaitGroup, channel chan<-
 	info.Card = trim(cap.Card())
 	info.Businfo = trim(cap.BusInfo())
 	info.Version = cap.Version()
+	info.IOMethod = device.IOMethod().String()
 
 	fmt.Printf("'%v'\n", info.Driver)
 
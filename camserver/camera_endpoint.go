@@ -3,6 +3,7 @@ package camserver
 import (
 	"camserver/params"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,9 +18,64 @@ type supported_resolution struct {
 	Height uint32 `json:"height"`
 }
 
+type supported_resolution_range struct {
+	MinWidth   uint32 `json:"min_width"`
+	MaxWidth   uint32 `json:"max_width"`
+	StepWidth  uint32 `json:"step_width"`
+	MinHeight  uint32 `json:"min_height"`
+	MaxHeight  uint32 `json:"max_height"`
+	StepHeight uint32 `json:"step_height"`
+}
+
+type supported_format struct {
+	FourCC      string `json:"fourcc"`
+	Description string `json:"description"`
+	Compressed  bool   `json:"compressed"`
+	Emulated    bool   `json:"emulated"`
+}
+
+type video_input struct {
+	Index    uint32 `json:"index"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Current  bool   `json:"current"`
+	NoPower  bool   `json:"no_power"`
+	NoSignal bool   `json:"no_signal"`
+}
+
+type video_standard struct {
+	Name    string `json:"name"`
+	ID      uint64 `json:"id"`
+	Current bool   `json:"current"`
+}
+
+type dv_timing struct {
+	Width      uint32  `json:"width"`
+	Height     uint32  `json:"height"`
+	Interlaced bool    `json:"interlaced"`
+	FrameRate  float64 `json:"frame_rate"`
+	PixelClock uint64  `json:"pixel_clock"`
+	Current    bool    `json:"current"`
+}
+
+type format_resolutions struct {
+	Resolutions      []supported_resolution       `json:"resolutions"`
+	ResolutionRanges []supported_resolution_range `json:"resolution_ranges,omitempty"`
+}
+
+/*
+* Resolutions and ResolutionRanges are the ones of the default pixel format, MJPEG on most
+* cameras. ResolutionsByFormat holds those of every format keyed by its fourcc code.
+ */
 type camera_full_info struct {
-	Info        camera_info            `json:"info"`
-	Resolutions []supported_resolution `json:"resolutions"`
+	Info                camera_info                   `json:"info"`
+	Formats             []supported_format            `json:"formats"`
+	Resolutions         []supported_resolution        `json:"resolutions"`
+	ResolutionRanges    []supported_resolution_range  `json:"resolution_ranges,omitempty"`
+	ResolutionsByFormat map[string]format_resolutions `json:"resolutions_by_format"`
+	Inputs              []video_input                 `json:"inputs,omitempty"`
+	Standards           []video_standard              `json:"standards,omitempty"`
+	DVTimings           []dv_timing                   `json:"dv_timings,omitempty"`
 }
 
 func cameraHandler(writer http.ResponseWriter, request *http.Request) {
@@ -30,23 +86,20 @@ func cameraHandler(writer http.ResponseWriter, request *http.Request) {
 	file, ok := parameters.GetVideoFile(name)
 
 	if !ok {
-		log.Printf("There is no device '%s'", name)
-		writer.Write([]byte(fmt.Sprintf("No device '%s'\n", name)))
+		logAndWriteResponse(fmt.Sprintf("There is no device '%s'", name), nil, http.StatusNotFound, writer)
 		return
 	}
 
 	err, info := readCameraFullInfo(file)
 
 	if err != nil {
-		log.Printf("Cannot get full info for device %v: %v\n", file, err)
-		writer.Write([]byte(fmt.Sprintf("Cannot get full info from device %v", file.Name)))
+		logAndWriteResponse(fmt.Sprintf("Cannot get full info from device %v", file.Name), err, statusOf(err), writer)
 		return
 	}
 	b, err := json.MarshalIndent(info, "", "  ")
 
 	if err != nil {
-		log.Printf("Cannot marshal response: %v", err)
-		writer.Write([]byte("Cannot marshall response"))
+		logAndWriteResponse("Cannot marshal response", err, http.StatusInternalServerError, writer)
 		return
 	}
 
@@ -76,21 +129,197 @@ func readCameraFullInfo(file params.VideoFile) (error, camera_full_info) {
 	fullInfo.Info.Card = trim(cap.Card())
 	fullInfo.Info.Businfo = trim(cap.BusInfo())
 	fullInfo.Info.Version = cap.Version()
+	fullInfo.Info.IOMethod = device.IOMethod().String()
 
-	frames, err := device.FrameSizes().AllDiscrete(v4l2.V4L2_PIX_FMT_MJPEG)
+	formats, err := device.Formats().All(device.BufferType())
 
 	if err != nil {
-		log.Printf("Cannot load frame sizes: %v", err)
+		log.Printf("Cannot load formats: %v", err)
 		return err, fullInfo
 	}
 
-	resolutions := make([]supported_resolution, 0, 10)
+	fullInfo.Formats = make([]supported_format, 0, len(formats))
+	fullInfo.ResolutionsByFormat = make(map[string]format_resolutions)
 
-	for _, frame := range frames {
-		resolutions = append(resolutions, supported_resolution{frame.Width, frame.Height})
+	for _, format := range formats {
+		fullInfo.Formats = append(fullInfo.Formats, supported_format{format.FourCC(), format.Description, format.Compressed(), format.Emulated()})
+
+		resolutions, err := readResolutions(device, format.Format)
+
+		if err != nil {
+			log.Printf("Cannot load frame sizes of format %s: %v", format.FourCC(), err)
+			return err, fullInfo
+		}
+
+		fullInfo.ResolutionsByFormat[format.FourCC()] = resolutions
+	}
+
+	fullInfo.Resolutions = make([]supported_resolution, 0)
+
+	if len(formats) > 0 {
+		defaultFormat, err := webcam.DefaultPixelFormat(device.Formats(), device.BufferType())
+
+		if err != nil {
+			log.Printf("Cannot resolve the default pixel format: %v", err)
+			return err, fullInfo
+		}
+
+		resolutions := fullInfo.ResolutionsByFormat[webcam.FourCC(defaultFormat)]
+		fullInfo.Resolutions = resolutions.Resolutions
+		fullInfo.ResolutionRanges = resolutions.ResolutionRanges
+	}
+
+	inputs, err := readInputs(device)
+
+	if err != nil {
+		log.Printf("Cannot load inputs: %v", err)
+		return err, fullInfo
+	}
+
+	fullInfo.Inputs = inputs
+
+	standards, err := readStandards(device)
+
+	if err != nil {
+		log.Printf("Cannot load standards: %v", err)
+		return err, fullInfo
+	}
+
+	fullInfo.Standards = standards
+
+	timings, err := readDVTimings(device)
+
+	if err != nil {
+		log.Printf("Cannot load DV timings: %v", err)
+		return err, fullInfo
 	}
 
-	fullInfo.Resolutions = resolutions
+	fullInfo.DVTimings = timings
 
 	return nil, fullInfo
 }
+
+func readResolutions(device webcam.VideoDevice, pixelFormat uint32) (format_resolutions, error) {
+
+	frames, err := device.FrameSizes().All(pixelFormat)
+
+	if err != nil {
+		return format_resolutions{}, err
+	}
+
+	result := format_resolutions{Resolutions: make([]supported_resolution, 0, len(frames))}
+
+	for _, frame := range frames {
+		if frame.Type == v4l2.V4L2_FRMSIZE_TYPE_DISCRETE {
+			result.Resolutions = append(result.Resolutions, supported_resolution{frame.MinWidth, frame.MinHeight})
+			continue
+		}
+
+		result.ResolutionRanges = append(result.ResolutionRanges, supported_resolution_range{frame.MinWidth, frame.MaxWidth, frame.StepWidth, frame.MinHeight, frame.MaxHeight, frame.StepHeight})
+	}
+
+	return result, nil
+}
+
+/*
+* DV timings of the current input, inputs without them report none
+ */
+func readDVTimings(device webcam.VideoDevice) ([]dv_timing, error) {
+
+	timings, err := device.DVTimings().All()
+
+	if errors.Is(err, webcam.ErrUnsupported) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := device.DVTimings().Current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dv_timing, 0, len(timings))
+
+	for _, t := range timings {
+		isCurrent := t.Width == current.Width && t.Height == current.Height && t.Interlaced == current.Interlaced && t.PixelClock == current.PixelClock
+		result = append(result, dv_timing{t.Width, t.Height, t.Interlaced, t.FrameRate(), t.PixelClock, isCurrent})
+	}
+
+	return result, nil
+}
+
+/*
+* Standards of the current input, inputs without them report none
+ */
+func readStandards(device webcam.VideoDevice) ([]video_standard, error) {
+
+	standards, err := device.Standards().All()
+
+	if errors.Is(err, webcam.ErrUnsupported) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := device.Standards().Current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]video_standard, 0, len(standards))
+
+	for _, standard := range standards {
+		result = append(result, video_standard{standard.Name, standard.ID, standard.ID&current != 0})
+	}
+
+	return result, nil
+}
+
+/*
+* Devices without inputs report none
+ */
+func readInputs(device webcam.VideoDevice) ([]video_input, error) {
+
+	inputs, err := device.Inputs().All()
+
+	if errors.Is(err, webcam.ErrUnsupported) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := device.Inputs().Current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]video_input, 0, len(inputs))
+
+	for _, input := range inputs {
+		result = append(result, video_input{input.Index, input.Name, inputType(input.Type), input.Index == current.Index, input.HasStatus(v4l2.V4L2_IN_ST_NO_POWER), input.HasStatus(v4l2.V4L2_IN_ST_NO_SIGNAL)})
+	}
+
+	return result, nil
+}
+
+func inputType(inputType uint32) string {
+	switch inputType {
+	case v4l2.V4L2_INPUT_TYPE_TUNER:
+		return "tuner"
+	case v4l2.V4L2_INPUT_TYPE_CAMERA:
+		return "camera"
+	case v4l2.V4L2_INPUT_TYPE_TOUCH:
+		return "touch"
+	}
+	return fmt.Sprintf("%d", inputType)
+}
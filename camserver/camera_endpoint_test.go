@@ -0,0 +1,110 @@
+package camserver
+
+import (
+	"camserver/params"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"v4l2/fake"
+)
+
+func TestCameraHandler(t *testing.T) {
+	serveFake(t, fake.DefaultConfig())
+
+	response := get(cameraHandler, "/camera/")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+	}
+
+	var info camera_full_info
+
+	if err := json.Unmarshal(response.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Formats) != 2 || info.Formats[0].FourCC != "MJPG" || !info.Formats[0].Compressed || info.Formats[1].FourCC != "YUYV" {
+		t.Errorf("Expected compressed MJPG and YUYV, got %+v", info.Formats)
+	}
+
+	for _, test := range []struct {
+		format string
+		sizes  []supported_resolution
+	}{
+		{"MJPG", []supported_resolution{{640, 480}, {1280, 720}, {1920, 1080}}},
+		{"YUYV", []supported_resolution{{640, 480}, {1280, 720}}},
+	} {
+		sizes := info.ResolutionsByFormat[test.format].Resolutions
+
+		if len(sizes) != len(test.sizes) {
+			t.Errorf("%s: expected resolutions %v, got %v", test.format, test.sizes, sizes)
+			continue
+		}
+
+		for i := range sizes {
+			if sizes[i] != test.sizes[i] {
+				t.Errorf("%s: expected resolutions %v, got %v", test.format, test.sizes, sizes)
+				break
+			}
+		}
+	}
+
+	if len(info.Resolutions) != 3 || info.Resolutions[2] != (supported_resolution{1920, 1080}) {
+		t.Errorf("Expected the MJPEG resolutions, got %v", info.Resolutions)
+	}
+
+	if len(info.ResolutionRanges) != 0 || len(info.ResolutionsByFormat["MJPG"].ResolutionRanges) != 0 {
+		t.Errorf("Expected no resolution ranges, got %v", info.ResolutionRanges)
+	}
+
+	if len(info.Inputs) != 1 || !info.Inputs[0].Current || info.Inputs[0].Type != "camera" {
+		t.Errorf("Expected the current camera input, got %+v", info.Inputs)
+	}
+}
+
+func TestCameraHandlerDVTimings(t *testing.T) {
+	serveFake(t, fake.HDMIConfig())
+
+	response := get(cameraHandler, "/camera/")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+	}
+
+	var info camera_full_info
+
+	if err := json.Unmarshal(response.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.DVTimings) != 3 || !info.DVTimings[0].Current || info.DVTimings[0].Width != 1920 || info.DVTimings[1].Current {
+		t.Errorf("Expected 3 timings with the current 1080p one first, got %+v", info.DVTimings)
+	}
+
+	if len(info.Standards) != 0 {
+		t.Errorf("Expected no standards, got %+v", info.Standards)
+	}
+}
+
+func TestCameraHandlerErrors(t *testing.T) {
+	serveFake(t, fake.DefaultConfig())
+
+	for _, test := range []struct {
+		name   string
+		files  []params.VideoFile
+		status int
+	}{
+		{"unplugged", []params.VideoFile{{Name: "", Path: "/dev/video1"}}, http.StatusServiceUnavailable},
+		{"unknown", []params.VideoFile{{Name: "other", Path: "/dev/video0"}}, http.StatusNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			parameters.Files = test.files
+
+			response := get(cameraHandler, "/camera/")
+
+			if response.Code != test.status {
+				t.Errorf("Expected status %d, got %d: %s", test.status, response.Code, response.Body)
+			}
+		})
+	}
+}
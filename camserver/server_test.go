@@ -0,0 +1,59 @@
+package camserver
+
+import (
+	"camserver/params"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"v4l2/fake"
+	"v4l2/ioctl"
+)
+
+/*
+* Serves the config of the fake driver as the only device. The handlers are called without
+* the router, mux.Vars finds no name then and the device is registered under the empty one.
+ */
+func serveFake(t *testing.T, config fake.Config) *fake.Driver {
+	t.Helper()
+
+	driver := fake.NewDriver()
+	driver.AddDevice("/dev/video0", config)
+
+	previous := ioctl.SetBackend(driver)
+	previousParameters := parameters
+	parameters = params.Params{Port: 8989, Files: []params.VideoFile{{Name: "", Path: "/dev/video0"}}}
+
+	t.Cleanup(func() {
+		ioctl.SetBackend(previous)
+		parameters = previousParameters
+	})
+
+	return driver
+}
+
+func get(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestAllCamerasHandler(t *testing.T) {
+	serveFake(t, fake.DefaultConfig())
+
+	response := get(allCamerasHandler, "/camera/")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+	}
+
+	var cameras []camera_info
+
+	if err := json.Unmarshal(response.Body.Bytes(), &cameras); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cameras) != 1 || cameras[0].Driver != "fake" || cameras[0].Card != "Fake Camera" || cameras[0].IOMethod != "mmap" {
+		t.Errorf("Expected the fake camera streaming by MMAP, got %+v", cameras)
+	}
+}
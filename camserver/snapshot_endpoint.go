@@ -1,22 +1,29 @@
 package camserver
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"v4l2"
 	"webcam"
 
 	"github.com/gorilla/mux"
 )
 
 type snapshot struct {
-	Width  uint32 `json:"width"`
-	Height uint32 `json:"height"`
-	Data   string `json:"data"`
+	Width        uint32 `json:"width"`
+	Height       uint32 `json:"height"`
+	PixelFormat  string `json:"pixel_format"`
+	BytesPerLine uint32 `json:"bytes_per_line"`
+	SizeImage    uint32 `json:"size_image"`
+	Data         string `json:"data"`
 }
 
 const (
@@ -32,21 +39,21 @@ func snapshotHandler(writer http.ResponseWriter, request *http.Request) {
 	format, ok := resolveOutputFormat(request)
 
 	if !ok {
-		logAndWriteResponse("Bad value of param 'format'", nil, writer)
+		logAndWriteResponse("Bad value of param 'format'", nil, http.StatusBadRequest, writer)
 		return
 	}
 
 	file, ok := parameters.GetVideoFile(name)
 
 	if !ok {
-		logAndWriteResponse(fmt.Sprintf("There is no device '%s'", name), nil, writer)
+		logAndWriteResponse(fmt.Sprintf("There is no device '%s'", name), nil, http.StatusNotFound, writer)
 		return
 	}
 
 	device, err := webcam.OpenVideoDevice(file.Path)
 
 	if err != nil {
-		logAndWriteResponse(fmt.Sprintf("Cannot read device '%s'\n", name), err, writer)
+		logAndWriteResponse(fmt.Sprintf("Cannot read device '%s'", name), err, statusOf(err), writer)
 		return
 	}
 
@@ -56,28 +63,54 @@ func snapshotHandler(writer http.ResponseWriter, request *http.Request) {
 		}
 	}()
 
-	framesize, err := resolveFrameSize(request, device)
+	if err := resolveInput(request, device); err != nil {
+		logAndWriteResponse("Cannot capture from the input", err, statusOf(err), writer)
+		return
+	}
+
+	pixelFormat, err := resolvePixelFormat(request, device)
 
 	if err != nil {
-		logAndWriteResponse("No frame size resolved", err, writer)
+		logAndWriteResponse("No pixel format resolved", err, statusOf(err), writer)
 		return
 	}
 
-	snap, err := device.TakeSnapshot(&framesize)
+	framesize, err := resolveFrameSize(request, device, pixelFormat)
 
 	if err != nil {
-		logAndWriteResponse("Cannot take snapshot", err, writer)
+		logAndWriteResponse("No frame size resolved", err, statusOf(err), writer)
 		return
 	}
 
-	contentType := resolveContentType(format)
+	quality, err := resolveQuality(request)
+
+	if err != nil {
+		logAndWriteResponse("Bad value of param 'quality'", err, statusOf(err), writer)
+		return
+	}
+
+	snap, err := device.TakeSnapshotConfig(request.Context(), webcam.StreamConfig{FrameSize: framesize, PixelFormat: pixelFormat, JPEGQuality: quality})
+
+	if err != nil {
+		logAndWriteResponse("Cannot take snapshot", err, statusOf(err), writer)
+		return
+	}
+
+	defer snap.Release()
+
+	contentType := resolveContentType(format, snap.Format().PixelFormat)
 	b := formatPayload(snap, format)
 
 	writer.Header().Set("Content-Type", contentType)
+
+	/* clients learn whether the device honoured the requested quality */
+	if quality > 0 {
+		writer.Header().Set("X-JPEG-Quality", strconv.Itoa(snap.JPEGQuality()))
+	}
 	writer.Write(b)
 }
 
-func logAndWriteResponse(m string, err error, writer http.ResponseWriter) {
+func logAndWriteResponse(m string, err error, status int, writer http.ResponseWriter) {
 	var message string
 	if err != nil {
 		message = fmt.Sprintf("%v: %v\n", m, err)
@@ -85,25 +118,64 @@ func logAndWriteResponse(m string, err error, writer http.ResponseWriter) {
 		message = m
 	}
 
-	log.Printf(message)
+	log.Print(message)
+
+	if errors.Is(err, webcam.ErrBusy) {
+		writer.Header().Set("Retry-After", "1")
+	}
+
+	writer.WriteHeader(status)
 	writer.Write([]byte(message))
 }
 
+/*
+* Maps device errors to HTTP status codes. A busy device is worth retrying, a missing
+* or disconnected one is unavailable until it is plugged in again.
+ */
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, webcam.ErrBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, webcam.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, webcam.ErrDisconnected), errors.Is(err, webcam.ErrNoSignal):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, webcam.ErrInvalidFormat), errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, webcam.ErrUnsupported):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+/* error caused by parameters of the request */
+var errBadRequest = errors.New("bad request")
+
 //-------------------------------------------------------------------------------
 //RESOLVING FRAME SIZE
 //-------------------------------------------------------------------------------
 
 type eval func(frameSize webcam.DiscreteFrameSize) uint32
 
+func distance(a uint32, b uint32) uint32 {
+	return uint32(math.Abs(float64(a) - float64(b)))
+}
+
 func findNearestFrameSizeByWidth(frameSizes []webcam.DiscreteFrameSize, width uint32) webcam.DiscreteFrameSize {
 	return findFrameSize(frameSizes, func(frameSize webcam.DiscreteFrameSize) uint32 {
-		return uint32(math.Abs(float64(frameSize.Width - width)))
+		return distance(frameSize.Width, width)
 	})
 }
 
 func findNearestFrameSizeByHeight(frameSizes []webcam.DiscreteFrameSize, height uint32) webcam.DiscreteFrameSize {
 	return findFrameSize(frameSizes, func(frameSize webcam.DiscreteFrameSize) uint32 {
-		return uint32(math.Abs(float64(frameSize.Height - height)))
+		return distance(frameSize.Height, height)
+	})
+}
+
+func findNearestFrameSize(frameSizes []webcam.DiscreteFrameSize, width uint32, height uint32) webcam.DiscreteFrameSize {
+	return findFrameSize(frameSizes, func(frameSize webcam.DiscreteFrameSize) uint32 {
+		return distance(frameSize.Width, width) + distance(frameSize.Height, height)
 	})
 }
 
@@ -122,7 +194,66 @@ func findFrameSize(sizes []webcam.DiscreteFrameSize, evaluation eval) webcam.Dis
 	return sizes[minIndex]
 }
 
-func resolveFrameSize(request *http.Request, device webcam.VideoDevice) (webcam.DiscreteFrameSize, error) {
+/*
+* Picks the closest legal frame size of every range. A missing dimension is derived
+* from the aspect ratio of the largest size of the range.
+ */
+func candidateFrameSizes(ranges []webcam.FrameSizeRange, width uint32, height uint32) []webcam.DiscreteFrameSize {
+	result := make([]webcam.DiscreteFrameSize, 0, len(ranges))
+
+	for _, r := range ranges {
+		w, h := width, height
+
+		if w == 0 && r.MaxHeight > 0 {
+			w = uint32(uint64(h) * uint64(r.MaxWidth) / uint64(r.MaxHeight))
+		}
+
+		if h == 0 && r.MaxWidth > 0 {
+			h = uint32(uint64(w) * uint64(r.MaxHeight) / uint64(r.MaxWidth))
+		}
+
+		result = append(result, r.Nearest(w, h))
+	}
+
+	return result
+}
+
+/*
+* Digital inputs are captured in the size of their DV timings, analog inputs in the geometry
+* of the standard of their signal, other devices in DEFAULT_WIDTH x DEFAULT_HEIGHT
+ */
+func defaultFrameSize(device webcam.VideoDevice) webcam.DiscreteFrameSize {
+
+	timings := device.DVTimings()
+	timing, err := timings.AutoDetect()
+
+	if err != nil && !errors.Is(err, webcam.ErrUnsupported) {
+		log.Printf("Cannot detect the DV timings, keeping the current ones: %v", err)
+		timing, err = timings.Current()
+	}
+
+	if err == nil && timing.Width > 0 {
+		return timing.FrameSize()
+	}
+
+	standards := device.Standards()
+	id, err := standards.AutoDetect()
+
+	if err != nil && !errors.Is(err, webcam.ErrUnsupported) {
+		log.Printf("Cannot detect the standard, keeping the current one: %v", err)
+		id, err = standards.Current()
+	}
+
+	if err == nil {
+		if size, ok := webcam.StandardFrameSize(id); ok {
+			return size
+		}
+	}
+
+	return webcam.DiscreteFrameSize{Width: DEFAULT_WIDTH, Height: DEFAULT_HEIGHT}
+}
+
+func resolveFrameSize(request *http.Request, device webcam.VideoDevice, pixelFormat uint32) (webcam.DiscreteFrameSize, error) {
 	queries := request.URL.Query()
 
 	widthStr, wok := queries["width"]
@@ -131,42 +262,168 @@ func resolveFrameSize(request *http.Request, device webcam.VideoDevice) (webcam.
 	result := webcam.DiscreteFrameSize{}
 
 	if !wok && !hok {
-		log.Println(fmt.Sprintf("No resolution setup. Setting default %dx%d", DEFAULT_WIDTH, DEFAULT_HEIGHT))
-		result.Width = DEFAULT_WIDTH
-		result.Height = DEFAULT_HEIGHT
+		result = defaultFrameSize(device)
+		log.Println(fmt.Sprintf("No resolution setup. Setting default %dx%d", result.Width, result.Height))
 		return result, nil
 	}
 
-	sizes, err := device.FrameSizes().AllDiscreteMJPEG()
+	ranges, err := device.FrameSizes().All(pixelFormat)
 	if err != nil {
 		return result, err
 	}
 
+	if len(ranges) == 0 {
+		return result, errors.New("Device reports no frame sizes")
+	}
+
+	var width, height int
+
+	if wok {
+		if width, err = strconv.Atoi(widthStr[0]); err != nil {
+			return result, fmt.Errorf("%v: %w", err, errBadRequest)
+		}
+	}
+
+	if hok {
+		if height, err = strconv.Atoi(heightStr[0]); err != nil {
+			return result, fmt.Errorf("%v: %w", err, errBadRequest)
+		}
+	}
+
+	if width < 0 || height < 0 {
+		return result, fmt.Errorf("Frame size must not be negative: %w", errBadRequest)
+	}
+
+	sizes := candidateFrameSizes(ranges, uint32(width), uint32(height))
+
 	if !wok {
 		log.Println("Width missing, looking for appropriate one")
-		height, err := strconv.Atoi(heightStr[0])
+		return findNearestFrameSizeByHeight(sizes, uint32(height)), nil
+	}
 
-		if err != nil {
-			return result, err
+	if !hok {
+		log.Println("Height missing, looking for appropriate one")
+		return findNearestFrameSizeByWidth(sizes, uint32(width)), nil
+	}
+
+	for _, r := range ranges {
+		if r.Contains(uint32(width), uint32(height)) {
+			return webcam.DiscreteFrameSize{Width: uint32(width), Height: uint32(height)}, nil
 		}
+	}
+
+	log.Printf("Frame size %dx%d is not supported, looking for the nearest one", width, height)
+	return findNearestFrameSize(sizes, uint32(width), uint32(height)), nil
+}
+
+//----------------------------------------------------------------------------
+//RESOLVING QUALITY
+//----------------------------------------------------------------------------
 
-		framesize := findNearestFrameSizeByHeight(sizes, uint32(height))
-		return framesize, nil
+/*
+* JPEG quality from 1 to 100 of the 'quality' param, zero keeps the setting of the device
+ */
+func resolveQuality(request *http.Request) (int, error) {
+
+	values, ok := request.URL.Query()["quality"]
+
+	if !ok {
+		return 0, nil
 	}
 
-	if !hok || hok && wok {
-		log.Println("Width missing, looking for appropriate one")
-		width, err := strconv.Atoi(widthStr[0])
+	quality, err := strconv.Atoi(values[0])
+
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, errBadRequest)
+	}
+
+	if quality < 1 || quality > 100 {
+		return 0, fmt.Errorf("Quality %d is out of range 1 to 100: %w", quality, errBadRequest)
+	}
+
+	return quality, nil
+}
+
+//----------------------------------------------------------------------------
+//RESOLVING INPUT
+//----------------------------------------------------------------------------
+
+/*
+* Selects the input of param 'input' and checks that the input to capture from receives
+* a signal. Devices without inputs are captured from as they are.
+ */
+func resolveInput(request *http.Request, device webcam.VideoDevice) error {
+
+	queries := request.URL.Query()
+
+	if values, ok := queries["input"]; ok {
+		index, err := strconv.ParseUint(values[0], 10, 32)
+
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, errBadRequest)
+		}
+
+		inputs, err := device.Inputs().All()
 
 		if err != nil {
-			return result, err
+			return err
+		}
+
+		if index >= uint64(len(inputs)) {
+			return fmt.Errorf("There is no input %d: %w", index, errBadRequest)
 		}
 
-		framesize := findNearestFrameSizeByWidth(sizes, uint32(width))
-		return framesize, nil
+		log.Printf("Selecting input %v", inputs[index])
+
+		if err := device.Inputs().Select(uint32(index)); err != nil {
+			return err
+		}
+	}
+
+	input, err := device.Inputs().Current()
+
+	if errors.Is(err, webcam.ErrUnsupported) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return input.Signal()
+}
+
+//----------------------------------------------------------------------------
+//RESOLVING PIXEL FORMAT
+//----------------------------------------------------------------------------
+
+func resolvePixelFormat(request *http.Request, device webcam.VideoDevice) (uint32, error) {
+
+	queries := request.URL.Query()
+
+	codes, ok := queries["pixelformat"]
+
+	if !ok {
+		return webcam.DefaultPixelFormat(device.Formats(), device.BufferType())
+	}
+
+	pixelFormat, err := webcam.ParseFourCC(codes[0])
+
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, errBadRequest)
 	}
 
-	panic("Unreachable")
+	supported, err := device.Formats().Supports(device.BufferType(), pixelFormat)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !supported {
+		return 0, fmt.Errorf("Pixel format '%s' is not supported by the device: %w", codes[0], webcam.ErrInvalidFormat)
+	}
+
+	return pixelFormat, nil
 }
 
 //----------------------------------------------------------------------------
@@ -199,16 +456,21 @@ func resolveOutputFormat(request *http.Request) (string, bool) {
 
 func formatPayload(snap webcam.Snapshot, format string) []byte {
 	if format == "json" {
+		format := snap.Format()
+
 		payload := snapshot{}
-		payload.Width = 640
-		payload.Height = 480
+		payload.Width = format.Width
+		payload.Height = format.Height
+		payload.PixelFormat = webcam.FourCC(format.PixelFormat)
+		payload.BytesPerLine = format.BytesPerLine
+		payload.SizeImage = format.SizeImage
 		payload.Data = base64.StdEncoding.EncodeToString(snap.Data())
 
 		b, err := json.MarshalIndent(payload, "", "  ")
 
 		if err != nil {
 			message := "Cannot marshall response to json"
-			log.Printf(message)
+			log.Print(message)
 			panic(message)
 		}
 
@@ -222,14 +484,17 @@ func formatPayload(snap webcam.Snapshot, format string) []byte {
 	panic("No format")
 }
 
-func resolveContentType(format string) string {
+func resolveContentType(format string, pixelFormat uint32) string {
 
 	switch format {
 	case "json":
 		return "application/json"
 
 	case "raw":
-		return "image/jpeg"
+		if pixelFormat == v4l2.V4L2_PIX_FMT_MJPEG || pixelFormat == v4l2.V4L2_PIX_FMT_JPEG {
+			return "image/jpeg"
+		}
+		return "application/octet-stream"
 
 	default:
 		panic("no format")
@@ -0,0 +1,122 @@
+package camserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"v4l2/fake"
+)
+
+func TestSnapshotHandler(t *testing.T) {
+	serveFake(t, fake.DefaultConfig())
+
+	for _, test := range []struct {
+		query   string
+		width   uint32
+		height  uint32
+		format  string
+		quality string
+	}{
+		{"", 640, 480, "MJPG", ""},
+		{"?width=1280&height=720", 1280, 720, "MJPG", ""},
+		{"?width=1300", 1280, 720, "MJPG", ""},
+		{"?pixelformat=YUYV&width=1280", 1280, 720, "YUYV", ""},
+		{"?quality=100", 640, 480, "MJPG", "95"},
+		{"?quality=42", 640, 480, "MJPG", "40"},
+	} {
+		t.Run(test.query, func(t *testing.T) {
+			response := get(snapshotHandler, "/camera/snapshot"+test.query)
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+			}
+
+			if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected JSON, got %s", contentType)
+			}
+
+			if quality := response.Header().Get("X-JPEG-Quality"); quality != test.quality {
+				t.Errorf("Expected JPEG quality '%s', got '%s'", test.quality, quality)
+			}
+
+			var snap snapshot
+
+			if err := json.Unmarshal(response.Body.Bytes(), &snap); err != nil {
+				t.Fatal(err)
+			}
+
+			if snap.Width != test.width || snap.Height != test.height || snap.PixelFormat != test.format || snap.Data == "" {
+				t.Errorf("Expected %s %dx%d, got %s %dx%d with %d bytes of data", test.format, test.width, test.height, snap.PixelFormat, snap.Width, snap.Height, len(snap.Data))
+			}
+		})
+	}
+}
+
+func TestSnapshotHandlerRaw(t *testing.T) {
+	serveFake(t, fake.DefaultConfig())
+
+	response := get(snapshotHandler, "/camera/snapshot?format=raw")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+	}
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "image/jpeg" {
+		t.Errorf("Expected a JPEG image, got %s", contentType)
+	}
+
+	if body := response.Body.Bytes(); !bytes.HasPrefix(body, []byte{0xff, 0xd8}) || !bytes.HasSuffix(body, []byte{0xff, 0xd9}) {
+		t.Errorf("Expected a JPEG frame, got %d bytes", len(body))
+	}
+}
+
+/*
+* Devices without MJPEG are captured in the first format they offer
+ */
+func TestSnapshotHandlerDefaultFormat(t *testing.T) {
+	config := fake.DefaultConfig()
+	config.Formats = config.Formats[1:]
+	serveFake(t, config)
+
+	response := get(snapshotHandler, "/camera/snapshot?format=raw")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", response.Code, response.Body)
+	}
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/octet-stream" {
+		t.Errorf("Expected raw YUYV, got %s", contentType)
+	}
+
+	if response.Body.Len() != 640*480*2 {
+		t.Errorf("Expected %d bytes of YUYV, got %d", 640*480*2, response.Body.Len())
+	}
+}
+
+func TestSnapshotHandlerErrors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config fake.Config
+		query  string
+		status int
+	}{
+		{"output format", fake.DefaultConfig(), "?format=png", http.StatusBadRequest},
+		{"pixel format", fake.DefaultConfig(), "?pixelformat=NV12", http.StatusBadRequest},
+		{"width", fake.DefaultConfig(), "?width=wide", http.StatusBadRequest},
+		{"quality", fake.DefaultConfig(), "?quality=101", http.StatusBadRequest},
+		{"input", fake.DefaultConfig(), "?input=1", http.StatusBadRequest},
+		{"no signal", fake.CaptureCardConfig(), "?input=1", http.StatusServiceUnavailable},
+		{"quality of a raw format", fake.MultiPlanarConfig(), "?quality=50", http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			serveFake(t, test.config)
+
+			response := get(snapshotHandler, "/camera/snapshot"+test.query)
+
+			if response.Code != test.status {
+				t.Errorf("Expected status %d, got %d: %s", test.status, response.Code, response.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package v4l2
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+/*
+ * Structs passed to the kernel, expectedSizes holds the sizes they must have
+ */
+var structSizes = []struct {
+	name string
+	size uintptr
+}{
+	{"V4l2Capability", unsafe.Sizeof(V4l2Capability{})},
+	{"V4l2Fmtdesc", unsafe.Sizeof(V4l2Fmtdesc{})},
+	{"V4l2Frmsizeenum", unsafe.Sizeof(V4l2Frmsizeenum{})},
+	{"V4l2Frmivalenum", unsafe.Sizeof(V4l2Frmivalenum{})},
+	{"V4l2Format", unsafe.Sizeof(V4l2Format{})},
+	{"V4l2PixFormat", unsafe.Sizeof(V4l2PixFormat{})},
+	{"V4l2PlanePixFormat", unsafe.Sizeof(V4l2PlanePixFormat{})},
+	{"V4l2PixFormatMplane", unsafe.Sizeof(V4l2PixFormatMplane{})},
+	{"V4l2RequestBuffers", unsafe.Sizeof(V4l2RequestBuffers{})},
+	{"V4l2Buffer", unsafe.Sizeof(V4l2Buffer{})},
+	{"V4l2Plane", unsafe.Sizeof(V4l2Plane{})},
+	{"V4l2ExportBuffer", unsafe.Sizeof(V4l2ExportBuffer{})},
+	{"V4l2Streamparm", unsafe.Sizeof(V4l2Streamparm{})},
+	{"V4l2Jpegcompression", unsafe.Sizeof(V4l2Jpegcompression{})},
+	{"V4l2Cropcap", unsafe.Sizeof(V4l2Cropcap{})},
+	{"V4l2Crop", unsafe.Sizeof(V4l2Crop{})},
+	{"V4l2Selection", unsafe.Sizeof(V4l2Selection{})},
+	{"V4l2Standard", unsafe.Sizeof(V4l2Standard{})},
+	{"V4l2BtTimings", unsafe.Sizeof(V4l2BtTimings{})},
+	{"V4l2DvTimings", unsafe.Sizeof(V4l2DvTimings{})},
+	{"V4l2EnumDvTimings", unsafe.Sizeof(V4l2EnumDvTimings{})},
+	{"V4l2Input", unsafe.Sizeof(V4l2Input{})},
+	{"V4l2Event", unsafe.Sizeof(V4l2Event{})},
+	{"V4l2EventSubscription", unsafe.Sizeof(V4l2EventSubscription{})},
+	{"V4l2Control", unsafe.Sizeof(V4l2Control{})},
+	{"V4l2ExtControl", unsafe.Sizeof(V4l2ExtControl{})},
+	{"V4l2ExtControls", unsafe.Sizeof(V4l2ExtControls{})},
+	{"V4l2Queryctrl", unsafe.Sizeof(V4l2Queryctrl{})},
+	{"V4l2Querymenu", unsafe.Sizeof(V4l2Querymenu{})},
+}
+
+func TestStructSizes(t *testing.T) {
+	sizes, ok := expectedSizes[runtime.GOARCH]
+
+	if !ok {
+		t.Skipf("No struct sizes known on %s", runtime.GOARCH)
+	}
+
+	if len(structSizes) != len(sizes) {
+		t.Errorf("%d structs checked but %d sizes expected on %s", len(structSizes), len(sizes), runtime.GOARCH)
+	}
+
+	for _, s := range structSizes {
+		expected, ok := sizes[s.name]
+
+		if !ok {
+			t.Errorf("No expected size of %s on %s", s.name, runtime.GOARCH)
+			continue
+		}
+
+		if s.size != expected {
+			t.Errorf("Size of %s on %s: expected %d, got %d", s.name, runtime.GOARCH, expected, s.size)
+		}
+	}
+}
+
+/*
+ * Struct sizes of the kernel ABI by GOARCH
+ */
+var expectedSizes = map[string]map[string]uintptr{
+	"386": {
+		"V4l2Capability":        104,
+		"V4l2Fmtdesc":           64,
+		"V4l2Frmsizeenum":       44,
+		"V4l2Frmivalenum":       52,
+		"V4l2Format":            204,
+		"V4l2PixFormat":         48,
+		"V4l2PlanePixFormat":    20,
+		"V4l2PixFormatMplane":   192,
+		"V4l2RequestBuffers":    20,
+		"V4l2Buffer":            68,
+		"V4l2Plane":             60,
+		"V4l2ExportBuffer":      64,
+		"V4l2Streamparm":        204,
+		"V4l2Jpegcompression":   140,
+		"V4l2Cropcap":           44,
+		"V4l2Crop":              20,
+		"V4l2Selection":         64,
+		"V4l2Standard":          64,
+		"V4l2BtTimings":         124,
+		"V4l2DvTimings":         132,
+		"V4l2EnumDvTimings":     148,
+		"V4l2Input":             76,
+		"V4l2Event":             120,
+		"V4l2EventSubscription": 32,
+		"V4l2Control":           8,
+		"V4l2ExtControl":        20,
+		"V4l2ExtControls":       24,
+		"V4l2Queryctrl":         68,
+		"V4l2Querymenu":         44,
+	},
+	"amd64": {
+		"V4l2Capability":        104,
+		"V4l2Fmtdesc":           64,
+		"V4l2Frmsizeenum":       44,
+		"V4l2Frmivalenum":       52,
+		"V4l2Format":            208,
+		"V4l2PixFormat":         48,
+		"V4l2PlanePixFormat":    20,
+		"V4l2PixFormatMplane":   192,
+		"V4l2RequestBuffers":    20,
+		"V4l2Buffer":            88,
+		"V4l2Plane":             64,
+		"V4l2ExportBuffer":      64,
+		"V4l2Streamparm":        204,
+		"V4l2Jpegcompression":   140,
+		"V4l2Cropcap":           44,
+		"V4l2Crop":              20,
+		"V4l2Selection":         64,
+		"V4l2Standard":          72,
+		"V4l2BtTimings":         124,
+		"V4l2DvTimings":         132,
+		"V4l2EnumDvTimings":     148,
+		"V4l2Input":             80,
+		"V4l2Event":             136,
+		"V4l2EventSubscription": 32,
+		"V4l2Control":           8,
+		"V4l2ExtControl":        20,
+		"V4l2ExtControls":       32,
+		"V4l2Queryctrl":         68,
+		"V4l2Querymenu":         44,
+	},
+	"arm": {
+		"V4l2Capability":        104,
+		"V4l2Fmtdesc":           64,
+		"V4l2Frmsizeenum":       44,
+		"V4l2Frmivalenum":       52,
+		"V4l2Format":            204,
+		"V4l2PixFormat":         48,
+		"V4l2PlanePixFormat":    20,
+		"V4l2PixFormatMplane":   192,
+		"V4l2RequestBuffers":    20,
+		"V4l2Buffer":            68,
+		"V4l2Plane":             60,
+		"V4l2ExportBuffer":      64,
+		"V4l2Streamparm":        204,
+		"V4l2Jpegcompression":   140,
+		"V4l2Cropcap":           44,
+		"V4l2Crop":              20,
+		"V4l2Selection":         64,
+		"V4l2Standard":          72,
+		"V4l2BtTimings":         124,
+		"V4l2DvTimings":         132,
+		"V4l2EnumDvTimings":     148,
+		"V4l2Input":             80,
+		"V4l2Event":             128,
+		"V4l2EventSubscription": 32,
+		"V4l2Control":           8,
+		"V4l2ExtControl":        20,
+		"V4l2ExtControls":       24,
+		"V4l2Queryctrl":         68,
+		"V4l2Querymenu":         44,
+	},
+	"arm64": {
+		"V4l2Capability":        104,
+		"V4l2Fmtdesc":           64,
+		"V4l2Frmsizeenum":       44,
+		"V4l2Frmivalenum":       52,
+		"V4l2Format":            208,
+		"V4l2PixFormat":         48,
+		"V4l2PlanePixFormat":    20,
+		"V4l2PixFormatMplane":   192,
+		"V4l2RequestBuffers":    20,
+		"V4l2Buffer":            88,
+		"V4l2Plane":             64,
+		"V4l2ExportBuffer":      64,
+		"V4l2Streamparm":        204,
+		"V4l2Jpegcompression":   140,
+		"V4l2Cropcap":           44,
+		"V4l2Crop":              20,
+		"V4l2Selection":         64,
+		"V4l2Standard":          72,
+		"V4l2BtTimings":         124,
+		"V4l2DvTimings":         132,
+		"V4l2EnumDvTimings":     148,
+		"V4l2Input":             80,
+		"V4l2Event":             136,
+		"V4l2EventSubscription": 32,
+		"V4l2Control":           8,
+		"V4l2ExtControl":        20,
+		"V4l2ExtControls":       32,
+		"V4l2Queryctrl":         68,
+		"V4l2Querymenu":         44,
+	},
+}
@@ -0,0 +1,7 @@
+package v4l2
+
+/*
+ * The ARM EABI aligns 64-bit members to 8 bytes while Go aligns them to 4 bytes
+ * on arm, structs holding them insert the padding in front of them.
+ */
+type pad64 = [1]uint32
@@ -0,0 +1,8 @@
+//go:build !arm
+
+package v4l2
+
+/*
+ * 64-bit members are aligned by Go like by the C compiler, see align_arm.go
+ */
+type pad64 = [0]uint32
@@ -0,0 +1,140 @@
+package v4l2
+
+/*
+ * Control classes and control IDs, see linux/v4l2-controls.h
+ */
+
+/* Control classes */
+const (
+	V4L2_CTRL_CLASS_USER         = 0x00980000 /* Old-style 'user' controls */
+	V4L2_CTRL_CLASS_MPEG         = 0x00990000 /* MPEG-compression controls */
+	V4L2_CTRL_CLASS_CAMERA       = 0x009a0000 /* Camera class controls */
+	V4L2_CTRL_CLASS_FM_TX        = 0x009b0000 /* FM Modulator controls */
+	V4L2_CTRL_CLASS_FLASH        = 0x009c0000 /* Camera flash controls */
+	V4L2_CTRL_CLASS_JPEG         = 0x009d0000 /* JPEG-compression controls */
+	V4L2_CTRL_CLASS_IMAGE_SOURCE = 0x009e0000 /* Image source controls */
+	V4L2_CTRL_CLASS_IMAGE_PROC   = 0x009f0000 /* Image processing controls */
+	V4L2_CTRL_CLASS_DV           = 0x00a00000 /* Digital Video controls */
+	V4L2_CTRL_CLASS_FM_RX        = 0x00a10000 /* FM Receiver controls */
+	V4L2_CTRL_CLASS_RF_TUNER     = 0x00a20000 /* RF tuner controls */
+	V4L2_CTRL_CLASS_DETECT       = 0x00a30000 /* Detection controls */
+)
+
+/* User-class control IDs */
+const (
+	V4L2_CID_BASE       = V4L2_CTRL_CLASS_USER | 0x900
+	V4L2_CID_USER_BASE  = V4L2_CID_BASE
+	V4L2_CID_USER_CLASS = V4L2_CTRL_CLASS_USER | 1
+
+	V4L2_CID_BRIGHTNESS                = V4L2_CID_BASE + 0
+	V4L2_CID_CONTRAST                  = V4L2_CID_BASE + 1
+	V4L2_CID_SATURATION                = V4L2_CID_BASE + 2
+	V4L2_CID_HUE                       = V4L2_CID_BASE + 3
+	V4L2_CID_AUDIO_VOLUME              = V4L2_CID_BASE + 5
+	V4L2_CID_AUDIO_BALANCE             = V4L2_CID_BASE + 6
+	V4L2_CID_AUDIO_BASS                = V4L2_CID_BASE + 7
+	V4L2_CID_AUDIO_TREBLE              = V4L2_CID_BASE + 8
+	V4L2_CID_AUDIO_MUTE                = V4L2_CID_BASE + 9
+	V4L2_CID_AUDIO_LOUDNESS            = V4L2_CID_BASE + 10
+	V4L2_CID_BLACK_LEVEL               = V4L2_CID_BASE + 11 /* Deprecated */
+	V4L2_CID_AUTO_WHITE_BALANCE        = V4L2_CID_BASE + 12
+	V4L2_CID_DO_WHITE_BALANCE          = V4L2_CID_BASE + 13
+	V4L2_CID_RED_BALANCE               = V4L2_CID_BASE + 14
+	V4L2_CID_BLUE_BALANCE              = V4L2_CID_BASE + 15
+	V4L2_CID_GAMMA                     = V4L2_CID_BASE + 16
+	V4L2_CID_WHITENESS                 = V4L2_CID_GAMMA /* Deprecated */
+	V4L2_CID_EXPOSURE                  = V4L2_CID_BASE + 17
+	V4L2_CID_AUTOGAIN                  = V4L2_CID_BASE + 18
+	V4L2_CID_GAIN                      = V4L2_CID_BASE + 19
+	V4L2_CID_HFLIP                     = V4L2_CID_BASE + 20
+	V4L2_CID_VFLIP                     = V4L2_CID_BASE + 21
+	V4L2_CID_POWER_LINE_FREQUENCY      = V4L2_CID_BASE + 24
+	V4L2_CID_HUE_AUTO                  = V4L2_CID_BASE + 25
+	V4L2_CID_WHITE_BALANCE_TEMPERATURE = V4L2_CID_BASE + 26
+	V4L2_CID_SHARPNESS                 = V4L2_CID_BASE + 27
+	V4L2_CID_BACKLIGHT_COMPENSATION    = V4L2_CID_BASE + 28
+	V4L2_CID_CHROMA_AGC                = V4L2_CID_BASE + 29
+	V4L2_CID_COLOR_KILLER              = V4L2_CID_BASE + 30
+	V4L2_CID_COLORFX                   = V4L2_CID_BASE + 31
+	V4L2_CID_AUTOBRIGHTNESS            = V4L2_CID_BASE + 32
+	V4L2_CID_BAND_STOP_FILTER          = V4L2_CID_BASE + 33
+	V4L2_CID_ROTATE                    = V4L2_CID_BASE + 34
+	V4L2_CID_BG_COLOR                  = V4L2_CID_BASE + 35
+	V4L2_CID_CHROMA_GAIN               = V4L2_CID_BASE + 36
+	V4L2_CID_ILLUMINATORS_1            = V4L2_CID_BASE + 37
+	V4L2_CID_ILLUMINATORS_2            = V4L2_CID_BASE + 38
+	V4L2_CID_MIN_BUFFERS_FOR_CAPTURE   = V4L2_CID_BASE + 39
+	V4L2_CID_MIN_BUFFERS_FOR_OUTPUT    = V4L2_CID_BASE + 40
+	V4L2_CID_ALPHA_COMPONENT           = V4L2_CID_BASE + 41
+	V4L2_CID_COLORFX_CBCR              = V4L2_CID_BASE + 42
+
+	/* last CID + 1 */
+	V4L2_CID_LASTP1 = V4L2_CID_BASE + 43
+)
+
+/* enum v4l2_power_line_frequency */
+const (
+	V4L2_CID_POWER_LINE_FREQUENCY_DISABLED = 0
+	V4L2_CID_POWER_LINE_FREQUENCY_50HZ     = 1
+	V4L2_CID_POWER_LINE_FREQUENCY_60HZ     = 2
+	V4L2_CID_POWER_LINE_FREQUENCY_AUTO     = 3
+)
+
+/* Camera class control IDs */
+const (
+	V4L2_CID_CAMERA_CLASS_BASE = V4L2_CTRL_CLASS_CAMERA | 0x900
+	V4L2_CID_CAMERA_CLASS      = V4L2_CTRL_CLASS_CAMERA | 1
+
+	V4L2_CID_EXPOSURE_AUTO               = V4L2_CID_CAMERA_CLASS_BASE + 1
+	V4L2_CID_EXPOSURE_ABSOLUTE           = V4L2_CID_CAMERA_CLASS_BASE + 2
+	V4L2_CID_EXPOSURE_AUTO_PRIORITY      = V4L2_CID_CAMERA_CLASS_BASE + 3
+	V4L2_CID_PAN_RELATIVE                = V4L2_CID_CAMERA_CLASS_BASE + 4
+	V4L2_CID_TILT_RELATIVE               = V4L2_CID_CAMERA_CLASS_BASE + 5
+	V4L2_CID_PAN_RESET                   = V4L2_CID_CAMERA_CLASS_BASE + 6
+	V4L2_CID_TILT_RESET                  = V4L2_CID_CAMERA_CLASS_BASE + 7
+	V4L2_CID_PAN_ABSOLUTE                = V4L2_CID_CAMERA_CLASS_BASE + 8
+	V4L2_CID_TILT_ABSOLUTE               = V4L2_CID_CAMERA_CLASS_BASE + 9
+	V4L2_CID_FOCUS_ABSOLUTE              = V4L2_CID_CAMERA_CLASS_BASE + 10
+	V4L2_CID_FOCUS_RELATIVE              = V4L2_CID_CAMERA_CLASS_BASE + 11
+	V4L2_CID_FOCUS_AUTO                  = V4L2_CID_CAMERA_CLASS_BASE + 12
+	V4L2_CID_ZOOM_ABSOLUTE               = V4L2_CID_CAMERA_CLASS_BASE + 13
+	V4L2_CID_ZOOM_RELATIVE               = V4L2_CID_CAMERA_CLASS_BASE + 14
+	V4L2_CID_ZOOM_CONTINUOUS             = V4L2_CID_CAMERA_CLASS_BASE + 15
+	V4L2_CID_PRIVACY                     = V4L2_CID_CAMERA_CLASS_BASE + 16
+	V4L2_CID_IRIS_ABSOLUTE               = V4L2_CID_CAMERA_CLASS_BASE + 17
+	V4L2_CID_IRIS_RELATIVE               = V4L2_CID_CAMERA_CLASS_BASE + 18
+	V4L2_CID_AUTO_EXPOSURE_BIAS          = V4L2_CID_CAMERA_CLASS_BASE + 19
+	V4L2_CID_AUTO_N_PRESET_WHITE_BALANCE = V4L2_CID_CAMERA_CLASS_BASE + 20
+	V4L2_CID_WIDE_DYNAMIC_RANGE          = V4L2_CID_CAMERA_CLASS_BASE + 21
+	V4L2_CID_IMAGE_STABILIZATION         = V4L2_CID_CAMERA_CLASS_BASE + 22
+	V4L2_CID_ISO_SENSITIVITY             = V4L2_CID_CAMERA_CLASS_BASE + 23
+	V4L2_CID_ISO_SENSITIVITY_AUTO        = V4L2_CID_CAMERA_CLASS_BASE + 24
+	V4L2_CID_EXPOSURE_METERING           = V4L2_CID_CAMERA_CLASS_BASE + 25
+	V4L2_CID_SCENE_MODE                  = V4L2_CID_CAMERA_CLASS_BASE + 26
+	V4L2_CID_3A_LOCK                     = V4L2_CID_CAMERA_CLASS_BASE + 27
+	V4L2_CID_AUTO_FOCUS_START            = V4L2_CID_CAMERA_CLASS_BASE + 28
+	V4L2_CID_AUTO_FOCUS_STOP             = V4L2_CID_CAMERA_CLASS_BASE + 29
+	V4L2_CID_AUTO_FOCUS_STATUS           = V4L2_CID_CAMERA_CLASS_BASE + 30
+	V4L2_CID_AUTO_FOCUS_RANGE            = V4L2_CID_CAMERA_CLASS_BASE + 31
+	V4L2_CID_PAN_SPEED                   = V4L2_CID_CAMERA_CLASS_BASE + 32
+	V4L2_CID_TILT_SPEED                  = V4L2_CID_CAMERA_CLASS_BASE + 33
+)
+
+/* JPEG-class control IDs */
+const (
+	V4L2_CID_JPEG_CLASS_BASE = V4L2_CTRL_CLASS_JPEG | 0x900
+	V4L2_CID_JPEG_CLASS      = V4L2_CTRL_CLASS_JPEG | 1
+
+	V4L2_CID_JPEG_CHROMA_SUBSAMPLING  = V4L2_CID_JPEG_CLASS_BASE + 1
+	V4L2_CID_JPEG_RESTART_INTERVAL    = V4L2_CID_JPEG_CLASS_BASE + 2
+	V4L2_CID_JPEG_COMPRESSION_QUALITY = V4L2_CID_JPEG_CLASS_BASE + 3
+	V4L2_CID_JPEG_ACTIVE_MARKER       = V4L2_CID_JPEG_CLASS_BASE + 4
+)
+
+/* enum v4l2_exposure_auto_type */
+const (
+	V4L2_EXPOSURE_AUTO              = 0
+	V4L2_EXPOSURE_MANUAL            = 1
+	V4L2_EXPOSURE_SHUTTER_PRIORITY  = 2
+	V4L2_EXPOSURE_APERTURE_PRIORITY = 3
+)
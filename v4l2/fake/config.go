@@ -0,0 +1,275 @@
+package fake
+
+import (
+	"v4l2"
+)
+
+/*
+* Description of an emulated video device
+ */
+type Config struct {
+	Driver  string
+	Card    string
+	BusInfo string
+	/* device capabilities, V4L2_CAP_* */
+	Capabilities uint32
+	Formats      []Format
+	Controls     []Control
+	/* event types besides V4L2_EVENT_CTRL the device reports, V4L2_EVENT_* */
+	Events []uint32
+	/* video inputs, the input ioctls are not implemented if there are none */
+	Inputs []Input
+	/* timings the receiver supports, inputs with V4L2_IN_CAP_DV_TIMINGS start with the first one */
+	DVTimings []v4l2.V4l2BtTimings
+	/*
+	* the sensor crops to the selection within the largest frame size of the format, it has
+	* no scaler, the frame size follows the crop rectangle and a new format re-centres it
+	 */
+	Crop bool
+}
+
+type Format struct {
+	PixelFormat uint32
+	Description string
+	/* V4L2_FMT_FLAG_* */
+	Flags uint32
+	/* memory planes of raw formats on multi-planar devices, zero stands for one */
+	Planes uint32
+	Sizes  []FrameSize
+}
+
+type FrameSize struct {
+	Width  uint32
+	Height uint32
+	/* discrete frame intervals, the first one is used after the size is set */
+	Intervals []v4l2.V4l2Fract
+}
+
+type Input struct {
+	Name string
+	/* V4L2_INPUT_TYPE_* */
+	Type uint32
+	/* V4L2_IN_ST_*, see Driver.SetInputStatus */
+	Status       uint32
+	Capabilities uint32
+	/* analog standards the input supports, V4L2_STD_*, the first one is set up initially */
+	Std uint64
+	/* standard of the incoming signal VIDIOC_QUERYSTD detects */
+	Signal uint64
+	/* timings of the incoming signal VIDIOC_QUERY_DV_TIMINGS detects, see Driver.SetSourceTimings */
+	Timings v4l2.V4l2BtTimings
+}
+
+type Control struct {
+	Id      uint32
+	Type    uint32
+	Name    string
+	Minimum int32
+	Maximum int32
+	Step    int32
+	Default int32
+	Flags   uint32
+	/* item names of menu controls, indexed from Minimum */
+	Menu []string
+}
+
+/*
+* USB camera with MJPEG and YUYV formats, a few user, camera and JPEG class controls and one input
+ */
+func DefaultConfig() Config {
+
+	fps := func(rates ...uint32) []v4l2.V4l2Fract {
+		intervals := make([]v4l2.V4l2Fract, 0, len(rates))
+		for _, r := range rates {
+			intervals = append(intervals, v4l2.V4l2Fract{Numerator: 1, Denominator: r})
+		}
+		return intervals
+	}
+
+	return Config{
+		Driver:       "fake",
+		Card:         "Fake Camera",
+		BusInfo:      "platform:fake",
+		Capabilities: v4l2.V4L2_CAP_VIDEO_CAPTURE | v4l2.V4L2_CAP_STREAMING,
+		Formats: []Format{
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_MJPEG,
+				Description: "Motion-JPEG",
+				Flags:       v4l2.V4L2_FMT_FLAG_COMPRESSED,
+				Sizes: []FrameSize{
+					{640, 480, fps(30, 15)},
+					{1280, 720, fps(30, 15)},
+					{1920, 1080, fps(30, 15)},
+				},
+			},
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_YUYV,
+				Description: "YUYV 4:2:2",
+				Sizes: []FrameSize{
+					{640, 480, fps(30, 15)},
+					{1280, 720, fps(10, 5)},
+				},
+			},
+		},
+		Controls: []Control{
+			{Id: v4l2.V4L2_CID_BRIGHTNESS, Type: v4l2.V4L2_CTRL_TYPE_INTEGER, Name: "Brightness", Minimum: 0, Maximum: 255, Step: 1, Default: 128, Flags: v4l2.V4L2_CTRL_FLAG_SLIDER},
+			{Id: v4l2.V4L2_CID_CONTRAST, Type: v4l2.V4L2_CTRL_TYPE_INTEGER, Name: "Contrast", Minimum: 0, Maximum: 255, Step: 1, Default: 32, Flags: v4l2.V4L2_CTRL_FLAG_SLIDER},
+			{Id: v4l2.V4L2_CID_POWER_LINE_FREQUENCY, Type: v4l2.V4L2_CTRL_TYPE_MENU, Name: "Power Line Frequency", Minimum: 0, Maximum: 2, Step: 1, Default: 1, Menu: []string{"Disabled", "50 Hz", "60 Hz"}},
+			{Id: v4l2.V4L2_CID_EXPOSURE_AUTO, Type: v4l2.V4L2_CTRL_TYPE_MENU, Name: "Exposure, Auto", Minimum: 0, Maximum: 3, Step: 1, Default: 3, Menu: []string{"Auto Mode", "Manual Mode", "Shutter Priority Mode", "Aperture Priority Mode"}},
+			{Id: v4l2.V4L2_CID_EXPOSURE_ABSOLUTE, Type: v4l2.V4L2_CTRL_TYPE_INTEGER, Name: "Exposure (Absolute)", Minimum: 3, Maximum: 2047, Step: 1, Default: 250},
+			{Id: v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY, Type: v4l2.V4L2_CTRL_TYPE_INTEGER, Name: "Compression Quality", Minimum: 10, Maximum: 95, Step: 5, Default: 80},
+		},
+		Inputs: []Input{{Name: "Camera 1", Type: v4l2.V4L2_INPUT_TYPE_CAMERA}},
+	}
+}
+
+/*
+* Multi-planar capture device like the ones of SoC camera interfaces, with NV12M and YUV420M formats
+* and frame sync, source change and end of stream events. The sensor crops without scaling.
+ */
+func MultiPlanarConfig() Config {
+
+	intervals := []v4l2.V4l2Fract{{Numerator: 1, Denominator: 30}}
+
+	return Config{
+		Driver:       "fake",
+		Card:         "Fake Multi-planar Camera",
+		BusInfo:      "platform:fake-mplane",
+		Capabilities: v4l2.V4L2_CAP_VIDEO_CAPTURE_MPLANE | v4l2.V4L2_CAP_STREAMING,
+		Formats: []Format{
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_NV12M,
+				Description: "Y/CbCr 4:2:0 (N-C)",
+				Planes:      2,
+				Sizes:       []FrameSize{{640, 480, intervals}, {1280, 720, intervals}},
+			},
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_YUV420M,
+				Description: "Planar YUV 4:2:0 (N-C)",
+				Planes:      3,
+				Sizes:       []FrameSize{{640, 480, intervals}, {1280, 720, intervals}},
+			},
+		},
+		Events: []uint32{v4l2.V4L2_EVENT_FRAME_SYNC, v4l2.V4L2_EVENT_SOURCE_CHANGE, v4l2.V4L2_EVENT_EOS},
+		Crop:   true,
+	}
+}
+
+/*
+* Output device like the ones of v4l2loopback, frames written to it are available through Driver.LastFrame
+ */
+func LoopbackConfig() Config {
+
+	intervals := []v4l2.V4l2Fract{{Numerator: 1, Denominator: 30}, {Numerator: 1, Denominator: 15}}
+
+	return Config{
+		Driver:       "v4l2 loopback",
+		Card:         "Fake Loopback",
+		BusInfo:      "platform:v4l2loopback-000",
+		Capabilities: v4l2.V4L2_CAP_VIDEO_OUTPUT | v4l2.V4L2_CAP_STREAMING,
+		Formats: []Format{
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_YUYV,
+				Description: "YUYV 4:2:2",
+				Sizes:       []FrameSize{{640, 480, intervals}, {1280, 720, intervals}},
+			},
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_MJPEG,
+				Description: "Motion-JPEG",
+				Flags:       v4l2.V4L2_FMT_FLAG_COMPRESSED,
+				Sizes:       []FrameSize{{640, 480, intervals}, {1280, 720, intervals}},
+			},
+		},
+	}
+}
+
+/*
+* Analog capture card with composite and S-Video inputs. A PAL source is connected to the
+* composite input, nothing to the S-Video one.
+ */
+func CaptureCardConfig() Config {
+
+	pal := []v4l2.V4l2Fract{{Numerator: 1, Denominator: 25}}
+	ntsc := []v4l2.V4l2Fract{{Numerator: 1001, Denominator: 30000}}
+	std := uint64(v4l2.V4L2_STD_NTSC | v4l2.V4L2_STD_PAL | v4l2.V4L2_STD_SECAM)
+
+	return Config{
+		Driver:       "fake",
+		Card:         "Fake Capture Card",
+		BusInfo:      "PCI:0000:03:00.0",
+		Capabilities: v4l2.V4L2_CAP_VIDEO_CAPTURE | v4l2.V4L2_CAP_READWRITE | v4l2.V4L2_CAP_STREAMING,
+		Formats: []Format{
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_YUYV,
+				Description: "YUYV 4:2:2",
+				Sizes:       []FrameSize{{720, 480, ntsc}, {720, 576, pal}},
+			},
+		},
+		Controls: []Control{
+			{Id: v4l2.V4L2_CID_BRIGHTNESS, Type: v4l2.V4L2_CTRL_TYPE_INTEGER, Name: "Brightness", Minimum: 0, Maximum: 255, Step: 1, Default: 128, Flags: v4l2.V4L2_CTRL_FLAG_SLIDER},
+		},
+		Events: []uint32{v4l2.V4L2_EVENT_SOURCE_CHANGE},
+		Inputs: []Input{
+			{Name: "Composite", Type: v4l2.V4L2_INPUT_TYPE_CAMERA, Capabilities: v4l2.V4L2_IN_CAP_STD, Std: std, Signal: v4l2.V4L2_STD_PAL_B},
+			{Name: "S-Video", Type: v4l2.V4L2_INPUT_TYPE_CAMERA, Status: v4l2.V4L2_IN_ST_NO_SIGNAL, Capabilities: v4l2.V4L2_IN_CAP_STD, Std: std},
+		},
+	}
+}
+
+/*
+* HDMI capture bridge receiving 1080p60 from the connected source
+ */
+func HDMIConfig() Config {
+
+	intervals := []v4l2.V4l2Fract{{Numerator: 1, Denominator: 60}}
+
+	return Config{
+		Driver:       "fake",
+		Card:         "Fake HDMI Bridge",
+		BusInfo:      "platform:fake-hdmi",
+		Capabilities: v4l2.V4L2_CAP_VIDEO_CAPTURE | v4l2.V4L2_CAP_STREAMING,
+		Formats: []Format{
+			{
+				PixelFormat: v4l2.V4L2_PIX_FMT_YUYV,
+				Description: "YUYV 4:2:2",
+				Sizes:       []FrameSize{{1920, 1080, intervals}, {1280, 720, intervals}, {640, 480, intervals}},
+			},
+		},
+		Events: []uint32{v4l2.V4L2_EVENT_SOURCE_CHANGE},
+		Inputs: []Input{
+			{Name: "HDMI", Type: v4l2.V4L2_INPUT_TYPE_CAMERA, Capabilities: v4l2.V4L2_IN_CAP_DV_TIMINGS, Timings: Timings1080p60},
+		},
+		DVTimings: []v4l2.V4l2BtTimings{Timings1080p60, Timings720p60, Timings640x480p60},
+	}
+}
+
+/*
+* CEA-861 timings, see v4l2-dv-timings.h
+ */
+var (
+	Timings1080p60    = btTimings(1920, 1080, 148500000, [3]uint32{88, 44, 148}, [3]uint32{4, 5, 36}, v4l2.V4L2_DV_HSYNC_POS_POL|v4l2.V4L2_DV_VSYNC_POS_POL, 16)
+	Timings720p60     = btTimings(1280, 720, 74250000, [3]uint32{110, 40, 220}, [3]uint32{5, 5, 20}, v4l2.V4L2_DV_HSYNC_POS_POL|v4l2.V4L2_DV_VSYNC_POS_POL, 4)
+	Timings640x480p60 = btTimings(640, 480, 25175000, [3]uint32{16, 96, 48}, [3]uint32{10, 2, 33}, 0, 1)
+)
+
+/*
+* Progressive timings with the front porch, sync and back porch of both directions
+ */
+func btTimings(width uint32, height uint32, pixelclock uint64, h [3]uint32, v [3]uint32, polarities uint32, vic uint8) v4l2.V4l2BtTimings {
+	timings := v4l2.V4l2BtTimings{
+		Width:       width,
+		Height:      height,
+		Polarities:  polarities,
+		Hfrontporch: h[0],
+		Hsync:       h[1],
+		Hbackporch:  h[2],
+		Vfrontporch: v[0],
+		Vsync:       v[1],
+		Vbackporch:  v[2],
+		Standards:   v4l2.V4L2_DV_BT_STD_CEA861,
+		Flags:       v4l2.V4L2_DV_FL_HAS_CEA861_VIC,
+		Cea861Vic:   vic,
+	}
+	timings.SetPixelclock(pixelclock)
+	return timings
+}
@@ -0,0 +1,154 @@
+package fake
+
+import (
+	"syscall"
+	"v4l2"
+)
+
+/* smallest crop rectangle the sensor supports */
+const minCrop = 16
+
+/*
+* Selections take the single-planar buffer type on multi-planar devices as well, like
+* the kernel accepts since 4.13
+ */
+func (d *device) selectionType(bufType uint32) bool {
+	switch d.bufType() {
+	case v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE:
+		return bufType == v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE || bufType == v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
+	case v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE:
+		return bufType == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE || bufType == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT
+	}
+	return bufType == d.bufType()
+}
+
+/*
+* Area of the sensor, the largest frame size of the current format
+ */
+func (d *device) cropBounds() v4l2.V4l2Rect {
+	var bounds v4l2.V4l2Rect
+	f, _ := d.findFormat(d.format.Pixelformat)
+
+	for _, s := range f.Sizes {
+		if s.Width*s.Height > bounds.Width*bounds.Height {
+			bounds = v4l2.V4l2Rect{Width: s.Width, Height: s.Height}
+		}
+	}
+
+	return bounds
+}
+
+/*
+* Fits the rectangle into the sensor with even sizes, centred if asked to
+ */
+func (d *device) clampCrop(rect v4l2.V4l2Rect, centre bool) v4l2.V4l2Rect {
+	bounds := d.cropBounds()
+
+	rect.Width = clamp(rect.Width, minCrop, bounds.Width) &^ 1
+	rect.Height = clamp(rect.Height, minCrop, bounds.Height) &^ 1
+
+	if centre {
+		rect.Left = int32(bounds.Width-rect.Width) / 2
+		rect.Top = int32(bounds.Height-rect.Height) / 2
+	}
+
+	rect.Left = clampOffset(rect.Left, bounds.Width-rect.Width)
+	rect.Top = clampOffset(rect.Top, bounds.Height-rect.Height)
+	return rect
+}
+
+func clamp(value uint32, min uint32, max uint32) uint32 {
+	if value < min {
+		value = min
+	}
+	if value > max {
+		value = max
+	}
+	return value
+}
+
+func clampOffset(offset int32, max uint32) int32 {
+	if offset < 0 {
+		return 0
+	}
+	return int32(clamp(uint32(offset), 0, max))
+}
+
+func (d *device) getSelection(selection *v4l2.V4l2Selection) error {
+	if !d.config.Crop || !d.selectionType(selection.Type) {
+		return syscall.EINVAL
+	}
+
+	switch selection.Target {
+	case v4l2.V4L2_SEL_TGT_CROP:
+		selection.R = d.crop
+	case v4l2.V4L2_SEL_TGT_CROP_DEFAULT, v4l2.V4L2_SEL_TGT_CROP_BOUNDS, v4l2.V4L2_SEL_TGT_NATIVE_SIZE:
+		selection.R = d.cropBounds()
+	default:
+		return syscall.EINVAL
+	}
+
+	return nil
+}
+
+/*
+* The sensor has no scaler, the frame size becomes the size of the adjusted rectangle.
+* Changing the size is refused while buffers are allocated.
+ */
+func (d *device) setSelection(selection *v4l2.V4l2Selection) error {
+	if !d.config.Crop || !d.selectionType(selection.Type) || selection.Target != v4l2.V4L2_SEL_TGT_CROP {
+		return syscall.EINVAL
+	}
+
+	rect := d.clampCrop(selection.R, false)
+
+	if rect.Width != d.format.Width || rect.Height != d.format.Height {
+		if len(d.buffers) > 0 || d.reading {
+			return syscall.EBUSY
+		}
+
+		f, _ := d.findFormat(d.format.Pixelformat)
+		bounds := d.cropBounds()
+		size, _ := d.findSize(f.PixelFormat, bounds.Width, bounds.Height)
+		d.applyFormat(f, FrameSize{rect.Width, rect.Height, size.Intervals})
+	}
+
+	d.crop = rect
+	selection.R = rect
+	return nil
+}
+
+/*
+* The legacy crop ioctls are served by the selection ones like in v4l2-ioctl
+ */
+func (d *device) cropCapability(cropcap *v4l2.V4l2Cropcap) error {
+	if !d.selectionType(cropcap.Type) {
+		return syscall.EINVAL
+	}
+
+	if !d.config.Crop {
+		return syscall.ENODATA
+	}
+
+	bounds := d.cropBounds()
+	cropcap.Bounds = bounds
+	cropcap.Defrect = bounds
+	cropcap.Pixelaspect = v4l2.V4l2Fract{Numerator: 1, Denominator: 1}
+	return nil
+}
+
+func (d *device) getCrop(crop *v4l2.V4l2Crop) error {
+	selection := v4l2.V4l2Selection{Type: crop.Type, Target: v4l2.V4L2_SEL_TGT_CROP}
+
+	if err := d.getSelection(&selection); err != nil {
+		return err
+	}
+
+	crop.C = selection.R
+	return nil
+}
+
+func (d *device) setCrop(crop *v4l2.V4l2Crop) error {
+	selection := v4l2.V4l2Selection{Type: crop.Type, Target: v4l2.V4L2_SEL_TGT_CROP, R: crop.C}
+	return d.setSelection(&selection)
+}
@@ -0,0 +1,1280 @@
+package fake
+
+import (
+	"context"
+	"math"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/* maximum number of buffers a queue can hold */
+const maxBuffers = 32
+
+/* buffers are exposed to mmap at page aligned offsets */
+const pageSize = 4096
+
+type buffer struct {
+	device *device
+	index  uint32
+	/* driver memory of MMAP buffers per plane, the memory attached by the last QBUF otherwise */
+	planes    [][]byte
+	userptr   uintptr
+	fd        int32
+	mapped    int
+	queued    bool
+	bytesused []uint32
+	/* frame number an output buffer was consumed as */
+	sequence uint32
+}
+
+/*
+* State of an emulated device shared by all its open files. The buffer queue belongs
+* to the file which requested the buffers, like in videobuf2.
+ */
+type device struct {
+	driver *Driver
+	config Config
+
+	mutex sync.Mutex
+	/* closed and replaced whenever the queue changes */
+	changed chan struct{}
+
+	format   v4l2.V4l2PixFormat
+	interval v4l2.V4l2Fract
+	values   map[uint32]int64
+
+	owner     *openFile
+	memory    uint32
+	buffers   []*buffer
+	queue     []*buffer
+	streaming bool
+	/* output buffers the driver is done with and the payload of the last one */
+	done    []*buffer
+	written []byte
+	/* the owner captures by read(), the queue is busy until it closes the device */
+	reading  bool
+	sequence uint32
+
+	subscribers map[*openFile]*subscriber
+
+	/* inputs with their current status and the index of the selected one */
+	inputs []Input
+	input  uint32
+	/* standard set up for the current input */
+	std uint64
+	/* DV timings set up for the current input */
+	timings v4l2.V4l2BtTimings
+	/* crop rectangle within the sensor */
+	crop v4l2.V4l2Rect
+}
+
+func newDevice(driver *Driver, config Config) *device {
+	dev := &device{driver: driver, config: config, changed: make(chan struct{}), values: make(map[uint32]int64), subscribers: make(map[*openFile]*subscriber)}
+
+	for _, c := range config.Controls {
+		dev.values[c.Id] = int64(c.Default)
+	}
+
+	dev.inputs = append([]Input(nil), config.Inputs...)
+
+	if len(config.Formats) > 0 && len(config.Formats[0].Sizes) > 0 {
+		f := config.Formats[0]
+		dev.applyFormat(f, f.Sizes[0])
+	}
+
+	if len(dev.inputs) > 0 && dev.inputs[0].Std != 0 {
+		dev.applyStandard(dev.inputs[0].Std)
+	}
+
+	if len(dev.inputs) > 0 && dev.inputs[0].Capabilities&v4l2.V4L2_IN_CAP_DV_TIMINGS > 0 && len(config.DVTimings) > 0 {
+		dev.applyTimings(config.DVTimings[0])
+	}
+
+	return dev
+}
+
+func (d *device) notify() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+func (d *device) ioctl(file *openFile, request uintptr, arg unsafe.Pointer) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch request {
+	case ioctl.VIDIOC_QUERYCAP:
+		return d.queryCapability((*v4l2.V4l2Capability)(arg))
+	case ioctl.VIDIOC_ENUM_FMT:
+		return d.enumFormat((*v4l2.V4l2Fmtdesc)(arg))
+	case ioctl.VIDIOC_ENUM_FRAMESIZES:
+		return d.enumFrameSize((*v4l2.V4l2Frmsizeenum)(arg))
+	case ioctl.VIDIOC_ENUM_FRAMEINTERVALS:
+		return d.enumFrameInterval((*v4l2.V4l2Frmivalenum)(arg))
+	case ioctl.VIDIOC_G_FMT:
+		return d.getFormat((*v4l2.V4l2Format)(arg))
+	case ioctl.VIDIOC_TRY_FMT:
+		return d.tryFormat((*v4l2.V4l2Format)(arg), false)
+	case ioctl.VIDIOC_S_FMT:
+		return d.tryFormat((*v4l2.V4l2Format)(arg), true)
+	case ioctl.VIDIOC_G_PARM:
+		return d.getParm((*v4l2.V4l2Streamparm)(arg))
+	case ioctl.VIDIOC_S_PARM:
+		return d.setParm((*v4l2.V4l2Streamparm)(arg))
+	case ioctl.VIDIOC_REQBUFS:
+		return d.requestBuffers(file, (*v4l2.V4l2RequestBuffers)(arg))
+	case ioctl.VIDIOC_QUERYBUF:
+		return d.queryBuffer((*v4l2.V4l2Buffer)(arg))
+	case ioctl.VIDIOC_EXPBUF:
+		return d.exportBuffer((*v4l2.V4l2ExportBuffer)(arg))
+	case ioctl.VIDIOC_QBUF:
+		return d.queueBuffer(file, (*v4l2.V4l2Buffer)(arg))
+	case ioctl.VIDIOC_DQBUF:
+		return d.dequeueBuffer(file, (*v4l2.V4l2Buffer)(arg))
+	case ioctl.VIDIOC_STREAMON:
+		return d.streamOn(file, *(*uint32)(arg))
+	case ioctl.VIDIOC_STREAMOFF:
+		return d.streamOff(file, *(*uint32)(arg))
+	case ioctl.VIDIOC_QUERYCTRL:
+		return d.queryControl((*v4l2.V4l2Queryctrl)(arg))
+	case ioctl.VIDIOC_QUERYMENU:
+		return d.queryMenu((*v4l2.V4l2Querymenu)(arg))
+	case ioctl.VIDIOC_G_CTRL:
+		return d.getControl((*v4l2.V4l2Control)(arg))
+	case ioctl.VIDIOC_S_CTRL:
+		return d.setControl(file, (*v4l2.V4l2Control)(arg))
+	case ioctl.VIDIOC_G_EXT_CTRLS:
+		return d.extControls(file, (*v4l2.V4l2ExtControls)(arg), ioctl.VIDIOC_G_EXT_CTRLS)
+	case ioctl.VIDIOC_S_EXT_CTRLS:
+		return d.extControls(file, (*v4l2.V4l2ExtControls)(arg), ioctl.VIDIOC_S_EXT_CTRLS)
+	case ioctl.VIDIOC_TRY_EXT_CTRLS:
+		return d.extControls(file, (*v4l2.V4l2ExtControls)(arg), ioctl.VIDIOC_TRY_EXT_CTRLS)
+	case ioctl.VIDIOC_G_JPEGCOMP:
+		return d.getJpegCompression((*v4l2.V4l2Jpegcompression)(arg))
+	case ioctl.VIDIOC_S_JPEGCOMP:
+		return d.setJpegCompression(file, (*v4l2.V4l2Jpegcompression)(arg))
+	case ioctl.VIDIOC_CROPCAP:
+		return d.cropCapability((*v4l2.V4l2Cropcap)(arg))
+	case ioctl.VIDIOC_G_CROP:
+		return d.getCrop((*v4l2.V4l2Crop)(arg))
+	case ioctl.VIDIOC_S_CROP:
+		return d.setCrop((*v4l2.V4l2Crop)(arg))
+	case ioctl.VIDIOC_G_SELECTION:
+		return d.getSelection((*v4l2.V4l2Selection)(arg))
+	case ioctl.VIDIOC_S_SELECTION:
+		return d.setSelection((*v4l2.V4l2Selection)(arg))
+	case ioctl.VIDIOC_ENUMSTD:
+		return d.enumStandard((*v4l2.V4l2Standard)(arg))
+	case ioctl.VIDIOC_G_STD:
+		return d.getStandard((*uint64)(arg))
+	case ioctl.VIDIOC_S_STD:
+		return d.setStandard((*uint64)(arg))
+	case ioctl.VIDIOC_QUERYSTD:
+		return d.queryStandard((*uint64)(arg))
+	case ioctl.VIDIOC_ENUM_DV_TIMINGS:
+		return d.enumDvTimings((*v4l2.V4l2EnumDvTimings)(arg))
+	case ioctl.VIDIOC_G_DV_TIMINGS:
+		return d.getDvTimings((*v4l2.V4l2DvTimings)(arg))
+	case ioctl.VIDIOC_S_DV_TIMINGS:
+		return d.setDvTimings((*v4l2.V4l2DvTimings)(arg))
+	case ioctl.VIDIOC_QUERY_DV_TIMINGS:
+		return d.queryDvTimings((*v4l2.V4l2DvTimings)(arg))
+	case ioctl.VIDIOC_ENUMINPUT:
+		return d.enumInput((*v4l2.V4l2Input)(arg))
+	case ioctl.VIDIOC_G_INPUT:
+		return d.getInput((*int32)(arg))
+	case ioctl.VIDIOC_S_INPUT:
+		return d.setInput((*int32)(arg))
+	case ioctl.VIDIOC_SUBSCRIBE_EVENT:
+		return d.subscribeEvent(file, (*v4l2.V4l2EventSubscription)(arg))
+	case ioctl.VIDIOC_UNSUBSCRIBE_EVENT:
+		return d.unsubscribeEvent(file, (*v4l2.V4l2EventSubscription)(arg))
+	case ioctl.VIDIOC_DQEVENT:
+		return d.dequeueEvent(file, (*v4l2.V4l2Event)(arg))
+	}
+
+	return syscall.ENOTTY
+}
+
+//--------------------------------------------------------------------------------------------------
+//CAPABILITY AND FORMATS
+//--------------------------------------------------------------------------------------------------
+
+func (d *device) queryCapability(capability *v4l2.V4l2Capability) error {
+	*capability = v4l2.V4l2Capability{}
+	copy(capability.Driver[:len(capability.Driver)-1], d.config.Driver)
+	copy(capability.Card[:len(capability.Card)-1], d.config.Card)
+	copy(capability.BusInfo[:len(capability.BusInfo)-1], d.config.BusInfo)
+	capability.Capabilities = d.config.Capabilities | v4l2.V4L2_CAP_DEVICE_CAPS
+	capability.DeviceCaps = d.config.Capabilities
+	return nil
+}
+
+/*
+* Devices accept a single buffer type chosen by their capabilities, output is
+* preferred to capture and multi-planar to single-planar buffers
+ */
+func (d *device) bufType() uint32 {
+	switch caps := d.config.Capabilities; {
+	case caps&v4l2.V4L2_CAP_VIDEO_OUTPUT_MPLANE > 0:
+		return v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE
+	case caps&v4l2.V4L2_CAP_VIDEO_OUTPUT > 0:
+		return v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT
+	case caps&v4l2.V4L2_CAP_VIDEO_CAPTURE_MPLANE > 0:
+		return v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE
+	}
+	return v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
+}
+
+func (d *device) multiPlanar() bool {
+	return d.bufType() == v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE || d.bufType() == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE
+}
+
+func (d *device) output() bool {
+	return d.bufType() == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT || d.bufType() == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE
+}
+
+func (d *device) enumFormat(desc *v4l2.V4l2Fmtdesc) error {
+	if desc.Typ != d.bufType() || desc.Index >= uint32(len(d.config.Formats)) {
+		return syscall.EINVAL
+	}
+
+	f := d.config.Formats[desc.Index]
+	desc.Flags = f.Flags
+	desc.Pixelformat = f.PixelFormat
+	desc.Description = [32]uint8{}
+	copy(desc.Description[:len(desc.Description)-1], f.Description)
+	return nil
+}
+
+func (d *device) findFormat(pixelFormat uint32) (Format, bool) {
+	for _, f := range d.config.Formats {
+		if f.PixelFormat == pixelFormat {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+func (d *device) findSize(pixelFormat uint32, width uint32, height uint32) (FrameSize, bool) {
+	f, ok := d.findFormat(pixelFormat)
+
+	if !ok {
+		return FrameSize{}, false
+	}
+
+	for _, s := range f.Sizes {
+		if s.Width == width && s.Height == height {
+			return s, true
+		}
+	}
+
+	return FrameSize{}, false
+}
+
+func (d *device) enumFrameSize(size *v4l2.V4l2Frmsizeenum) error {
+	f, ok := d.findFormat(size.PixelFormat)
+
+	if !ok || size.Index >= uint32(len(f.Sizes)) {
+		return syscall.EINVAL
+	}
+
+	s := f.Sizes[size.Index]
+	size.Type = v4l2.V4L2_FRMSIZE_TYPE_DISCRETE
+	size.SetDiscrete(v4l2.V4l2Frmsize_discrete{Width: s.Width, Height: s.Height})
+	return nil
+}
+
+func (d *device) enumFrameInterval(interval *v4l2.V4l2Frmivalenum) error {
+	s, ok := d.findSize(interval.PixelFormat, interval.Width, interval.Height)
+
+	if !ok || interval.Index >= uint32(len(s.Intervals)) {
+		return syscall.EINVAL
+	}
+
+	interval.Type = v4l2.V4L2_FRMIVAL_TYPE_DISCRETE
+	interval.SetDiscrete(s.Intervals[interval.Index])
+	return nil
+}
+
+func (d *device) getFormat(format *v4l2.V4l2Format) error {
+	if format.Type != d.bufType() {
+		return syscall.EINVAL
+	}
+
+	f, _ := d.findFormat(d.format.Pixelformat)
+	d.describeFormat(format, f, d.format)
+	return nil
+}
+
+/*
+* Stores the format in the member of the union which matches the buffer type
+ */
+func (d *device) describeFormat(format *v4l2.V4l2Format, f Format, pix v4l2.V4l2PixFormat) {
+	if !d.multiPlanar() {
+		format.SetPixFormat(&pix)
+		format.Type = d.bufType()
+		return
+	}
+
+	planes := planeLayout(f, pix)
+	mplane := v4l2.V4l2PixFormatMplane{
+		Width:       pix.Width,
+		Height:      pix.Height,
+		Pixelformat: pix.Pixelformat,
+		Field:       pix.Field,
+		Colorspace:  pix.Colorspace,
+		NumPlanes:   uint8(len(planes)),
+	}
+
+	copy(mplane.PlaneFmt[:], planes)
+	format.SetPixFormatMplane(&mplane)
+	format.Type = d.bufType()
+}
+
+/*
+* Adjusts the requested format to the closest supported one the way drivers do,
+* an unknown pixel format is replaced by the first one
+ */
+func (d *device) tryFormat(format *v4l2.V4l2Format, set bool) error {
+	if format.Type != d.bufType() || len(d.config.Formats) == 0 {
+		return syscall.EINVAL
+	}
+
+	if set && (len(d.buffers) > 0 || d.reading) {
+		return syscall.EBUSY
+	}
+
+	requested := format.PixFormat()
+
+	if d.multiPlanar() {
+		mplane := format.PixFormatMplane()
+		requested = v4l2.V4l2PixFormat{Width: mplane.Width, Height: mplane.Height, Pixelformat: mplane.Pixelformat}
+	}
+
+	f, ok := d.findFormat(requested.Pixelformat)
+
+	if !ok {
+		f = d.config.Formats[0]
+	}
+
+	if len(f.Sizes) == 0 {
+		return syscall.EINVAL
+	}
+
+	nearest := f.Sizes[0]
+	for _, s := range f.Sizes[1:] {
+		if sizeDistance(s, requested) < sizeDistance(nearest, requested) {
+			nearest = s
+		}
+	}
+
+	pix := pixFormat(f, nearest)
+	d.describeFormat(format, f, pix)
+
+	if set {
+		d.applyFormat(f, nearest)
+	}
+
+	return nil
+}
+
+func (d *device) applyFormat(f Format, size FrameSize) {
+	d.format = pixFormat(f, size)
+	d.interval = v4l2.V4l2Fract{}
+
+	if len(size.Intervals) > 0 {
+		d.interval = size.Intervals[0]
+	}
+
+	if d.config.Crop {
+		d.crop = d.clampCrop(v4l2.V4l2Rect{Width: size.Width, Height: size.Height}, true)
+	}
+}
+
+func sizeDistance(s FrameSize, pix v4l2.V4l2PixFormat) int64 {
+	return abs(int64(s.Width)-int64(pix.Width)) + abs(int64(s.Height)-int64(pix.Height))
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+/*
+* Raw formats are treated as 16 bits per pixel, compressed ones get the same buffer size.
+* Multi-planar raw formats are 4:2:0, see planeLayout.
+ */
+func pixFormat(f Format, size FrameSize) v4l2.V4l2PixFormat {
+	pix := v4l2.V4l2PixFormat{
+		Width:       size.Width,
+		Height:      size.Height,
+		Pixelformat: f.PixelFormat,
+		Field:       v4l2.V4L2_FIELD_NONE,
+		Sizeimage:   size.Width * size.Height * 2,
+		Colorspace:  v4l2.V4L2_COLORSPACE_SRGB,
+	}
+
+	if f.Flags&v4l2.V4L2_FMT_FLAG_COMPRESSED > 0 {
+		pix.Colorspace = v4l2.V4L2_COLORSPACE_JPEG
+	} else if f.Planes > 1 {
+		pix.Bytesperline = size.Width
+		pix.Sizeimage = 0
+
+		for _, p := range planeLayout(f, pix) {
+			pix.Sizeimage += p.Sizeimage
+		}
+	} else {
+		pix.Bytesperline = size.Width * 2
+	}
+
+	return pix
+}
+
+/*
+* Planes of a format, single-planar formats have one. Multi-planar raw formats hold
+* 8 bit luma in the first plane and the 4:2:0 chroma split evenly among the others,
+* like NV12M and YUV420M.
+ */
+func planeLayout(f Format, pix v4l2.V4l2PixFormat) []v4l2.V4l2PlanePixFormat {
+	if f.Planes <= 1 || f.Flags&v4l2.V4L2_FMT_FLAG_COMPRESSED > 0 {
+		return []v4l2.V4l2PlanePixFormat{{Sizeimage: pix.Sizeimage, Bytesperline: pix.Bytesperline}}
+	}
+
+	chroma := f.Planes - 1
+	planes := []v4l2.V4l2PlanePixFormat{{Sizeimage: pix.Width * pix.Height, Bytesperline: pix.Width}}
+
+	for i := uint32(0); i < chroma; i++ {
+		planes = append(planes, v4l2.V4l2PlanePixFormat{Sizeimage: pix.Width * pix.Height / 2 / chroma, Bytesperline: pix.Width / chroma})
+	}
+
+	return planes
+}
+
+/*
+* Planes of the buffers of the current format, single-planar devices keep all of them in one
+ */
+func (d *device) planeFormats() []v4l2.V4l2PlanePixFormat {
+	if !d.multiPlanar() {
+		return []v4l2.V4l2PlanePixFormat{{Sizeimage: d.format.Sizeimage, Bytesperline: d.format.Bytesperline}}
+	}
+
+	f, _ := d.findFormat(d.format.Pixelformat)
+	return planeLayout(f, d.format)
+}
+
+//--------------------------------------------------------------------------------------------------
+//STREAMING PARAMETERS
+//--------------------------------------------------------------------------------------------------
+
+func (d *device) getParm(param *v4l2.V4l2Streamparm) error {
+	if param.Type != d.bufType() {
+		return syscall.EINVAL
+	}
+
+	if d.output() {
+		output := param.Output()
+		*output = v4l2.V4l2Outputparm{}
+		output.Capability = v4l2.V4L2_CAP_TIMEPERFRAME
+		output.Timeperframe = d.interval
+		return nil
+	}
+
+	capture := param.Capture()
+	*capture = v4l2.V4l2Captureparm{}
+	capture.Capability = v4l2.V4L2_CAP_TIMEPERFRAME
+	capture.Timeperframe = d.interval
+	return nil
+}
+
+/*
+* Picks the closest interval the current frame size supports
+ */
+func (d *device) setParm(param *v4l2.V4l2Streamparm) error {
+	if param.Type != d.bufType() {
+		return syscall.EINVAL
+	}
+
+	if d.streaming {
+		return syscall.EBUSY
+	}
+
+	requested := param.Capture().Timeperframe
+
+	if d.output() {
+		requested = param.Output().Timeperframe
+	}
+
+	size, ok := d.findSize(d.format.Pixelformat, d.format.Width, d.format.Height)
+
+	if ok && len(size.Intervals) > 0 && requested.Denominator > 0 {
+		wanted := float64(requested.Numerator) / float64(requested.Denominator)
+		nearest := size.Intervals[0]
+
+		for _, i := range size.Intervals[1:] {
+			if math.Abs(seconds(i)-wanted) < math.Abs(seconds(nearest)-wanted) {
+				nearest = i
+			}
+		}
+
+		d.interval = nearest
+	}
+
+	return d.getParm(param)
+}
+
+func seconds(f v4l2.V4l2Fract) float64 {
+	return float64(f.Numerator) / float64(f.Denominator)
+}
+
+//--------------------------------------------------------------------------------------------------
+//BUFFERS
+//--------------------------------------------------------------------------------------------------
+
+func (d *device) checkOwner(file *openFile) error {
+	if d.owner != nil && d.owner != file {
+		return syscall.EBUSY
+	}
+	return nil
+}
+
+func (d *device) requestBuffers(file *openFile, request *v4l2.V4l2RequestBuffers) error {
+	if request.Type != d.bufType() {
+		return syscall.EINVAL
+	}
+
+	switch request.Memory {
+	case v4l2.V4L2_MEMORY_MMAP, v4l2.V4L2_MEMORY_USERPTR, v4l2.V4L2_MEMORY_DMABUF:
+	default:
+		return syscall.EINVAL
+	}
+
+	/* multi-planar queues only emulate MMAP */
+	if d.multiPlanar() && request.Memory != v4l2.V4L2_MEMORY_MMAP {
+		return syscall.EINVAL
+	}
+
+	if err := d.checkOwner(file); err != nil {
+		return err
+	}
+
+	if d.streaming || d.reading {
+		return syscall.EBUSY
+	}
+
+	for _, b := range d.buffers {
+		if b.mapped > 0 {
+			return syscall.EBUSY
+		}
+	}
+
+	d.buffers = nil
+	d.queue = nil
+	d.owner = nil
+
+	count := request.Count
+
+	if count > maxBuffers {
+		count = maxBuffers
+	}
+
+	d.memory = request.Memory
+	planes := d.planeFormats()
+
+	for index := uint32(0); index < count; index++ {
+		buf := &buffer{device: d, index: index, fd: -1, planes: make([][]byte, len(planes)), bytesused: make([]uint32, len(planes))}
+
+		if d.memory == v4l2.V4L2_MEMORY_MMAP {
+			for i, p := range planes {
+				buf.planes[i] = make([]byte, p.Sizeimage)
+			}
+		}
+
+		d.buffers = append(d.buffers, buf)
+	}
+
+	if count > 0 {
+		d.owner = file
+	}
+
+	request.Count = count
+	return nil
+}
+
+func (d *device) lookupBuffer(b *v4l2.V4l2Buffer) (*buffer, error) {
+	if b.Type != d.bufType() || b.Index >= uint32(len(d.buffers)) {
+		return nil, syscall.EINVAL
+	}
+
+	if err := d.checkPlanes(b); err != nil {
+		return nil, err
+	}
+
+	return d.buffers[b.Index], nil
+}
+
+/*
+* Multi-planar buffers pass a plane array which has to hold all planes of the format
+ */
+func (d *device) checkPlanes(b *v4l2.V4l2Buffer) error {
+	if d.multiPlanar() && (b.Planes() == nil || b.Length < uint32(len(d.planeFormats()))) {
+		return syscall.EINVAL
+	}
+	return nil
+}
+
+/*
+* MMAP offsets identify the buffer and the plane
+ */
+func planeOffset(index uint32, plane int) uint32 {
+	return (index*v4l2.VIDEO_MAX_PLANES + uint32(plane)) * pageSize
+}
+
+func (d *device) fillBuffer(buf *buffer, b *v4l2.V4l2Buffer) {
+	b.Memory = d.memory
+
+	if d.multiPlanar() {
+		planes := b.Planes()
+
+		for i, data := range buf.planes {
+			planes[i] = v4l2.V4l2Plane{Bytesused: buf.bytesused[i], Length: uint32(len(data))}
+			planes[i].SetMemOffset(planeOffset(buf.index, i))
+		}
+
+		b.Length = uint32(len(buf.planes))
+	} else {
+		b.Length = uint32(len(buf.planes[0]))
+		b.Bytesused = buf.bytesused[0]
+
+		switch d.memory {
+		case v4l2.V4L2_MEMORY_MMAP:
+			b.SetOffset(planeOffset(buf.index, 0))
+		case v4l2.V4L2_MEMORY_USERPTR:
+			b.SetUserptr(buf.userptr)
+		case v4l2.V4L2_MEMORY_DMABUF:
+			b.SetFd(buf.fd)
+		}
+	}
+
+	b.Flags = v4l2.V4L2_BUF_FLAG_TIMESTAMP_MONOTONIC
+
+	if buf.mapped > 0 {
+		b.Flags |= v4l2.V4L2_BUF_FLAG_MAPPED
+	}
+
+	if buf.queued {
+		b.Flags |= v4l2.V4L2_BUF_FLAG_QUEUED
+	}
+}
+
+func (d *device) queryBuffer(b *v4l2.V4l2Buffer) error {
+	buf, err := d.lookupBuffer(b)
+
+	if err != nil {
+		return err
+	}
+
+	d.fillBuffer(buf, b)
+	return nil
+}
+
+func (d *device) queueBuffer(file *openFile, b *v4l2.V4l2Buffer) error {
+	if err := d.checkOwner(file); err != nil {
+		return err
+	}
+
+	buf, err := d.lookupBuffer(b)
+
+	if err != nil {
+		return err
+	}
+
+	if b.Memory != d.memory || buf.queued {
+		return syscall.EINVAL
+	}
+
+	if err := d.attachMemory(buf, b); err != nil {
+		return err
+	}
+
+	if d.output() {
+		d.takePayload(buf, b)
+	}
+
+	buf.queued = true
+	d.queue = append(d.queue, buf)
+	d.fillBuffer(buf, b)
+
+	if d.output() && d.streaming {
+		d.consume()
+	}
+
+	d.notify()
+	return nil
+}
+
+/*
+* Payload the application filled an output buffer with, zero stands for the whole plane
+ */
+func (d *device) takePayload(buf *buffer, b *v4l2.V4l2Buffer) {
+	used := []uint32{b.Bytesused}
+
+	if d.multiPlanar() {
+		used = used[:0]
+		for _, p := range b.Planes()[:len(buf.planes)] {
+			used = append(used, p.Bytesused)
+		}
+	}
+
+	for i, data := range buf.planes {
+		if used[i] == 0 || used[i] > uint32(len(data)) {
+			used[i] = uint32(len(data))
+		}
+		buf.bytesused[i] = used[i]
+	}
+}
+
+/*
+* Output devices consume queued buffers right away while streaming, the payload of
+* the last one is kept for LastFrame
+ */
+func (d *device) consume() {
+	for _, buf := range d.queue {
+		d.written = d.written[:0]
+
+		for i, data := range buf.planes {
+			d.written = append(d.written, data[:buf.bytesused[i]]...)
+		}
+
+		buf.queued = false
+		buf.sequence = d.sequence
+		d.done = append(d.done, buf)
+		d.sequence++
+	}
+
+	d.queue = nil
+}
+
+/*
+* Buffers DQBUF can return, filled capture buffers are taken from the queue
+ */
+func (d *device) dequeueable() int {
+	if d.output() {
+		return len(d.done)
+	}
+	return len(d.queue)
+}
+
+/*
+* Fills the oldest queued buffer with a synthetic frame, output devices return the oldest
+* consumed buffer. Blocks while there is none unless the file is non-blocking.
+ */
+func (d *device) dequeueBuffer(file *openFile, b *v4l2.V4l2Buffer) error {
+	if err := d.checkOwner(file); err != nil {
+		return err
+	}
+
+	if b.Type != d.bufType() || b.Memory != d.memory {
+		return syscall.EINVAL
+	}
+
+	if err := d.checkPlanes(b); err != nil {
+		return err
+	}
+
+	for d.dequeueable() == 0 {
+		if !d.streaming {
+			return syscall.EINVAL
+		}
+
+		if file.flags&syscall.O_NONBLOCK > 0 {
+			return syscall.EAGAIN
+		}
+
+		changed := d.changed
+		d.mutex.Unlock()
+		<-changed
+		d.mutex.Lock()
+	}
+
+	if !d.streaming {
+		return syscall.EINVAL
+	}
+
+	if d.output() {
+		buf := d.done[0]
+		d.done = d.done[1:]
+
+		d.fillBuffer(buf, b)
+		b.Index = buf.index
+		b.Flags |= v4l2.V4L2_BUF_FLAG_DONE
+		b.Field = v4l2.V4L2_FIELD_NONE
+		b.Sequence = buf.sequence
+		return nil
+	}
+
+	buf := d.queue[0]
+	d.queue = d.queue[1:]
+	buf.queued = false
+
+	for i, data := range buf.planes {
+		buf.bytesused[i] = renderFrame(d.format, d.sequence, d.jpegQuality(), data)
+	}
+
+	d.fillBuffer(buf, b)
+	b.Index = buf.index
+	b.Flags |= v4l2.V4L2_BUF_FLAG_DONE
+	b.Field = v4l2.V4L2_FIELD_NONE
+	b.Sequence = d.sequence
+	stamp := monotonicNow()
+	b.SetTimestamp(int64(stamp/time.Second), int64(stamp%time.Second/time.Microsecond))
+
+	d.frameSync(d.sequence)
+	d.sequence++
+	return nil
+}
+
+/*
+* Takes the memory of USERPTR and DMABUF buffers from the application, it has to hold
+* a whole image
+ */
+func (d *device) attachMemory(buf *buffer, b *v4l2.V4l2Buffer) error {
+	switch d.memory {
+	case v4l2.V4L2_MEMORY_USERPTR:
+		if b.Userptr() == 0 || b.Length < d.format.Sizeimage {
+			return syscall.EINVAL
+		}
+
+		buf.userptr = b.Userptr()
+		buf.planes[0] = userMemory(b.Userptr(), b.Length)
+
+	case v4l2.V4L2_MEMORY_DMABUF:
+		data, ok := d.driver.dmabuf(uintptr(b.Fd()))
+
+		if !ok || uint32(len(data)) < d.format.Sizeimage {
+			return syscall.EINVAL
+		}
+
+		buf.fd = b.Fd()
+		buf.planes[0] = data
+	}
+
+	return nil
+}
+
+/*
+* Memory of the application at a USERPTR address, the driver writes to it like the
+* kernel does after pinning the pages
+ */
+func userMemory(userptr uintptr, length uint32) []byte {
+	p := *(*unsafe.Pointer)(unsafe.Pointer(&userptr))
+	return unsafe.Slice((*byte)(p), length)
+}
+
+/*
+* Exports a plane of an MMAP buffer, the DMABUF shares its memory
+ */
+func (d *device) exportBuffer(export *v4l2.V4l2ExportBuffer) error {
+	if export.Type != d.bufType() || export.Index >= uint32(len(d.buffers)) || d.memory != v4l2.V4L2_MEMORY_MMAP {
+		return syscall.EINVAL
+	}
+
+	buf := d.buffers[export.Index]
+
+	if export.Plane >= uint32(len(buf.planes)) {
+		return syscall.EINVAL
+	}
+
+	export.Fd = int32(d.driver.export(buf.planes[export.Plane]))
+	return nil
+}
+
+func (d *device) streamOn(file *openFile, bufType uint32) error {
+	if bufType != d.bufType() || len(d.buffers) == 0 {
+		return syscall.EINVAL
+	}
+
+	if err := d.checkOwner(file); err != nil {
+		return err
+	}
+
+	if !d.streaming {
+		d.streaming = true
+		d.sequence = 0
+
+		if d.output() {
+			d.consume()
+		}
+
+		d.notify()
+	}
+
+	return nil
+}
+
+/*
+* Stops streaming and returns all buffers to the dequeued state
+ */
+func (d *device) streamOff(file *openFile, bufType uint32) error {
+	if bufType != d.bufType() {
+		return syscall.EINVAL
+	}
+
+	if err := d.checkOwner(file); err != nil {
+		return err
+	}
+
+	d.stopStreaming()
+	return nil
+}
+
+func (d *device) stopStreaming() {
+	d.streaming = false
+
+	for _, b := range d.queue {
+		b.queued = false
+	}
+
+	d.queue = nil
+	d.done = nil
+	d.notify()
+}
+
+func (d *device) mmap(offset int64, length int) (*buffer, []byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	index := offset / pageSize / v4l2.VIDEO_MAX_PLANES
+	plane := offset / pageSize % v4l2.VIDEO_MAX_PLANES
+
+	if d.memory != v4l2.V4L2_MEMORY_MMAP || offset%pageSize != 0 || index >= int64(len(d.buffers)) || length <= 0 {
+		return nil, nil, syscall.EINVAL
+	}
+
+	buf := d.buffers[index]
+
+	if plane >= int64(len(buf.planes)) || length > len(buf.planes[plane]) {
+		return nil, nil, syscall.EINVAL
+	}
+
+	buf.mapped++
+	return buf, buf.planes[plane][:length:length], nil
+}
+
+func (d *device) munmap(buf *buffer) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	buf.mapped--
+}
+
+/*
+* Ends the subscriptions of the file and releases the queue when its owner closes the
+* device, mapped memory stays valid until it is unmapped
+ */
+func (d *device) release(file *openFile) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.subscribers, file)
+
+	if d.owner != file {
+		return
+	}
+
+	d.stopStreaming()
+	d.buffers = nil
+	d.reading = false
+	d.owner = nil
+}
+
+/*
+* Read I/O, every read copies one synthetic frame. The first read makes the file
+* the owner of the queue, streaming I/O is busy until the file is closed.
+ */
+func (d *device) read(file *openFile, data []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.config.Capabilities&v4l2.V4L2_CAP_READWRITE == 0 {
+		return 0, syscall.EINVAL
+	}
+
+	if err := d.checkOwner(file); err != nil {
+		return 0, err
+	}
+
+	if len(d.buffers) > 0 {
+		return 0, syscall.EBUSY
+	}
+
+	d.owner = file
+	d.reading = true
+
+	length := renderFrame(d.format, d.sequence, d.jpegQuality(), data)
+	d.frameSync(d.sequence)
+	d.sequence++
+
+	return int(length), nil
+}
+
+/*
+* Files with pending events have an exceptional condition. Capture devices are readable
+* while a queued buffer can be filled, output devices are writable while a consumed
+* buffer can be dequeued. Polling a queue which does not stream reports an error. Read
+* I/O always has a frame ready.
+ */
+func (d *device) poll(ctx context.Context, file *openFile, events int16) (int16, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for {
+		var revents int16
+
+		if events&ioctl.POLLPRI > 0 && d.pendingEvents(file) > 0 {
+			revents |= ioctl.POLLPRI
+		}
+
+		if events&(ioctl.POLLIN|ioctl.POLLOUT) > 0 {
+			revents |= d.pollQueue(file, events)
+		}
+
+		if revents != 0 {
+			return revents, nil
+		}
+
+		changed := d.changed
+		d.mutex.Unlock()
+
+		select {
+		case <-changed:
+			d.mutex.Lock()
+		case <-ctx.Done():
+			d.mutex.Lock()
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (d *device) pollQueue(file *openFile, events int16) int16 {
+	if d.config.Capabilities&v4l2.V4L2_CAP_READWRITE > 0 && len(d.buffers) == 0 && d.checkOwner(file) == nil && events&ioctl.POLLIN > 0 {
+		return ioctl.POLLIN
+	}
+
+	if !d.streaming {
+		return ioctl.POLLERR
+	}
+
+	if d.dequeueable() > 0 {
+		if d.output() && events&ioctl.POLLOUT > 0 {
+			return ioctl.POLLOUT
+		}
+
+		if !d.output() && events&ioctl.POLLIN > 0 {
+			return ioctl.POLLIN
+		}
+	}
+
+	return 0
+}
+
+//--------------------------------------------------------------------------------------------------
+//CONTROLS
+//--------------------------------------------------------------------------------------------------
+
+func (d *device) findControl(id uint32) (Control, bool) {
+	for _, c := range d.config.Controls {
+		if c.Id == id {
+			return c, true
+		}
+	}
+	return Control{}, false
+}
+
+func (d *device) queryControl(query *v4l2.V4l2Queryctrl) error {
+	var control Control
+	found := false
+
+	if query.Id&v4l2.V4L2_CTRL_FLAG_NEXT_CTRL > 0 {
+		id := query.Id &^ (v4l2.V4L2_CTRL_FLAG_NEXT_CTRL | v4l2.V4L2_CTRL_FLAG_NEXT_COMPOUND)
+
+		/* controls are sorted by id */
+		for _, c := range d.config.Controls {
+			if c.Id > id {
+				control, found = c, true
+				break
+			}
+		}
+	} else {
+		control, found = d.findControl(query.Id)
+	}
+
+	if !found {
+		return syscall.EINVAL
+	}
+
+	*query = v4l2.V4l2Queryctrl{
+		Id:           control.Id,
+		Type:         control.Type,
+		Minimum:      control.Minimum,
+		Maximum:      control.Maximum,
+		Step:         control.Step,
+		DefaultValue: control.Default,
+		Flags:        control.Flags,
+	}
+	copy(query.Name[:len(query.Name)-1], control.Name)
+	return nil
+}
+
+func (d *device) queryMenu(menu *v4l2.V4l2Querymenu) error {
+	control, ok := d.findControl(menu.Id)
+
+	if !ok || control.Type != v4l2.V4L2_CTRL_TYPE_MENU {
+		return syscall.EINVAL
+	}
+
+	item := int64(menu.Index) - int64(control.Minimum)
+
+	if int32(menu.Index) < control.Minimum || int32(menu.Index) > control.Maximum || item >= int64(len(control.Menu)) {
+		return syscall.EINVAL
+	}
+
+	var name [32]uint8
+	copy(name[:len(name)-1], control.Menu[item])
+	menu.SetName(name)
+	return nil
+}
+
+/*
+* Integer values are clamped to the range and rounded to the step, menu values out of range fail
+ */
+func (d *device) validate(control Control, value int64) (int64, error) {
+	if control.Flags&v4l2.V4L2_CTRL_FLAG_READ_ONLY > 0 {
+		return 0, syscall.EACCES
+	}
+
+	switch control.Type {
+	case v4l2.V4L2_CTRL_TYPE_MENU, v4l2.V4L2_CTRL_TYPE_INTEGER_MENU:
+		if value < int64(control.Minimum) || value > int64(control.Maximum) {
+			return 0, syscall.ERANGE
+		}
+	case v4l2.V4L2_CTRL_TYPE_BUTTON:
+		return 0, nil
+	default:
+		if value < int64(control.Minimum) {
+			value = int64(control.Minimum)
+		}
+		if value > int64(control.Maximum) {
+			value = int64(control.Maximum)
+		}
+		if control.Step > 1 {
+			value -= (value - int64(control.Minimum)) % int64(control.Step)
+		}
+	}
+
+	return value, nil
+}
+
+func (d *device) getControl(ctrl *v4l2.V4l2Control) error {
+	if _, ok := d.findControl(ctrl.Id); !ok {
+		return syscall.EINVAL
+	}
+
+	ctrl.Value = int32(d.values[ctrl.Id])
+	return nil
+}
+
+func (d *device) setControl(file *openFile, ctrl *v4l2.V4l2Control) error {
+	control, ok := d.findControl(ctrl.Id)
+
+	if !ok {
+		return syscall.EINVAL
+	}
+
+	value, err := d.validate(control, int64(ctrl.Value))
+
+	if err != nil {
+		return err
+	}
+
+	d.changeControl(file, control, value)
+	ctrl.Value = int32(value)
+	return nil
+}
+
+/*
+* Handles G/S/TRY_EXT_CTRLS, setting is all or nothing and ErrorIdx points to the failed control
+ */
+func (d *device) extControls(file *openFile, ctrls *v4l2.V4l2ExtControls, request uintptr) error {
+	if ctrls.Count == 0 {
+		return nil
+	}
+
+	list := unsafe.Slice(ctrls.Controls, ctrls.Count)
+	values := make([]int64, len(list))
+
+	for i := range list {
+		control, ok := d.findControl(list[i].Id)
+
+		if !ok {
+			ctrls.ErrorIdx = uint32(i)
+			return syscall.EINVAL
+		}
+
+		if request == ioctl.VIDIOC_G_EXT_CTRLS {
+			if ctrls.Which == v4l2.V4L2_CTRL_WHICH_DEF_VAL {
+				values[i] = int64(control.Default)
+			} else {
+				values[i] = d.values[control.Id]
+			}
+			continue
+		}
+
+		value := list[i].Value64()
+
+		if control.Type != v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+			value = int64(list[i].Value())
+		}
+
+		value, err := d.validate(control, value)
+
+		if err != nil {
+			ctrls.ErrorIdx = uint32(i)
+			return err
+		}
+
+		values[i] = value
+	}
+
+	for i := range list {
+		control, _ := d.findControl(list[i].Id)
+
+		if request == ioctl.VIDIOC_S_EXT_CTRLS {
+			d.changeControl(file, control, values[i])
+		}
+
+		if control.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+			list[i].SetValue64(values[i])
+		} else {
+			list[i].SetValue(int32(values[i]))
+		}
+	}
+
+	return nil
+}
+
+/*
+* Copy of the payload of the last frame written to an output device
+ */
+func (d *device) lastFrame() []byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([]byte(nil), d.written...)
+}
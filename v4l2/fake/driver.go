@@ -0,0 +1,309 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"syscall"
+	"unsafe"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/*
+* In-memory V4L2 driver implementing ioctl.Backend. Devices are registered under
+* a path and opened like real device files:
+*
+*	driver := fake.NewDriver()
+*	driver.AddDevice("/dev/video0", fake.DefaultConfig())
+*	ioctl.SetBackend(driver)
+*
+* Frames are produced as soon as the application dequeues a buffer, there is no
+* frame rate pacing.
+ */
+type Driver struct {
+	mutex   sync.Mutex
+	devices map[string]*device
+	files   map[uintptr]*openFile
+	/* memory of exported DMABUFs by their descriptors */
+	dmabufs map[uintptr][]byte
+	/* mapped device buffers, nil stands for a mapped DMABUF */
+	mappings map[*byte][]*buffer
+	nextFd   uintptr
+}
+
+type openFile struct {
+	device *device
+	flags  int
+}
+
+/* file descriptors of the fake driver do not collide with small real ones */
+const firstFd = 1000
+
+func NewDriver() *Driver {
+	return &Driver{
+		devices:  make(map[string]*device),
+		files:    make(map[uintptr]*openFile),
+		dmabufs:  make(map[uintptr][]byte),
+		mappings: make(map[*byte][]*buffer),
+		nextFd:   firstFd,
+	}
+}
+
+func (d *Driver) AddDevice(path string, config Config) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	controls := append([]Control(nil), config.Controls...)
+	sort.Slice(controls, func(i, j int) bool { return controls[i].Id < controls[j].Id })
+	config.Controls = controls
+
+	d.devices[path] = newDevice(d, config)
+}
+
+func (d *Driver) Open(path string, flags int) (uintptr, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	dev, ok := d.devices[path]
+
+	if !ok {
+		return 0, syscall.ENOENT
+	}
+
+	fd := d.nextFd
+	d.nextFd++
+	d.files[fd] = &openFile{dev, flags}
+
+	return fd, nil
+}
+
+func (d *Driver) Close(fd uintptr) error {
+	d.mutex.Lock()
+	file, ok := d.files[fd]
+	_, exported := d.dmabufs[fd]
+	delete(d.files, fd)
+	delete(d.dmabufs, fd)
+	d.mutex.Unlock()
+
+	if exported {
+		return nil
+	}
+
+	if !ok {
+		return syscall.EBADF
+	}
+
+	file.device.release(file)
+	return nil
+}
+
+func (d *Driver) file(fd uintptr) (*openFile, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	file, ok := d.files[fd]
+
+	if !ok {
+		return nil, syscall.EBADF
+	}
+
+	return file, nil
+}
+
+func (d *Driver) Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	file, err := d.file(fd)
+
+	if err != nil {
+		return err
+	}
+
+	return file.device.ioctl(file, request, arg)
+}
+
+func (d *Driver) Mmap(fd uintptr, offset int64, length int) ([]byte, error) {
+	if data, ok := d.dmabuf(fd); ok {
+		if offset < 0 || length <= 0 || offset+int64(length) > int64(len(data)) {
+			return nil, syscall.EINVAL
+		}
+
+		data = data[offset : offset+int64(length) : offset+int64(length)]
+
+		d.mutex.Lock()
+		d.mappings[&data[0]] = append(d.mappings[&data[0]], nil)
+		d.mutex.Unlock()
+
+		return data, nil
+	}
+
+	file, err := d.file(fd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf, data, err := file.device.mmap(offset, length)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	d.mappings[&data[0]] = append(d.mappings[&data[0]], buf)
+	d.mutex.Unlock()
+
+	return data, nil
+}
+
+func (d *Driver) Munmap(data []byte) error {
+	if len(data) == 0 {
+		return syscall.EINVAL
+	}
+
+	d.mutex.Lock()
+	mapped := d.mappings[&data[0]]
+
+	if len(mapped) == 0 {
+		d.mutex.Unlock()
+		return syscall.EINVAL
+	}
+
+	/* exported buffers can be mapped through the device and the DMABUF at the same address, device mappings go first */
+	i := len(mapped) - 1
+	for i > 0 && mapped[i] == nil {
+		i--
+	}
+
+	buf := mapped[i]
+	mapped = append(mapped[:i:i], mapped[i+1:]...)
+
+	if len(mapped) == 0 {
+		delete(d.mappings, &data[0])
+	} else {
+		d.mappings[&data[0]] = mapped
+	}
+
+	d.mutex.Unlock()
+
+	if buf != nil {
+		buf.device.munmap(buf)
+	}
+
+	return nil
+}
+
+/*
+* Registers memory of a device buffer as DMABUF, it stays valid until the descriptor is closed
+ */
+func (d *Driver) export(data []byte) uintptr {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	fd := d.nextFd
+	d.nextFd++
+	d.dmabufs[fd] = data
+
+	return fd
+}
+
+func (d *Driver) dmabuf(fd uintptr) ([]byte, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	data, ok := d.dmabufs[fd]
+	return data, ok
+}
+
+/*
+* Payload of the last frame written to the output device at the path, nil before the
+* first one. Frames of multi-planar formats are concatenated.
+ */
+func (d *Driver) LastFrame(path string) []byte {
+	d.mutex.Lock()
+	dev, ok := d.devices[path]
+	d.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return dev.lastFrame()
+}
+
+/*
+* Queues the event for the files of the device at the path which subscribed it, like
+* a source change or the end of a stream would
+ */
+func (d *Driver) RaiseEvent(path string, event v4l2.V4l2Event) error {
+	d.mutex.Lock()
+	dev, ok := d.devices[path]
+	d.mutex.Unlock()
+
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	dev.raiseEvent(nil, event)
+	return nil
+}
+
+/*
+* Changes the V4L2_IN_ST_* status of an input of the device at the path, like plugging
+* a cable in or out would
+ */
+func (d *Driver) SetInputStatus(path string, index uint32, status uint32) error {
+	d.mutex.Lock()
+	dev, ok := d.devices[path]
+	d.mutex.Unlock()
+
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	return dev.setInputStatus(index, status)
+}
+
+/*
+* Connects a source with other timings to an input of the device at the path, zero timings
+* unplug it. Subscribers learn about the change by V4L2_EVENT_SOURCE_CHANGE.
+ */
+func (d *Driver) SetSourceTimings(path string, index uint32, timings v4l2.V4l2BtTimings) error {
+	d.mutex.Lock()
+	dev, ok := d.devices[path]
+	d.mutex.Unlock()
+
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	return dev.setSourceTimings(index, timings)
+}
+
+func (d *Driver) Read(fd uintptr, data []byte) (int, error) {
+	file, err := d.file(fd)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return file.device.read(file, data)
+}
+
+func (d *Driver) Poll(ctx context.Context, fd uintptr, events int16) (int16, error) {
+	file, err := d.file(fd)
+
+	if err != nil {
+		return ioctl.POLLNVAL, nil
+	}
+
+	return file.device.poll(ctx, file, events)
+}
@@ -0,0 +1,198 @@
+package fake
+
+import (
+	"syscall"
+	"time"
+	"v4l2"
+)
+
+/* events kept pending per file, the oldest ones are dropped beyond it like in v4l2-event */
+const maxEvents = 16
+
+type eventKey struct {
+	eventType uint32
+	id        uint32
+}
+
+/*
+* Subscriptions and pending events of an open file
+ */
+type subscriber struct {
+	/* flags of the subscriptions, V4L2_EVENT_SUB_FL_* */
+	subscriptions map[eventKey]uint32
+	pending       []v4l2.V4l2Event
+	sequence      uint32
+}
+
+/*
+* Control events can be subscribed for every control, other events only if the
+* configuration lists them
+ */
+func (d *device) supportsEvent(eventType uint32, id uint32) bool {
+	if eventType == v4l2.V4L2_EVENT_CTRL {
+		_, ok := d.findControl(id)
+		return ok
+	}
+
+	for _, t := range d.config.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *device) subscribeEvent(file *openFile, subscription *v4l2.V4l2EventSubscription) error {
+	if subscription.Type == v4l2.V4L2_EVENT_ALL || !d.supportsEvent(subscription.Type, subscription.Id) {
+		return syscall.EINVAL
+	}
+
+	s, ok := d.subscribers[file]
+
+	if !ok {
+		s = &subscriber{subscriptions: make(map[eventKey]uint32)}
+		d.subscribers[file] = s
+	}
+
+	key := eventKey{subscription.Type, subscription.Id}
+	_, subscribed := s.subscriptions[key]
+	s.subscriptions[key] = subscription.Flags
+
+	if !subscribed && subscription.Type == v4l2.V4L2_EVENT_CTRL && subscription.Flags&v4l2.V4L2_EVENT_SUB_FL_SEND_INITIAL > 0 {
+		control, _ := d.findControl(subscription.Id)
+		d.queueEvent(s, d.controlEvent(control, v4l2.V4L2_EVENT_CTRL_CH_VALUE|v4l2.V4L2_EVENT_CTRL_CH_FLAGS))
+		d.notify()
+	}
+
+	return nil
+}
+
+/*
+* V4L2_EVENT_ALL ends all subscriptions of the file, unknown subscriptions are ignored
+ */
+func (d *device) unsubscribeEvent(file *openFile, subscription *v4l2.V4l2EventSubscription) error {
+	s, ok := d.subscribers[file]
+
+	if !ok {
+		return nil
+	}
+
+	if subscription.Type == v4l2.V4L2_EVENT_ALL {
+		delete(d.subscribers, file)
+		return nil
+	}
+
+	delete(s.subscriptions, eventKey{subscription.Type, subscription.Id})
+	return nil
+}
+
+/*
+* Returns the oldest pending event, ENOENT if there is none and the file is non-blocking
+ */
+func (d *device) dequeueEvent(file *openFile, event *v4l2.V4l2Event) error {
+	for d.pendingEvents(file) == 0 {
+		s, ok := d.subscribers[file]
+
+		/* nothing would ever wake a blocking call without subscriptions */
+		if !ok || len(s.subscriptions) == 0 || file.flags&syscall.O_NONBLOCK > 0 {
+			return syscall.ENOENT
+		}
+
+		changed := d.changed
+		d.mutex.Unlock()
+		<-changed
+		d.mutex.Lock()
+	}
+
+	s := d.subscribers[file]
+	*event = s.pending[0]
+	s.pending = s.pending[1:]
+	event.Pending = uint32(len(s.pending))
+	return nil
+}
+
+func (d *device) pendingEvents(file *openFile) int {
+	if s, ok := d.subscribers[file]; ok {
+		return len(s.pending)
+	}
+	return 0
+}
+
+func (d *device) queueEvent(s *subscriber, event v4l2.V4l2Event) {
+	stamp := monotonicNow()
+	event.Sequence = s.sequence
+	event.SetTimestamp(int64(stamp/time.Second), int64(stamp%time.Second))
+	s.sequence++
+
+	if len(s.pending) == maxEvents {
+		s.pending = s.pending[1:]
+	}
+
+	s.pending = append(s.pending, event)
+}
+
+/*
+* Queues the event for all files which subscribed it. Control events caused by a file
+* reach the file itself only if it allowed feedback.
+ */
+func (d *device) raiseEvent(source *openFile, event v4l2.V4l2Event) {
+	raised := false
+
+	for file, s := range d.subscribers {
+		flags, ok := s.subscriptions[eventKey{event.Type, event.Id}]
+
+		if !ok {
+			continue
+		}
+
+		if file == source && event.Type == v4l2.V4L2_EVENT_CTRL && flags&v4l2.V4L2_EVENT_SUB_FL_ALLOW_FEEDBACK == 0 {
+			continue
+		}
+
+		d.queueEvent(s, event)
+		raised = true
+	}
+
+	if raised {
+		d.notify()
+	}
+}
+
+func (d *device) controlEvent(control Control, changes uint32) v4l2.V4l2Event {
+	event := v4l2.V4l2Event{Type: v4l2.V4L2_EVENT_CTRL, Id: control.Id}
+
+	ctrl := event.Ctrl()
+	ctrl.Changes = changes
+	ctrl.Type = control.Type
+	ctrl.Flags = control.Flags
+	ctrl.Minimum = control.Minimum
+	ctrl.Maximum = control.Maximum
+	ctrl.Step = control.Step
+	ctrl.DefaultValue = control.Default
+
+	if control.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+		ctrl.SetValue64(d.values[control.Id])
+	} else {
+		ctrl.SetValue(int32(d.values[control.Id]))
+	}
+
+	return event
+}
+
+/*
+* Stores a new control value set through the file, subscribers learn about changes
+ */
+func (d *device) changeControl(file *openFile, control Control, value int64) {
+	if d.values[control.Id] == value {
+		return
+	}
+
+	d.values[control.Id] = value
+	d.raiseEvent(file, d.controlEvent(control, v4l2.V4L2_EVENT_CTRL_CH_VALUE))
+}
+
+func (d *device) frameSync(sequence uint32) {
+	event := v4l2.V4l2Event{Type: v4l2.V4L2_EVENT_FRAME_SYNC}
+	event.FrameSync().FrameSequence = sequence
+	d.raiseEvent(nil, event)
+}
@@ -0,0 +1,61 @@
+package fake
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+	"v4l2"
+)
+
+const clockMonotonic = 1
+
+/*
+* Writes a synthetic frame into data and returns its length. Compressed frames are
+* a JPEG skeleton with start and end markers growing with the quality, raw frames
+* a pattern shifted by the sequence number over the whole image.
+ */
+func renderFrame(format v4l2.V4l2PixFormat, sequence uint32, quality int64, data []byte) uint32 {
+
+	if format.Pixelformat == v4l2.V4L2_PIX_FMT_MJPEG || format.Pixelformat == v4l2.V4L2_PIX_FMT_JPEG {
+		length := len(data) / 8
+
+		/* quality 80 compresses to the eighth of the buffer like devices without the setting */
+		if quality > 0 {
+			length = int(int64(len(data)) * quality / 640)
+		}
+
+		if length < 4 {
+			length = len(data)
+		}
+
+		if length < 4 {
+			return 0
+		}
+
+		for i := 2; i < length-2; i++ {
+			data[i] = byte(uint32(i) + sequence)
+		}
+
+		data[0], data[1] = 0xff, 0xd8
+		data[length-2], data[length-1] = 0xff, 0xd9
+		return uint32(length)
+	}
+
+	length := len(data)
+
+	if int(format.Sizeimage) < length {
+		length = int(format.Sizeimage)
+	}
+
+	for i := 0; i < length; i++ {
+		data[i] = byte(uint32(i) + sequence)
+	}
+
+	return uint32(length)
+}
+
+func monotonicNow() time.Duration {
+	var ts syscall.Timespec
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Duration(ts.Nano())
+}
@@ -0,0 +1,346 @@
+package fake
+
+import (
+	"syscall"
+	"v4l2"
+)
+
+/*
+* Standards devices enumerate if the current input supports any of their bits
+ */
+var standards = []struct {
+	id     uint64
+	name   string
+	period v4l2.V4l2Fract
+	lines  uint32
+}{
+	{v4l2.V4L2_STD_NTSC, "NTSC", v4l2.V4l2Fract{Numerator: 1001, Denominator: 30000}, 525},
+	{v4l2.V4L2_STD_NTSC_443, "NTSC-443", v4l2.V4l2Fract{Numerator: 1001, Denominator: 30000}, 525},
+	{v4l2.V4L2_STD_PAL, "PAL", v4l2.V4l2Fract{Numerator: 1, Denominator: 25}, 625},
+	{v4l2.V4L2_STD_PAL_M, "PAL-M", v4l2.V4l2Fract{Numerator: 1001, Denominator: 30000}, 525},
+	{v4l2.V4L2_STD_PAL_N | v4l2.V4L2_STD_PAL_Nc, "PAL-N", v4l2.V4l2Fract{Numerator: 1, Denominator: 25}, 625},
+	{v4l2.V4L2_STD_SECAM, "SECAM", v4l2.V4l2Fract{Numerator: 1, Denominator: 25}, 625},
+}
+
+func (d *device) enumInput(input *v4l2.V4l2Input) error {
+	if len(d.inputs) == 0 {
+		return syscall.ENOTTY
+	}
+
+	if input.Index >= uint32(len(d.inputs)) {
+		return syscall.EINVAL
+	}
+
+	in := d.inputs[input.Index]
+
+	*input = v4l2.V4l2Input{
+		Index:        input.Index,
+		Type:         in.Type,
+		Std:          in.Std,
+		Status:       in.Status,
+		Capabilities: in.Capabilities,
+	}
+	copy(input.Name[:len(input.Name)-1], in.Name)
+	return nil
+}
+
+func (d *device) getInput(index *int32) error {
+	if len(d.inputs) == 0 {
+		return syscall.ENOTTY
+	}
+
+	*index = int32(d.input)
+	return nil
+}
+
+/*
+* Switching inputs is refused while buffers are allocated, like drivers built on videobuf2 do
+ */
+func (d *device) setInput(index *int32) error {
+	if len(d.inputs) == 0 {
+		return syscall.ENOTTY
+	}
+
+	if *index < 0 || int(*index) >= len(d.inputs) {
+		return syscall.EINVAL
+	}
+
+	if uint32(*index) == d.input {
+		return nil
+	}
+
+	if len(d.buffers) > 0 || d.reading {
+		return syscall.EBUSY
+	}
+
+	d.input = uint32(*index)
+	d.std = 0
+	d.timings = v4l2.V4l2BtTimings{}
+
+	if std := d.inputs[d.input].Std; std != 0 {
+		d.applyStandard(std)
+	}
+
+	if d.inputs[d.input].Capabilities&v4l2.V4L2_IN_CAP_DV_TIMINGS > 0 && len(d.config.DVTimings) > 0 {
+		d.applyTimings(d.config.DVTimings[0])
+	}
+
+	return nil
+}
+
+func (d *device) setInputStatus(index uint32, status uint32) error {
+	if index >= uint32(len(d.inputs)) {
+		return syscall.EINVAL
+	}
+
+	d.inputs[index].Status = status
+	return nil
+}
+
+/*
+* Standard ioctls fail with ENODATA on inputs without standards
+ */
+func (d *device) currentStandards() (uint64, error) {
+	if len(d.inputs) == 0 {
+		return 0, syscall.ENOTTY
+	}
+
+	std := d.inputs[d.input].Std
+
+	if std == 0 || d.inputs[d.input].Capabilities&v4l2.V4L2_IN_CAP_STD == 0 {
+		return 0, syscall.ENODATA
+	}
+
+	return std, nil
+}
+
+func (d *device) enumStandard(standard *v4l2.V4l2Standard) error {
+	supported, err := d.currentStandards()
+
+	if err != nil {
+		return err
+	}
+
+	index := uint32(0)
+
+	for _, s := range standards {
+		if s.id&supported == 0 {
+			continue
+		}
+
+		if index == standard.Index {
+			*standard = v4l2.V4l2Standard{Index: index, Id: s.id & supported, Frameperiod: s.period, Framelines: s.lines}
+			copy(standard.Name[:len(standard.Name)-1], s.name)
+			return nil
+		}
+
+		index++
+	}
+
+	return syscall.EINVAL
+}
+
+func (d *device) getStandard(id *uint64) error {
+	if _, err := d.currentStandards(); err != nil {
+		return err
+	}
+
+	*id = d.std
+	return nil
+}
+
+func (d *device) setStandard(id *uint64) error {
+	supported, err := d.currentStandards()
+
+	if err != nil {
+		return err
+	}
+
+	if *id&supported == 0 {
+		return syscall.EINVAL
+	}
+
+	if len(d.buffers) > 0 || d.reading {
+		return syscall.EBUSY
+	}
+
+	d.applyStandard(*id & supported)
+	return nil
+}
+
+/*
+* Reports the standards of the signal at the input, V4L2_STD_UNKNOWN if nothing is connected
+ */
+func (d *device) queryStandard(id *uint64) error {
+	supported, err := d.currentStandards()
+
+	if err != nil {
+		return err
+	}
+
+	if len(d.buffers) > 0 || d.reading {
+		return syscall.EBUSY
+	}
+
+	input := d.inputs[d.input]
+	*id = v4l2.V4L2_STD_UNKNOWN
+
+	if input.Status&(v4l2.V4L2_IN_ST_NO_POWER|v4l2.V4L2_IN_ST_NO_SIGNAL) == 0 {
+		*id = input.Signal & supported
+	}
+
+	return nil
+}
+
+/*
+* Picks the first standard of the set like drivers do for ambiguous sets and resets
+* the format to its geometry
+ */
+func (d *device) applyStandard(id uint64) {
+	for _, s := range standards {
+		if s.id&id == 0 {
+			continue
+		}
+
+		d.std = s.id & id
+		height := uint32(576)
+
+		if s.lines == 525 {
+			height = 480
+		}
+
+		if size, ok := d.findSize(d.format.Pixelformat, 720, height); ok {
+			f, _ := d.findFormat(d.format.Pixelformat)
+			d.applyFormat(f, size)
+		}
+		return
+	}
+}
+
+/*
+* DV timings ioctls fail with ENODATA on inputs without V4L2_IN_CAP_DV_TIMINGS
+ */
+func (d *device) checkTimings() error {
+	if len(d.inputs) == 0 {
+		return syscall.ENOTTY
+	}
+
+	if d.inputs[d.input].Capabilities&v4l2.V4L2_IN_CAP_DV_TIMINGS == 0 {
+		return syscall.ENODATA
+	}
+
+	return nil
+}
+
+func (d *device) enumDvTimings(timings *v4l2.V4l2EnumDvTimings) error {
+	if err := d.checkTimings(); err != nil {
+		return err
+	}
+
+	if timings.Pad != 0 || timings.Index >= uint32(len(d.config.DVTimings)) {
+		return syscall.EINVAL
+	}
+
+	timings.Timings = v4l2.V4l2DvTimings{Type: v4l2.V4L2_DV_BT_656_1120, Bt: d.config.DVTimings[timings.Index]}
+	return nil
+}
+
+func (d *device) getDvTimings(timings *v4l2.V4l2DvTimings) error {
+	if err := d.checkTimings(); err != nil {
+		return err
+	}
+
+	*timings = v4l2.V4l2DvTimings{Type: v4l2.V4L2_DV_BT_656_1120, Bt: d.timings}
+	return nil
+}
+
+/*
+* Only the timings the receiver supports are accepted, they reset the format to their size
+ */
+func (d *device) setDvTimings(timings *v4l2.V4l2DvTimings) error {
+	if err := d.checkTimings(); err != nil {
+		return err
+	}
+
+	supported, ok := d.findTimings(timings.Bt)
+
+	if timings.Type != v4l2.V4L2_DV_BT_656_1120 || !ok {
+		return syscall.EINVAL
+	}
+
+	if _, ok := d.findSize(d.format.Pixelformat, supported.Width, supported.Height); !ok {
+		return syscall.EINVAL
+	}
+
+	if len(d.buffers) > 0 || d.reading {
+		return syscall.EBUSY
+	}
+
+	d.applyTimings(supported)
+	timings.Bt = supported
+	return nil
+}
+
+/*
+* Reports the timings of the source, ENOLINK if nothing is connected and ERANGE if the
+* receiver does not support them
+ */
+func (d *device) queryDvTimings(timings *v4l2.V4l2DvTimings) error {
+	if err := d.checkTimings(); err != nil {
+		return err
+	}
+
+	input := d.inputs[d.input]
+
+	if input.Status&(v4l2.V4L2_IN_ST_NO_POWER|v4l2.V4L2_IN_ST_NO_SIGNAL) > 0 || input.Timings.Width == 0 {
+		return syscall.ENOLINK
+	}
+
+	if _, ok := d.findTimings(input.Timings); !ok {
+		return syscall.ERANGE
+	}
+
+	*timings = v4l2.V4l2DvTimings{Type: v4l2.V4L2_DV_BT_656_1120, Bt: input.Timings}
+	return nil
+}
+
+/*
+* Timings are told apart by their active area, scan and pixel clock like v4l2_match_dv_timings does
+ */
+func (d *device) findTimings(bt v4l2.V4l2BtTimings) (v4l2.V4l2BtTimings, bool) {
+	for _, t := range d.config.DVTimings {
+		if t.Width == bt.Width && t.Height == bt.Height && t.Interlaced == bt.Interlaced && t.Pixelclock() == bt.Pixelclock() {
+			return t, true
+		}
+	}
+	return v4l2.V4l2BtTimings{}, false
+}
+
+func (d *device) applyTimings(bt v4l2.V4l2BtTimings) {
+	d.timings = bt
+
+	if size, ok := d.findSize(d.format.Pixelformat, bt.Width, bt.Height); ok {
+		f, _ := d.findFormat(d.format.Pixelformat)
+		d.applyFormat(f, size)
+	}
+}
+
+func (d *device) setSourceTimings(index uint32, timings v4l2.V4l2BtTimings) error {
+	if index >= uint32(len(d.inputs)) {
+		return syscall.EINVAL
+	}
+
+	input := &d.inputs[index]
+	input.Timings = timings
+
+	if timings.Width == 0 {
+		input.Status |= v4l2.V4L2_IN_ST_NO_SIGNAL
+	} else {
+		input.Status &^= v4l2.V4L2_IN_ST_NO_SIGNAL
+	}
+
+	event := v4l2.V4l2Event{Type: v4l2.V4L2_EVENT_SOURCE_CHANGE, Id: index}
+	event.SrcChange().Changes = v4l2.V4L2_EVENT_SRC_CH_RESOLUTION
+	d.raiseEvent(nil, event)
+	return nil
+}
@@ -0,0 +1,55 @@
+package fake
+
+import (
+	"syscall"
+	"v4l2"
+)
+
+/*
+* Devices with V4L2_CID_JPEG_COMPRESSION_QUALITY serve the legacy JPEGCOMP ioctls by it
+ */
+func (d *device) qualityControl() (Control, bool) {
+	return d.findControl(v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY)
+}
+
+/*
+* Quality compressed frames are rendered with, zero if the device has no quality setting
+ */
+func (d *device) jpegQuality() int64 {
+	if _, ok := d.qualityControl(); !ok {
+		return 0
+	}
+	return d.values[v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY]
+}
+
+func (d *device) getJpegCompression(compression *v4l2.V4l2Jpegcompression) error {
+	if _, ok := d.qualityControl(); !ok {
+		return syscall.ENOTTY
+	}
+
+	*compression = v4l2.V4l2Jpegcompression{
+		Quality:     int32(d.jpegQuality()),
+		JpegMarkers: v4l2.V4L2_JPEG_MARKER_DHT | v4l2.V4L2_JPEG_MARKER_DQT,
+	}
+	return nil
+}
+
+/*
+* Only the quality is taken, it is clamped to the range of the control
+ */
+func (d *device) setJpegCompression(file *openFile, compression *v4l2.V4l2Jpegcompression) error {
+	control, ok := d.qualityControl()
+
+	if !ok {
+		return syscall.ENOTTY
+	}
+
+	value, err := d.validate(control, int64(compression.Quality))
+
+	if err != nil {
+		return err
+	}
+
+	d.changeControl(file, control, value)
+	return nil
+}
@@ -0,0 +1,227 @@
+package ioctl
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+/*
+* Access to video devices. All ioctl wrappers and device files of this package go through
+* the current backend, which is the kernel unless replaced by SetBackend.
+* Errors are reported as syscall.Errno values, the same way the kernel reports them.
+ */
+type Backend interface {
+	Open(path string, flags int) (uintptr, error)
+	Close(fd uintptr) error
+	Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error
+	Mmap(fd uintptr, offset int64, length int) ([]byte, error)
+	Munmap(data []byte) error
+	/* read I/O, returns the number of bytes the driver copied into data */
+	Read(fd uintptr, data []byte) (int, error)
+	/* waits until one of the events is signalled, returns the signalled events or the error of the context */
+	Poll(ctx context.Context, fd uintptr, events int16) (int16, error)
+}
+
+/* poll events */
+const (
+	POLLIN   = 0x0001
+	POLLPRI  = 0x0002
+	POLLOUT  = 0x0004
+	POLLERR  = 0x0008
+	POLLHUP  = 0x0010
+	POLLNVAL = 0x0020
+)
+
+var (
+	backendMutex sync.RWMutex
+	backend      Backend = Kernel{}
+)
+
+/*
+* Replaces the backend and returns the previous one. Devices opened through the previous
+* backend have to be closed before.
+ */
+func SetBackend(b Backend) Backend {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+
+	previous := backend
+	backend = b
+	return previous
+}
+
+func currentBackend() Backend {
+	backendMutex.RLock()
+	defer backendMutex.RUnlock()
+
+	return backend
+}
+
+/*
+* Requests interrupted by a signal are repeated
+ */
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	for {
+		err := currentBackend().Ioctl(fd, request, arg)
+
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return wrap(requestName(request), pathOf(fd), err)
+		}
+
+		return nil
+	}
+}
+
+func Mmap(fd uintptr, offset int64, length int) ([]byte, error) {
+	data, err := currentBackend().Mmap(fd, offset, length)
+
+	if err != nil {
+		return nil, wrap("mmap", pathOf(fd), err)
+	}
+
+	return data, nil
+}
+
+func Munmap(data []byte) error {
+	return currentBackend().Munmap(data)
+}
+
+/*
+* Reads a frame of a device using read I/O, interrupted reads are repeated
+ */
+func Read(fd uintptr, data []byte) (int, error) {
+	for {
+		n, err := currentBackend().Read(fd, data)
+
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err != nil {
+			return 0, wrap("read", pathOf(fd), err)
+		}
+
+		return n, nil
+	}
+}
+
+func Poll(ctx context.Context, fd uintptr, events int16) (int16, error) {
+	revents, err := currentBackend().Poll(ctx, fd, events)
+
+	if err != nil {
+		return 0, wrap("poll", pathOf(fd), err)
+	}
+
+	return revents, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+//DEVICE FILE
+//--------------------------------------------------------------------------------------------------
+
+/*
+* Device file opened through the backend
+ */
+type File struct {
+	fd   uintptr
+	name string
+}
+
+func Open(path string, flags int) (*File, error) {
+	fd, err := currentBackend().Open(path, flags)
+
+	if err != nil {
+		return nil, wrap("open", path, err)
+	}
+
+	registerPath(fd, path)
+	return &File{fd, path}, nil
+}
+
+func (f *File) Fd() uintptr {
+	return f.fd
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+func (f *File) Close() error {
+	unregisterPath(f.fd)
+
+	if err := currentBackend().Close(f.fd); err != nil {
+		return wrap("close", f.name, err)
+	}
+
+	return nil
+}
+
+/*
+* Closes a descriptor which has not been opened by Open, e.g. an exported DMABUF
+ */
+func CloseFd(fd uintptr) error {
+	if err := currentBackend().Close(fd); err != nil {
+		return wrap("close", pathOf(fd), err)
+	}
+
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+//KERNEL
+//--------------------------------------------------------------------------------------------------
+
+/*
+* Backend calling the kernel directly
+ */
+type Kernel struct{}
+
+func (Kernel) Open(path string, flags int) (uintptr, error) {
+	fd, err := syscall.Open(path, flags|syscall.O_CLOEXEC, 0666)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uintptr(fd), nil
+}
+
+func (Kernel) Close(fd uintptr) error {
+	kernelPoller.forget(int32(fd))
+	return syscall.Close(int(fd))
+}
+
+func (Kernel) Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+
+	if err != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (Kernel) Mmap(fd uintptr, offset int64, length int) ([]byte, error) {
+	return syscall.Mmap(int(fd), offset, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func (Kernel) Munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+func (Kernel) Read(fd uintptr, data []byte) (int, error) {
+	return syscall.Read(int(fd), data)
+}
+
+/*
+* All devices are polled by a single epoll loop
+ */
+func (Kernel) Poll(ctx context.Context, fd uintptr, events int16) (int16, error) {
+	return kernelPoller.wait(ctx, int32(fd), events)
+}
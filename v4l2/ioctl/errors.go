@@ -0,0 +1,161 @@
+package ioctl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+)
+
+/*
+* Categories of failures, test them by errors.Is
+ */
+var (
+	/* device is used by another process or its queue is set up already */
+	ErrBusy = errors.New("device busy")
+	/* driver does not implement the operation */
+	ErrUnsupported = errors.New("operation not supported")
+	/* device has been unplugged or stopped working */
+	ErrDisconnected = errors.New("device disconnected")
+	/* driver rejected the requested format */
+	ErrInvalidFormat = errors.New("invalid format")
+	/* device did not respond in time */
+	ErrTimeout = errors.New("timeout")
+)
+
+/*
+* Failure of an operation on a device, it wraps the errno reported by the driver
+ */
+type Error struct {
+	/* name of the ioctl request or the system call */
+	Op     string
+	Device string
+	Errno  syscall.Errno
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Device, e.Errno)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Errno
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBusy:
+		return e.Errno == syscall.EBUSY
+	case ErrUnsupported:
+		return e.Errno == syscall.ENOTTY || e.Errno == syscall.EOPNOTSUPP
+	case ErrDisconnected:
+		/* videobuf2 reports EIO once the device is gone */
+		return e.Errno == syscall.ENODEV || e.Errno == syscall.ENXIO || e.Errno == syscall.EIO
+	case ErrInvalidFormat:
+		return e.Errno == syscall.EINVAL && formatRequests[e.Op]
+	case ErrTimeout:
+		return e.Errno == syscall.ETIMEDOUT
+	}
+	return false
+}
+
+var formatRequests = map[string]bool{
+	"VIDIOC_G_FMT":   true,
+	"VIDIOC_S_FMT":   true,
+	"VIDIOC_TRY_FMT": true,
+}
+
+var requestNames = map[uintptr]string{
+	VIDIOC_QUERYCAP:            "VIDIOC_QUERYCAP",
+	VIDIOC_ENUM_FMT:            "VIDIOC_ENUM_FMT",
+	VIDIOC_ENUM_FRAMESIZES:     "VIDIOC_ENUM_FRAMESIZES",
+	VIDIOC_ENUM_FRAMEINTERVALS: "VIDIOC_ENUM_FRAMEINTERVALS",
+	VIDIOC_G_FMT:               "VIDIOC_G_FMT",
+	VIDIOC_TRY_FMT:             "VIDIOC_TRY_FMT",
+	VIDIOC_S_FMT:               "VIDIOC_S_FMT",
+	VIDIOC_REQBUFS:             "VIDIOC_REQBUFS",
+	VIDIOC_QUERYBUF:            "VIDIOC_QUERYBUF",
+	VIDIOC_EXPBUF:              "VIDIOC_EXPBUF",
+	VIDIOC_STREAMON:            "VIDIOC_STREAMON",
+	VIDIOC_STREAMOFF:           "VIDIOC_STREAMOFF",
+	VIDIOC_DQBUF:               "VIDIOC_DQBUF",
+	VIDIOC_QBUF:                "VIDIOC_QBUF",
+	VIDIOC_G_PARM:              "VIDIOC_G_PARM",
+	VIDIOC_S_PARM:              "VIDIOC_S_PARM",
+	VIDIOC_G_CTRL:              "VIDIOC_G_CTRL",
+	VIDIOC_S_CTRL:              "VIDIOC_S_CTRL",
+	VIDIOC_QUERYCTRL:           "VIDIOC_QUERYCTRL",
+	VIDIOC_QUERYMENU:           "VIDIOC_QUERYMENU",
+	VIDIOC_G_EXT_CTRLS:         "VIDIOC_G_EXT_CTRLS",
+	VIDIOC_S_EXT_CTRLS:         "VIDIOC_S_EXT_CTRLS",
+	VIDIOC_TRY_EXT_CTRLS:       "VIDIOC_TRY_EXT_CTRLS",
+	VIDIOC_G_JPEGCOMP:          "VIDIOC_G_JPEGCOMP",
+	VIDIOC_S_JPEGCOMP:          "VIDIOC_S_JPEGCOMP",
+	VIDIOC_CROPCAP:             "VIDIOC_CROPCAP",
+	VIDIOC_G_CROP:              "VIDIOC_G_CROP",
+	VIDIOC_S_CROP:              "VIDIOC_S_CROP",
+	VIDIOC_G_SELECTION:         "VIDIOC_G_SELECTION",
+	VIDIOC_S_SELECTION:         "VIDIOC_S_SELECTION",
+	VIDIOC_ENUMSTD:             "VIDIOC_ENUMSTD",
+	VIDIOC_G_STD:               "VIDIOC_G_STD",
+	VIDIOC_S_STD:               "VIDIOC_S_STD",
+	VIDIOC_QUERYSTD:            "VIDIOC_QUERYSTD",
+	VIDIOC_S_DV_TIMINGS:        "VIDIOC_S_DV_TIMINGS",
+	VIDIOC_G_DV_TIMINGS:        "VIDIOC_G_DV_TIMINGS",
+	VIDIOC_ENUM_DV_TIMINGS:     "VIDIOC_ENUM_DV_TIMINGS",
+	VIDIOC_QUERY_DV_TIMINGS:    "VIDIOC_QUERY_DV_TIMINGS",
+	VIDIOC_ENUMINPUT:           "VIDIOC_ENUMINPUT",
+	VIDIOC_G_INPUT:             "VIDIOC_G_INPUT",
+	VIDIOC_S_INPUT:             "VIDIOC_S_INPUT",
+	VIDIOC_DQEVENT:             "VIDIOC_DQEVENT",
+	VIDIOC_SUBSCRIBE_EVENT:     "VIDIOC_SUBSCRIBE_EVENT",
+	VIDIOC_UNSUBSCRIBE_EVENT:   "VIDIOC_UNSUBSCRIBE_EVENT",
+}
+
+func requestName(request uintptr) string {
+	if name, ok := requestNames[request]; ok {
+		return name
+	}
+	return fmt.Sprintf("ioctl 0x%08x", request)
+}
+
+//--------------------------------------------------------------------------------------------------
+//DEVICE PATHS
+//--------------------------------------------------------------------------------------------------
+
+/* paths of the devices opened by Open, errors name the device by them */
+var (
+	pathsMutex sync.RWMutex
+	paths      = make(map[uintptr]string)
+)
+
+func registerPath(fd uintptr, path string) {
+	pathsMutex.Lock()
+	defer pathsMutex.Unlock()
+	paths[fd] = path
+}
+
+func unregisterPath(fd uintptr) {
+	pathsMutex.Lock()
+	defer pathsMutex.Unlock()
+	delete(paths, fd)
+}
+
+func pathOf(fd uintptr) string {
+	pathsMutex.RLock()
+	defer pathsMutex.RUnlock()
+
+	if path, ok := paths[fd]; ok {
+		return path
+	}
+	return fmt.Sprintf("fd %d", fd)
+}
+
+/*
+* Wraps an errno into Error, other errors are returned unchanged
+ */
+func wrap(op string, device string, err error) error {
+	if errno, ok := err.(syscall.Errno); ok {
+		return &Error{op, device, errno}
+	}
+	return err
+}
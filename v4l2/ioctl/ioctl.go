@@ -2,7 +2,6 @@ package ioctl
 
 import (
 	"errors"
-	"fmt"
 	"syscall"
 	"unsafe"
 	"v4l2"
@@ -22,23 +21,57 @@ const (
 	IOC_SIZE_SHIFT = IOC_TYPE_SHIFT + IOC_TYPE_BITS
 	IOC_DIR_SHIFT  = IOC_SIZE_SHIFT + IOC_SIZE_BITS
 
-	VIDIOC_QUERYCAP        = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (0 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Capability{})) << IOC_SIZE_SHIFT)
-	VIDIOC_ENUM_FMT        = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (2 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Fmtdesc{})) << IOC_SIZE_SHIFT)
-	VIDIOC_ENUM_FRAMESIZES = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (74 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Frmsizeenum{})) << IOC_SIZE_SHIFT)
-	VIDIOC_S_FMT           = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (5 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Format{}) << IOC_SIZE_SHIFT)
-	VIDIOC_REQBUFS         = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (8 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2RequestBuffers{})) << IOC_SIZE_SHIFT)
-	VIDIOC_QUERYBUF        = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (9 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Buffer{})) << IOC_SIZE_SHIFT)
-	VIDIOC_STREAMON        = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (18 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint32(0)) << IOC_SIZE_SHIFT)
-	VIDIOC_STREAMOFF       = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (19 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint32(0)) << IOC_SIZE_SHIFT)
-	VIDIOC_DQBUF           = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (17 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Buffer{}) << IOC_SIZE_SHIFT)
-	VIDIOC_QBUF            = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (15 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Buffer{})) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERYCAP            = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (0 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Capability{})) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUM_FMT            = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (2 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Fmtdesc{})) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUM_FRAMESIZES     = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (74 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Frmsizeenum{})) << IOC_SIZE_SHIFT)
+	VIDIOC_G_FMT               = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (4 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Format{}) << IOC_SIZE_SHIFT)
+	VIDIOC_TRY_FMT             = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (64 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Format{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_FMT               = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (5 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Format{}) << IOC_SIZE_SHIFT)
+	VIDIOC_REQBUFS             = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (8 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2RequestBuffers{})) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERYBUF            = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (9 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Buffer{})) << IOC_SIZE_SHIFT)
+	VIDIOC_EXPBUF              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (16 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2ExportBuffer{}) << IOC_SIZE_SHIFT)
+	VIDIOC_STREAMON            = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (18 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint32(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_STREAMOFF           = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (19 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint32(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_DQBUF               = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (17 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Buffer{}) << IOC_SIZE_SHIFT)
+	VIDIOC_QBUF                = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (15 << IOC_NR_SHIFT) | ((unsafe.Sizeof(v4l2.V4l2Buffer{})) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUM_FRAMEINTERVALS = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (75 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Frmivalenum{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_PARM              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (21 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Streamparm{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_PARM              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (22 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Streamparm{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_CTRL              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (27 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Control{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_CTRL              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (28 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Control{}) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERYCTRL           = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (36 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Queryctrl{}) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERYMENU           = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (37 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Querymenu{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_EXT_CTRLS         = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (71 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2ExtControls{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_EXT_CTRLS         = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (72 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2ExtControls{}) << IOC_SIZE_SHIFT)
+	VIDIOC_TRY_EXT_CTRLS       = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (73 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2ExtControls{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_JPEGCOMP          = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (61 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Jpegcompression{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_JPEGCOMP          = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (62 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Jpegcompression{}) << IOC_SIZE_SHIFT)
+	VIDIOC_CROPCAP             = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (58 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Cropcap{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_CROP              = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (59 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Crop{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_CROP              = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (60 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Crop{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_SELECTION         = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (94 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Selection{}) << IOC_SIZE_SHIFT)
+	VIDIOC_S_SELECTION         = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (95 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Selection{}) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUMSTD             = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (25 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Standard{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_STD               = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (23 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint64(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_S_STD               = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (24 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint64(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERYSTD            = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (63 << IOC_NR_SHIFT) | (unsafe.Sizeof(uint64(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_S_DV_TIMINGS        = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (87 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2DvTimings{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_DV_TIMINGS        = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (88 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2DvTimings{}) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUM_DV_TIMINGS     = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (98 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2EnumDvTimings{}) << IOC_SIZE_SHIFT)
+	VIDIOC_QUERY_DV_TIMINGS    = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (99 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2DvTimings{}) << IOC_SIZE_SHIFT)
+	VIDIOC_ENUMINPUT           = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (26 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Input{}) << IOC_SIZE_SHIFT)
+	VIDIOC_G_INPUT             = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (38 << IOC_NR_SHIFT) | (unsafe.Sizeof(int32(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_S_INPUT             = ((IOC_READ | IOC_WRITE) << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (39 << IOC_NR_SHIFT) | (unsafe.Sizeof(int32(0)) << IOC_SIZE_SHIFT)
+	VIDIOC_DQEVENT             = (IOC_READ << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (89 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2Event{}) << IOC_SIZE_SHIFT)
+	VIDIOC_SUBSCRIBE_EVENT     = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (90 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2EventSubscription{}) << IOC_SIZE_SHIFT)
+	VIDIOC_UNSUBSCRIBE_EVENT   = (IOC_WRITE << IOC_DIR_SHIFT) | (uintptr('V') << IOC_TYPE_SHIFT) | (91 << IOC_NR_SHIFT) | (unsafe.Sizeof(v4l2.V4l2EventSubscription{}) << IOC_SIZE_SHIFT)
 )
 
 func QueryCapability(fd uintptr) (v4l2.V4l2Capability, error) {
 	capability := v4l2.V4l2Capability{}
-	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_QUERYCAP, uintptr(unsafe.Pointer(&capability)))
+	err := ioctl(fd, VIDIOC_QUERYCAP, unsafe.Pointer(&capability))
 
-	if err != 0 {
+	if err != nil {
 		return capability, err
 	}
 
@@ -47,59 +80,110 @@ func QueryCapability(fd uintptr) (v4l2.V4l2Capability, error) {
 
 func QueryFormat(fd uintptr, desc *v4l2.V4l2Fmtdesc) (bool, error) {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_ENUM_FMT, uintptr(unsafe.Pointer(desc)))
+	err := ioctl(fd, VIDIOC_ENUM_FMT, unsafe.Pointer(desc))
 
-	if err != 0 {
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
 		return false, err
 	}
 
-	if r1 == 0 {
+	return true, nil
+}
+
+func QueryFrameSize(fd uintptr, str *v4l2.V4l2Frmsizeenum) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_ENUM_FRAMESIZES, unsafe.Pointer(str))
+
+	if errors.Is(err, syscall.EINVAL) {
 		return false, nil
 	}
 
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
-func QueryFrameSize(fd uintptr, str *v4l2.V4l2Frmsizeenum) (bool, error) {
+func QueryFrameInterval(fd uintptr, str *v4l2.V4l2Frmivalenum) (bool, error) {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_ENUM_FRAMESIZES, uintptr(unsafe.Pointer(str)))
+	err := ioctl(fd, VIDIOC_ENUM_FRAMEINTERVALS, unsafe.Pointer(str))
 
-	if r1 > 0 {
+	if errors.Is(err, syscall.EINVAL) {
 		return false, nil
 	}
 
-	if err != 0 {
+	if err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
+func GetStreamParameters(fd uintptr, str *v4l2.V4l2Streamparm) error {
+
+	err := ioctl(fd, VIDIOC_G_PARM, unsafe.Pointer(str))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetStreamParameters(fd uintptr, str *v4l2.V4l2Streamparm) error {
+
+	err := ioctl(fd, VIDIOC_S_PARM, unsafe.Pointer(str))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func SetFrameSize(fd uintptr, str *v4l2.V4l2Format) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_S_FMT, uintptr(unsafe.Pointer(str)))
+	err := ioctl(fd, VIDIOC_S_FMT, unsafe.Pointer(str))
 
-	if r1 > 0 {
-		return errors.New(fmt.Sprintf("Cannot set frame size, ioctl system call returned status %v", r1))
+	if err != nil {
+		return err
 	}
 
-	if err != 0 {
+	return nil
+}
+
+func GetFormat(fd uintptr, str *v4l2.V4l2Format) error {
+
+	err := ioctl(fd, VIDIOC_G_FMT, unsafe.Pointer(str))
+
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func RequestBuffer(fd uintptr, str *v4l2.V4l2RequestBuffers) error {
+func TryFormat(fd uintptr, str *v4l2.V4l2Format) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_REQBUFS, uintptr(unsafe.Pointer(str)))
+	err := ioctl(fd, VIDIOC_TRY_FMT, unsafe.Pointer(str))
 
-	if err != 0 {
+	if err != nil {
 		return err
 	}
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot request buffer, ioctl system call returned status %v", r1))
+	return nil
+}
+
+func RequestBuffer(fd uintptr, str *v4l2.V4l2RequestBuffers) error {
+
+	err := ioctl(fd, VIDIOC_REQBUFS, unsafe.Pointer(str))
+
+	if err != nil {
+		return err
 	}
 
 	return nil
@@ -107,75 +191,435 @@ func RequestBuffer(fd uintptr, str *v4l2.V4l2RequestBuffers) error {
 
 func QueryBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_QUERYBUF, uintptr(unsafe.Pointer(buffer)))
+	err := ioctl(fd, VIDIOC_QUERYBUF, unsafe.Pointer(buffer))
 
-	if err != 0 {
+	if err != nil {
 		return err
 	}
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot query buffer, ioctl system call returned status %v", r1))
+	return nil
+}
+
+func ActivateStreaming(fd uintptr, bufType uint32) error {
+
+	err := ioctl(fd, VIDIOC_STREAMON, unsafe.Pointer(&bufType))
+
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func ActivateStreaming(fd uintptr, bufType uint32) error {
+func DeactivateStreaming(fd uintptr, bufType uint32) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_STREAMON, uintptr(unsafe.Pointer(&bufType)))
+	err := ioctl(fd, VIDIOC_STREAMOFF, unsafe.Pointer(&bufType))
 
-	if err != 0 {
+	if err != nil {
 		return err
 	}
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot activate streaming, ioctl system call returned with status %d\n", r1))
+	return nil
+}
+
+func QueueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
+
+	err := ioctl(fd, VIDIOC_QBUF, unsafe.Pointer(buffer))
+
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func DeactivateStreaming(fd uintptr, bufType uint32) error {
+/*
+* Exports a buffer as DMABUF file descriptor, the driver stores it into Fd
+ */
+func ExportBuffer(fd uintptr, export *v4l2.V4l2ExportBuffer) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_STREAMOFF, uintptr(unsafe.Pointer(&bufType)))
+	err := ioctl(fd, VIDIOC_EXPBUF, unsafe.Pointer(export))
 
-	if err != 0 {
+	if err != nil {
 		return err
 	}
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot deactivate streaming, ioctl system call returned with status %d\n", r1))
+	return nil
+}
+
+/*
+* Returns false if no buffer has been filled yet, non-blocking devices report it by EAGAIN
+ */
+func DequeueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_DQBUF, unsafe.Pointer(buffer))
+
+	if errors.Is(err, syscall.EAGAIN) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func QueryControl(fd uintptr, ctrl *v4l2.V4l2Queryctrl) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_QUERYCTRL, unsafe.Pointer(ctrl))
+
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func QueryMenu(fd uintptr, menu *v4l2.V4l2Querymenu) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_QUERYMENU, unsafe.Pointer(menu))
+
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func GetControl(fd uintptr, ctrl *v4l2.V4l2Control) error {
+
+	err := ioctl(fd, VIDIOC_G_CTRL, unsafe.Pointer(ctrl))
+
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func QueueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
+func SetControl(fd uintptr, ctrl *v4l2.V4l2Control) error {
+
+	err := ioctl(fd, VIDIOC_S_CTRL, unsafe.Pointer(ctrl))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetExtControls(fd uintptr, ctrls *v4l2.V4l2ExtControls) error {
+
+	err := ioctl(fd, VIDIOC_G_EXT_CTRLS, unsafe.Pointer(ctrls))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetExtControls(fd uintptr, ctrls *v4l2.V4l2ExtControls) error {
+
+	err := ioctl(fd, VIDIOC_S_EXT_CTRLS, unsafe.Pointer(ctrls))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func TryExtControls(fd uintptr, ctrls *v4l2.V4l2ExtControls) error {
+
+	err := ioctl(fd, VIDIOC_TRY_EXT_CTRLS, unsafe.Pointer(ctrls))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetJpegCompression(fd uintptr, compression *v4l2.V4l2Jpegcompression) error {
+
+	err := ioctl(fd, VIDIOC_G_JPEGCOMP, unsafe.Pointer(compression))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetJpegCompression(fd uintptr, compression *v4l2.V4l2Jpegcompression) error {
+
+	err := ioctl(fd, VIDIOC_S_JPEGCOMP, unsafe.Pointer(compression))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func QueryCropCapability(fd uintptr, cropcap *v4l2.V4l2Cropcap) error {
+
+	err := ioctl(fd, VIDIOC_CROPCAP, unsafe.Pointer(cropcap))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetCrop(fd uintptr, crop *v4l2.V4l2Crop) error {
+
+	err := ioctl(fd, VIDIOC_G_CROP, unsafe.Pointer(crop))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetCrop(fd uintptr, crop *v4l2.V4l2Crop) error {
+
+	err := ioctl(fd, VIDIOC_S_CROP, unsafe.Pointer(crop))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetSelection(fd uintptr, selection *v4l2.V4l2Selection) error {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_QBUF, uintptr(unsafe.Pointer(buffer)))
+	err := ioctl(fd, VIDIOC_G_SELECTION, unsafe.Pointer(selection))
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot queue buffer, ioctl system call returned with status %d\n", r1))
+	if err != nil {
+		return err
 	}
 
-	if err != 0 {
+	return nil
+}
+
+/*
+* The driver adjusts the rectangle to what the hardware supports and returns it
+ */
+func SetSelection(fd uintptr, selection *v4l2.V4l2Selection) error {
+
+	err := ioctl(fd, VIDIOC_S_SELECTION, unsafe.Pointer(selection))
+
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func DequeueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
+/*
+* Returns false once the index is past the last standard
+ */
+func QueryStandard(fd uintptr, standard *v4l2.V4l2Standard) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_ENUMSTD, unsafe.Pointer(standard))
+
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func GetStandard(fd uintptr) (uint64, error) {
 
-	r1, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, VIDIOC_DQBUF, uintptr(unsafe.Pointer(buffer)))
+	var id uint64
+	err := ioctl(fd, VIDIOC_G_STD, unsafe.Pointer(&id))
 
-	if r1 != 0 {
-		return errors.New(fmt.Sprintf("Cannot dequeue buffer, ioctl system call returned with status %d\n", r1))
+	if err != nil {
+		return 0, err
 	}
 
-	if err != 0 {
+	return id, nil
+}
+
+func SetStandard(fd uintptr, id uint64) error {
+
+	err := ioctl(fd, VIDIOC_S_STD, unsafe.Pointer(&id))
+
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+/*
+* Senses the standards the incoming signal may have, V4L2_STD_UNKNOWN without a signal
+ */
+func DetectStandard(fd uintptr) (uint64, error) {
+
+	var id uint64
+	err := ioctl(fd, VIDIOC_QUERYSTD, unsafe.Pointer(&id))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+/*
+* Returns false once the index is past the last timing
+ */
+func QueryDvTimingsEnum(fd uintptr, timings *v4l2.V4l2EnumDvTimings) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_ENUM_DV_TIMINGS, unsafe.Pointer(timings))
+
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func GetDvTimings(fd uintptr, timings *v4l2.V4l2DvTimings) error {
+
+	err := ioctl(fd, VIDIOC_G_DV_TIMINGS, unsafe.Pointer(timings))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SetDvTimings(fd uintptr, timings *v4l2.V4l2DvTimings) error {
+
+	err := ioctl(fd, VIDIOC_S_DV_TIMINGS, unsafe.Pointer(timings))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Senses the timings of the incoming signal. Receivers report ENOLINK without a signal,
+* ENOLCK for an unstable one and ERANGE for timings out of their range.
+ */
+func DetectDvTimings(fd uintptr, timings *v4l2.V4l2DvTimings) error {
+
+	err := ioctl(fd, VIDIOC_QUERY_DV_TIMINGS, unsafe.Pointer(timings))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Returns false once the index is past the last input
+ */
+func QueryInput(fd uintptr, input *v4l2.V4l2Input) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_ENUMINPUT, unsafe.Pointer(input))
+
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func GetInput(fd uintptr) (uint32, error) {
+
+	var index int32
+	err := ioctl(fd, VIDIOC_G_INPUT, unsafe.Pointer(&index))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(index), nil
+}
+
+func SetInput(fd uintptr, index uint32) error {
+
+	value := int32(index)
+	err := ioctl(fd, VIDIOC_S_INPUT, unsafe.Pointer(&value))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SubscribeEvent(fd uintptr, subscription *v4l2.V4l2EventSubscription) error {
+
+	err := ioctl(fd, VIDIOC_SUBSCRIBE_EVENT, unsafe.Pointer(subscription))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func UnsubscribeEvent(fd uintptr, subscription *v4l2.V4l2EventSubscription) error {
+
+	err := ioctl(fd, VIDIOC_UNSUBSCRIBE_EVENT, unsafe.Pointer(subscription))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Returns false if no event is pending, non-blocking devices report it by ENOENT.
+* Pending events are signalled by POLLPRI.
+ */
+func DequeueEvent(fd uintptr, event *v4l2.V4l2Event) (bool, error) {
+
+	err := ioctl(fd, VIDIOC_DQEVENT, unsafe.Pointer(event))
+
+	if errors.Is(err, syscall.ENOENT) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
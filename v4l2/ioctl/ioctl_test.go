@@ -0,0 +1,224 @@
+package ioctl
+
+import (
+	"runtime"
+	"testing"
+)
+
+/*
+ * Every named request is checked, expectedRequests holds the numbers they must have
+ */
+func TestRequestNumbers(t *testing.T) {
+	requests, ok := expectedRequests[runtime.GOARCH]
+
+	if !ok {
+		t.Skipf("No request numbers known on %s", runtime.GOARCH)
+	}
+
+	if len(requestNames) != len(requests) {
+		t.Errorf("%d requests named but %d numbers expected on %s", len(requestNames), len(requests), runtime.GOARCH)
+	}
+
+	for request, name := range requestNames {
+		expected, ok := requests[name]
+
+		if !ok {
+			t.Errorf("No expected number of %s on %s", name, runtime.GOARCH)
+			continue
+		}
+
+		if request != expected {
+			t.Errorf("Number of %s on %s: expected 0x%08x, got 0x%08x", name, runtime.GOARCH, expected, request)
+		}
+	}
+}
+
+/*
+ * Request numbers of the kernel by GOARCH
+ */
+var expectedRequests = map[string]map[string]uintptr{
+	"386": {
+		"VIDIOC_QUERYCAP":            0x80685600,
+		"VIDIOC_ENUM_FMT":            0xc0405602,
+		"VIDIOC_ENUM_FRAMESIZES":     0xc02c564a,
+		"VIDIOC_ENUM_FRAMEINTERVALS": 0xc034564b,
+		"VIDIOC_G_FMT":               0xc0cc5604,
+		"VIDIOC_S_FMT":               0xc0cc5605,
+		"VIDIOC_TRY_FMT":             0xc0cc5640,
+		"VIDIOC_REQBUFS":             0xc0145608,
+		"VIDIOC_QUERYBUF":            0xc0445609,
+		"VIDIOC_QBUF":                0xc044560f,
+		"VIDIOC_DQBUF":               0xc0445611,
+		"VIDIOC_EXPBUF":              0xc0405610,
+		"VIDIOC_STREAMON":            0x40045612,
+		"VIDIOC_STREAMOFF":           0x40045613,
+		"VIDIOC_G_PARM":              0xc0cc5615,
+		"VIDIOC_S_PARM":              0xc0cc5616,
+		"VIDIOC_G_CTRL":              0xc008561b,
+		"VIDIOC_S_CTRL":              0xc008561c,
+		"VIDIOC_QUERYCTRL":           0xc0445624,
+		"VIDIOC_QUERYMENU":           0xc02c5625,
+		"VIDIOC_G_EXT_CTRLS":         0xc0185647,
+		"VIDIOC_S_EXT_CTRLS":         0xc0185648,
+		"VIDIOC_TRY_EXT_CTRLS":       0xc0185649,
+		"VIDIOC_G_JPEGCOMP":          0x808c563d,
+		"VIDIOC_S_JPEGCOMP":          0x408c563e,
+		"VIDIOC_CROPCAP":             0xc02c563a,
+		"VIDIOC_G_CROP":              0xc014563b,
+		"VIDIOC_S_CROP":              0x4014563c,
+		"VIDIOC_G_SELECTION":         0xc040565e,
+		"VIDIOC_S_SELECTION":         0xc040565f,
+		"VIDIOC_ENUMSTD":             0xc0405619,
+		"VIDIOC_G_STD":               0x80085617,
+		"VIDIOC_S_STD":               0x40085618,
+		"VIDIOC_QUERYSTD":            0x8008563f,
+		"VIDIOC_S_DV_TIMINGS":        0xc0845657,
+		"VIDIOC_G_DV_TIMINGS":        0xc0845658,
+		"VIDIOC_ENUM_DV_TIMINGS":     0xc0945662,
+		"VIDIOC_QUERY_DV_TIMINGS":    0x80845663,
+		"VIDIOC_ENUMINPUT":           0xc04c561a,
+		"VIDIOC_G_INPUT":             0x80045626,
+		"VIDIOC_S_INPUT":             0xc0045627,
+		"VIDIOC_DQEVENT":             0x80785659,
+		"VIDIOC_SUBSCRIBE_EVENT":     0x4020565a,
+		"VIDIOC_UNSUBSCRIBE_EVENT":   0x4020565b,
+	},
+	"amd64": {
+		"VIDIOC_QUERYCAP":            0x80685600,
+		"VIDIOC_ENUM_FMT":            0xc0405602,
+		"VIDIOC_ENUM_FRAMESIZES":     0xc02c564a,
+		"VIDIOC_ENUM_FRAMEINTERVALS": 0xc034564b,
+		"VIDIOC_G_FMT":               0xc0d05604,
+		"VIDIOC_S_FMT":               0xc0d05605,
+		"VIDIOC_TRY_FMT":             0xc0d05640,
+		"VIDIOC_REQBUFS":             0xc0145608,
+		"VIDIOC_QUERYBUF":            0xc0585609,
+		"VIDIOC_QBUF":                0xc058560f,
+		"VIDIOC_DQBUF":               0xc0585611,
+		"VIDIOC_EXPBUF":              0xc0405610,
+		"VIDIOC_STREAMON":            0x40045612,
+		"VIDIOC_STREAMOFF":           0x40045613,
+		"VIDIOC_G_PARM":              0xc0cc5615,
+		"VIDIOC_S_PARM":              0xc0cc5616,
+		"VIDIOC_G_CTRL":              0xc008561b,
+		"VIDIOC_S_CTRL":              0xc008561c,
+		"VIDIOC_QUERYCTRL":           0xc0445624,
+		"VIDIOC_QUERYMENU":           0xc02c5625,
+		"VIDIOC_G_EXT_CTRLS":         0xc0205647,
+		"VIDIOC_S_EXT_CTRLS":         0xc0205648,
+		"VIDIOC_TRY_EXT_CTRLS":       0xc0205649,
+		"VIDIOC_G_JPEGCOMP":          0x808c563d,
+		"VIDIOC_S_JPEGCOMP":          0x408c563e,
+		"VIDIOC_CROPCAP":             0xc02c563a,
+		"VIDIOC_G_CROP":              0xc014563b,
+		"VIDIOC_S_CROP":              0x4014563c,
+		"VIDIOC_G_SELECTION":         0xc040565e,
+		"VIDIOC_S_SELECTION":         0xc040565f,
+		"VIDIOC_ENUMSTD":             0xc0485619,
+		"VIDIOC_G_STD":               0x80085617,
+		"VIDIOC_S_STD":               0x40085618,
+		"VIDIOC_QUERYSTD":            0x8008563f,
+		"VIDIOC_S_DV_TIMINGS":        0xc0845657,
+		"VIDIOC_G_DV_TIMINGS":        0xc0845658,
+		"VIDIOC_ENUM_DV_TIMINGS":     0xc0945662,
+		"VIDIOC_QUERY_DV_TIMINGS":    0x80845663,
+		"VIDIOC_ENUMINPUT":           0xc050561a,
+		"VIDIOC_G_INPUT":             0x80045626,
+		"VIDIOC_S_INPUT":             0xc0045627,
+		"VIDIOC_DQEVENT":             0x80885659,
+		"VIDIOC_SUBSCRIBE_EVENT":     0x4020565a,
+		"VIDIOC_UNSUBSCRIBE_EVENT":   0x4020565b,
+	},
+	"arm": {
+		"VIDIOC_QUERYCAP":            0x80685600,
+		"VIDIOC_ENUM_FMT":            0xc0405602,
+		"VIDIOC_ENUM_FRAMESIZES":     0xc02c564a,
+		"VIDIOC_ENUM_FRAMEINTERVALS": 0xc034564b,
+		"VIDIOC_G_FMT":               0xc0cc5604,
+		"VIDIOC_S_FMT":               0xc0cc5605,
+		"VIDIOC_TRY_FMT":             0xc0cc5640,
+		"VIDIOC_REQBUFS":             0xc0145608,
+		"VIDIOC_QUERYBUF":            0xc0445609,
+		"VIDIOC_QBUF":                0xc044560f,
+		"VIDIOC_DQBUF":               0xc0445611,
+		"VIDIOC_EXPBUF":              0xc0405610,
+		"VIDIOC_STREAMON":            0x40045612,
+		"VIDIOC_STREAMOFF":           0x40045613,
+		"VIDIOC_G_PARM":              0xc0cc5615,
+		"VIDIOC_S_PARM":              0xc0cc5616,
+		"VIDIOC_G_CTRL":              0xc008561b,
+		"VIDIOC_S_CTRL":              0xc008561c,
+		"VIDIOC_QUERYCTRL":           0xc0445624,
+		"VIDIOC_QUERYMENU":           0xc02c5625,
+		"VIDIOC_G_EXT_CTRLS":         0xc0185647,
+		"VIDIOC_S_EXT_CTRLS":         0xc0185648,
+		"VIDIOC_TRY_EXT_CTRLS":       0xc0185649,
+		"VIDIOC_G_JPEGCOMP":          0x808c563d,
+		"VIDIOC_S_JPEGCOMP":          0x408c563e,
+		"VIDIOC_CROPCAP":             0xc02c563a,
+		"VIDIOC_G_CROP":              0xc014563b,
+		"VIDIOC_S_CROP":              0x4014563c,
+		"VIDIOC_G_SELECTION":         0xc040565e,
+		"VIDIOC_S_SELECTION":         0xc040565f,
+		"VIDIOC_ENUMSTD":             0xc0485619,
+		"VIDIOC_G_STD":               0x80085617,
+		"VIDIOC_S_STD":               0x40085618,
+		"VIDIOC_QUERYSTD":            0x8008563f,
+		"VIDIOC_S_DV_TIMINGS":        0xc0845657,
+		"VIDIOC_G_DV_TIMINGS":        0xc0845658,
+		"VIDIOC_ENUM_DV_TIMINGS":     0xc0945662,
+		"VIDIOC_QUERY_DV_TIMINGS":    0x80845663,
+		"VIDIOC_ENUMINPUT":           0xc050561a,
+		"VIDIOC_G_INPUT":             0x80045626,
+		"VIDIOC_S_INPUT":             0xc0045627,
+		"VIDIOC_DQEVENT":             0x80805659,
+		"VIDIOC_SUBSCRIBE_EVENT":     0x4020565a,
+		"VIDIOC_UNSUBSCRIBE_EVENT":   0x4020565b,
+	},
+	"arm64": {
+		"VIDIOC_QUERYCAP":            0x80685600,
+		"VIDIOC_ENUM_FMT":            0xc0405602,
+		"VIDIOC_ENUM_FRAMESIZES":     0xc02c564a,
+		"VIDIOC_ENUM_FRAMEINTERVALS": 0xc034564b,
+		"VIDIOC_G_FMT":               0xc0d05604,
+		"VIDIOC_S_FMT":               0xc0d05605,
+		"VIDIOC_TRY_FMT":             0xc0d05640,
+		"VIDIOC_REQBUFS":             0xc0145608,
+		"VIDIOC_QUERYBUF":            0xc0585609,
+		"VIDIOC_QBUF":                0xc058560f,
+		"VIDIOC_DQBUF":               0xc0585611,
+		"VIDIOC_EXPBUF":              0xc0405610,
+		"VIDIOC_STREAMON":            0x40045612,
+		"VIDIOC_STREAMOFF":           0x40045613,
+		"VIDIOC_G_PARM":              0xc0cc5615,
+		"VIDIOC_S_PARM":              0xc0cc5616,
+		"VIDIOC_G_CTRL":              0xc008561b,
+		"VIDIOC_S_CTRL":              0xc008561c,
+		"VIDIOC_QUERYCTRL":           0xc0445624,
+		"VIDIOC_QUERYMENU":           0xc02c5625,
+		"VIDIOC_G_EXT_CTRLS":         0xc0205647,
+		"VIDIOC_S_EXT_CTRLS":         0xc0205648,
+		"VIDIOC_TRY_EXT_CTRLS":       0xc0205649,
+		"VIDIOC_G_JPEGCOMP":          0x808c563d,
+		"VIDIOC_S_JPEGCOMP":          0x408c563e,
+		"VIDIOC_CROPCAP":             0xc02c563a,
+		"VIDIOC_G_CROP":              0xc014563b,
+		"VIDIOC_S_CROP":              0x4014563c,
+		"VIDIOC_G_SELECTION":         0xc040565e,
+		"VIDIOC_S_SELECTION":         0xc040565f,
+		"VIDIOC_ENUMSTD":             0xc0485619,
+		"VIDIOC_G_STD":               0x80085617,
+		"VIDIOC_S_STD":               0x40085618,
+		"VIDIOC_QUERYSTD":            0x8008563f,
+		"VIDIOC_S_DV_TIMINGS":        0xc0845657,
+		"VIDIOC_G_DV_TIMINGS":        0xc0845658,
+		"VIDIOC_ENUM_DV_TIMINGS":     0xc0945662,
+		"VIDIOC_QUERY_DV_TIMINGS":    0x80845663,
+		"VIDIOC_ENUMINPUT":           0xc050561a,
+		"VIDIOC_G_INPUT":             0x80045626,
+		"VIDIOC_S_INPUT":             0xc0045627,
+		"VIDIOC_DQEVENT":             0x80885659,
+		"VIDIOC_SUBSCRIBE_EVENT":     0x4020565a,
+		"VIDIOC_UNSUBSCRIBE_EVENT":   0x4020565b,
+	},
+}
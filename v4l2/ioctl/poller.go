@@ -0,0 +1,236 @@
+package ioctl
+
+import (
+	"context"
+	"log"
+	"sync"
+	"syscall"
+)
+
+/*
+* Single epoll instance shared by all devices the kernel backend polls. One goroutine
+* waits in epoll_wait for all of them, the callers only wait on channels. Every wait
+* arms the descriptor in one-shot mode for the events of its current waiters.
+ */
+type epoller struct {
+	once sync.Once
+	err  error
+	epfd int
+
+	mutex      sync.Mutex
+	waiters    map[int32][]*pollWaiter
+	registered map[int32]bool
+}
+
+type pollWaiter struct {
+	events int16
+	/* receives the signalled events, buffered so the loop never blocks */
+	ch chan int16
+}
+
+/* events delivered to every waiter no matter what it waits for */
+const pollAlways = POLLERR | POLLHUP | POLLNVAL
+
+var kernelPoller = &epoller{}
+
+func (p *epoller) start() error {
+	p.once.Do(func() {
+		p.epfd, p.err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+
+		if p.err == nil {
+			p.waiters = make(map[int32][]*pollWaiter)
+			p.registered = make(map[int32]bool)
+			go p.loop()
+		}
+	})
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+func (p *epoller) wait(ctx context.Context, fd int32, events int16) (int16, error) {
+	if err := p.start(); err != nil {
+		return 0, err
+	}
+
+	w := &pollWaiter{events, make(chan int16, 1)}
+
+	p.mutex.Lock()
+	p.waiters[fd] = append(p.waiters[fd], w)
+
+	if err := p.arm(fd); err != nil {
+		p.remove(fd, w)
+		p.mutex.Unlock()
+		return 0, err
+	}
+
+	p.mutex.Unlock()
+
+	select {
+	case revents := <-w.ch:
+		return revents, nil
+	case <-ctx.Done():
+	}
+
+	p.mutex.Lock()
+	p.remove(fd, w)
+	p.mutex.Unlock()
+
+	/* the loop may have delivered the events meanwhile */
+	select {
+	case revents := <-w.ch:
+		return revents, nil
+	default:
+		return 0, ctx.Err()
+	}
+}
+
+/*
+* Caller holds the mutex. A descriptor number can be reused after close, hence the
+* fallbacks between adding and modifying.
+ */
+func (p *epoller) arm(fd int32) error {
+	var events uint32
+
+	for _, w := range p.waiters[fd] {
+		events |= uint32(uint16(w.events))
+	}
+
+	event := syscall.EpollEvent{Events: events | syscall.EPOLLONESHOT, Fd: fd}
+
+	op := syscall.EPOLL_CTL_ADD
+	if p.registered[fd] {
+		op = syscall.EPOLL_CTL_MOD
+	}
+
+	err := syscall.EpollCtl(p.epfd, op, int(fd), &event)
+
+	if err == syscall.ENOENT && op == syscall.EPOLL_CTL_MOD {
+		err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, int(fd), &event)
+	} else if err == syscall.EEXIST && op == syscall.EPOLL_CTL_ADD {
+		err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_MOD, int(fd), &event)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.registered[fd] = true
+	return nil
+}
+
+/*
+* Caller holds the mutex
+ */
+func (p *epoller) remove(fd int32, w *pollWaiter) {
+	waiters := p.waiters[fd]
+
+	for i, other := range waiters {
+		if other == w {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiters) == 0 {
+		delete(p.waiters, fd)
+		return
+	}
+
+	p.waiters[fd] = waiters
+}
+
+/*
+* Stops watching a descriptor which is going to be closed, its waiters are woken by POLLNVAL
+ */
+func (p *epoller) forget(fd int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.registered[fd] {
+		return
+	}
+
+	syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, int(fd), nil)
+	delete(p.registered, fd)
+
+	for _, w := range p.waiters[fd] {
+		w.ch <- POLLNVAL
+	}
+
+	delete(p.waiters, fd)
+}
+
+func (p *epoller) loop() {
+	events := make([]syscall.EpollEvent, 16)
+
+	for {
+		n, err := syscall.EpollWait(p.epfd, events, -1)
+
+		if err == syscall.EINTR {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Polling devices failed: %v\n", err)
+			p.fail(err)
+			return
+		}
+
+		p.mutex.Lock()
+
+		for _, event := range events[:n] {
+			p.deliver(event.Fd, int16(event.Events))
+		}
+
+		p.mutex.Unlock()
+	}
+}
+
+/*
+* Caller holds the mutex. Waiters whose events did not occur stay and the descriptor
+* is armed again for them.
+ */
+func (p *epoller) deliver(fd int32, revents int16) {
+	var remaining []*pollWaiter
+
+	for _, w := range p.waiters[fd] {
+		if signalled := revents & (w.events | pollAlways); signalled != 0 {
+			w.ch <- signalled
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(p.waiters, fd)
+		return
+	}
+
+	p.waiters[fd] = remaining
+
+	if err := p.arm(fd); err != nil {
+		for _, w := range remaining {
+			w.ch <- POLLERR
+		}
+		delete(p.waiters, fd)
+	}
+}
+
+/*
+* Wakes all waiters after epoll itself broke, later waits fail right away
+ */
+func (p *epoller) fail(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.err = err
+
+	for fd, waiters := range p.waiters {
+		for _, w := range waiters {
+			w.ch <- POLLERR
+		}
+		delete(p.waiters, fd)
+	}
+}
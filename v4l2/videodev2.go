@@ -1,7 +1,6 @@
 package v4l2
 
 import (
-	"encoding/binary"
 	"unsafe"
 	//"bytes"
 )
@@ -69,6 +68,12 @@ type V4l2Fmtdesc struct {
 	Reserved    [4]uint32
 }
 
+/* Flags of v4l2_fmtdesc */
+const (
+	V4L2_FMT_FLAG_COMPRESSED = 0x0001
+	V4L2_FMT_FLAG_EMULATED   = 0x0002
+)
+
 const (
 	V4L2_BUF_TYPE_VIDEO_CAPTURE        = 1
 	V4L2_BUF_TYPE_VIDEO_OUTPUT         = 2
@@ -119,6 +124,23 @@ const (
 	transmitted first */
 )
 
+/* enum v4l2_colorspace */
+const (
+	V4L2_COLORSPACE_DEFAULT       = 0
+	V4L2_COLORSPACE_SMPTE170M     = 1
+	V4L2_COLORSPACE_SMPTE240M     = 2
+	V4L2_COLORSPACE_REC709        = 3
+	V4L2_COLORSPACE_BT878         = 4
+	V4L2_COLORSPACE_470_SYSTEM_M  = 5
+	V4L2_COLORSPACE_470_SYSTEM_BG = 6
+	V4L2_COLORSPACE_JPEG          = 7
+	V4L2_COLORSPACE_SRGB          = 8
+	V4L2_COLORSPACE_ADOBERGB      = 9
+	V4L2_COLORSPACE_BT2020        = 10
+	V4L2_COLORSPACE_RAW           = 11
+	V4L2_COLORSPACE_DCI_P3        = 12
+)
+
 /*      Pixel format         FOURCC                          depth  Description  */
 
 /* RGB formats */
@@ -335,17 +357,15 @@ type V4l2Frmsizeenum struct {
 }
 
 func (f V4l2Frmsizeenum) Discrete() V4l2Frmsize_discrete {
-	ptr := uintptr(unsafe.Pointer(&f))
-	ptr += 12 /*skip index, pixel_format and type*/
-
-	return *(*V4l2Frmsize_discrete)(unsafe.Pointer(ptr))
+	return *(*V4l2Frmsize_discrete)(unsafe.Pointer(&f.data))
 }
 
-func (f V4l2Frmsizeenum) Stepwise() V4l2Frmsize_discrete {
-	ptr := uintptr(unsafe.Pointer(&f))
-	ptr += 24 /*skip index, pixel_format and type*/
+func (f V4l2Frmsizeenum) Stepwise() V4l2Frmsize_stepwise {
+	return *(*V4l2Frmsize_stepwise)(unsafe.Pointer(&f.data))
+}
 
-	return *(*V4l2Frmsize_discrete)(unsafe.Pointer(ptr))
+func (f *V4l2Frmsizeenum) SetDiscrete(discrete V4l2Frmsize_discrete) {
+	*(*V4l2Frmsize_discrete)(unsafe.Pointer(&f.data)) = discrete
 }
 
 type V4l2Frmsize_discrete struct {
@@ -362,6 +382,55 @@ type V4l2Frmsize_stepwise struct {
 	Step_height uint32 /* Frame height step size [pixel] */
 }
 
+type V4l2Fract struct {
+	Numerator   uint32
+	Denominator uint32
+}
+
+/*
+ *	F R A M E   R A T E   E N U M E R A T I O N
+ */
+const (
+	V4L2_FRMIVAL_TYPE_DISCRETE   = 1
+	V4L2_FRMIVAL_TYPE_CONTINUOUS = 2
+	V4L2_FRMIVAL_TYPE_STEPWISE   = 3
+)
+
+type V4l2Frmivalenum struct {
+	Index       uint32 /* Frame format index */
+	PixelFormat uint32 /* Pixel format */
+	Width       uint32 /* Frame width */
+	Height      uint32 /* Frame height */
+	Type        uint32 /* Frame interval type the device supports. */
+
+	data [6]uint32
+	/*
+		union {
+			struct v4l2_fract		discrete;
+			struct v4l2_frmival_stepwise	stepwise;
+		};*/
+
+	reserved [2]uint32 /* Reserved space for future use */
+}
+
+func (f V4l2Frmivalenum) Discrete() V4l2Fract {
+	return *(*V4l2Fract)(unsafe.Pointer(&f.data))
+}
+
+func (f V4l2Frmivalenum) Stepwise() V4l2Frmival_stepwise {
+	return *(*V4l2Frmival_stepwise)(unsafe.Pointer(&f.data))
+}
+
+func (f *V4l2Frmivalenum) SetDiscrete(discrete V4l2Fract) {
+	*(*V4l2Fract)(unsafe.Pointer(&f.data)) = discrete
+}
+
+type V4l2Frmival_stepwise struct {
+	Min  V4l2Fract /* Minimum frame interval [s] */
+	Max  V4l2Fract /* Maximum frame interval [s] */
+	Step V4l2Fract /* Frame interval step size [s] */
+}
+
 /**
  * struct v4l2_format - stream data format
  * @type:	enum v4l2_buf_type; type of the data stream
@@ -375,7 +444,7 @@ type V4l2Frmsize_stepwise struct {
 type V4l2Format struct {
 	Type uint32
 
-	data [200]byte
+	data formatUnion
 	//union {
 	//	struct v4l2_pix_format		pix;     /* V4L2_BUF_TYPE_VIDEO_CAPTURE */
 	//	struct v4l2_pix_format_mplane	pix_mp;  /* V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE */
@@ -387,18 +456,37 @@ type V4l2Format struct {
 	//} fmt;
 }
 
+/*
+ * The pointers of struct v4l2_window make the union pointer aligned, on 64-bit
+ * architectures there is a padding after the type field.
+ */
+type formatUnion struct {
+	_   [0]uintptr
+	raw [200]byte
+}
+
 func (f *V4l2Format) SetPixFormat(pixformat *V4l2PixFormat) {
 
 	f.Type = V4L2_BUF_TYPE_VIDEO_CAPTURE
 
 	t := (*V4l2PixFormat)(unsafe.Pointer(&f.data))
-	t.Width = pixformat.Width
-	t.Height = pixformat.Height
-	t.Pixelformat = pixformat.Pixelformat
-	t.Field = pixformat.Field
+	*t = *pixformat
+}
+
+func (f *V4l2Format) PixFormat() V4l2PixFormat {
+	return *(*V4l2PixFormat)(unsafe.Pointer(&f.data))
+}
+
+func (f *V4l2Format) SetPixFormatMplane(pixformat *V4l2PixFormatMplane) {
+
+	f.Type = V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE
 
-	//fff := *(*[204]byte)(unsafe.Pointer(f))
-	//fmt.Printf("%v\n", fff)
+	t := (*V4l2PixFormatMplane)(unsafe.Pointer(&f.data))
+	*t = *pixformat
+}
+
+func (f *V4l2Format) PixFormatMplane() V4l2PixFormatMplane {
+	return *(*V4l2PixFormatMplane)(unsafe.Pointer(&f.data))
 }
 
 /*
@@ -419,6 +507,77 @@ type V4l2PixFormat struct {
 	Xfer_func    uint32 /* enum v4l2_xfer_func */
 }
 
+const VIDEO_MAX_PLANES = 8
+
+/**
+ * struct v4l2_plane_pix_format - additional, per-plane format definition
+ * @sizeimage:		maximum size in bytes required for data, for which
+ *			this plane will be used
+ * @bytesperline:	distance in bytes between the leftmost pixels in two
+ *			adjacent lines
+ */
+type V4l2PlanePixFormat struct {
+	Sizeimage    uint32
+	Bytesperline uint32
+	Reserved     [6]uint16
+}
+
+/**
+ * struct v4l2_pix_format_mplane - multiplanar format definition
+ * @width:		image width in pixels
+ * @height:		image height in pixels
+ * @pixelformat:	little endian four character code (fourcc)
+ * @field:		enum v4l2_field; field order (for interlaced video)
+ * @colorspace:		enum v4l2_colorspace; supplemental to pixelformat
+ * @plane_fmt:		per-plane information
+ * @num_planes:		number of planes for this format
+ * @flags:		format flags (V4L2_PIX_FMT_FLAG_*)
+ * @ycbcr_enc:		enum v4l2_ycbcr_encoding, Y'CbCr encoding
+ * @quantization:	enum v4l2_quantization, colorspace quantization
+ * @xfer_func:		enum v4l2_xfer_func, colorspace transfer function
+ */
+type V4l2PixFormatMplane struct {
+	Width        uint32
+	Height       uint32
+	Pixelformat  uint32
+	Field        uint32
+	Colorspace   uint32
+	PlaneFmt     [VIDEO_MAX_PLANES]V4l2PlanePixFormat
+	NumPlanes    uint8
+	Flags        uint8
+	Ycbcr_enc    uint8 /* hsv_enc for HSV formats */
+	Quantization uint8
+	Xfer_func    uint8
+	Reserved     [7]uint8
+}
+
+/*
+ *	J P E G   C O M P R E S S I O N
+ */
+
+/*
+ * Deprecated by the kernel in favour of V4L2_CID_JPEG_COMPRESSION_QUALITY, older drivers
+ * offer the quality only this way
+ */
+type V4l2Jpegcompression struct {
+	Quality     int32
+	APPn        int32 /* Number of APP segment to be written, must be 0..15 */
+	APPLen      int32 /* Length of data in JPEG APPn segment */
+	APPData     [60]uint8
+	COMLen      int32 /* Length of data in JPEG COM segment */
+	COMData     [60]uint8
+	JpegMarkers uint32
+}
+
+/* Which markers should go into the JPEG output */
+const (
+	V4L2_JPEG_MARKER_DHT = 1 << 3 /* Define Huffman Tables */
+	V4L2_JPEG_MARKER_DQT = 1 << 4 /* Define Quantization Tables */
+	V4L2_JPEG_MARKER_DRI = 1 << 5 /* Define Restart Interval */
+	V4L2_JPEG_MARKER_COM = 1 << 6 /* Comment segment */
+	V4L2_JPEG_MARKER_APP = 1 << 7 /* App segment, driver will always use APP0 */
+)
+
 /*
  *	M E M O R Y - M A P P I N G   B U F F E R S
  */
@@ -473,25 +632,894 @@ type V4l2Buffer struct {
 	Bytesused uint32
 	Flags     uint32
 	Field     uint32
-	timestamp [8]byte  //struct timeval		timestamp;
-	timecode  [16]byte //struct v4l2_timecode	timecode;
+	timestamp V4l2Timeval //struct timeval		timestamp;
+	timecode  [16]byte    //struct v4l2_timecode	timecode;
 	Sequence  uint32
 
 	/* memory location */
 	Memory uint32
-	m      [4]byte
+	m      v4l2Ulong
 	/*
 		union {
-			__u32           offset;   //4
-			unsigned long   userptr; //4
-			struct v4l2_plane *planes;  //4
-			__s32		fd; //4
+			__u32           offset;
+			unsigned long   userptr;
+			struct v4l2_plane *planes;
+			__s32		fd;
 		} m;*/
 	Length    uint32
 	Reserved2 uint32
 	Reserved  uint32
 }
 
+/*
+ * The offset is the first member of the union, it shares the leading bytes of
+ * the union in the native byte order.
+ */
 func (b *V4l2Buffer) Offset() uint32 {
-	return binary.LittleEndian.Uint32(b.m[:])
+	return *(*uint32)(unsafe.Pointer(&b.m))
+}
+
+func (b *V4l2Buffer) SetOffset(offset uint32) {
+	*(*uint32)(unsafe.Pointer(&b.m)) = offset
+}
+
+/*
+ * Address of the application memory of a V4L2_MEMORY_USERPTR buffer
+ */
+func (b *V4l2Buffer) Userptr() uintptr {
+	return uintptr(b.m)
+}
+
+func (b *V4l2Buffer) SetUserptr(userptr uintptr) {
+	b.m = v4l2Ulong(userptr)
+}
+
+/*
+ * DMABUF file descriptor of a V4L2_MEMORY_DMABUF buffer, it shares the leading
+ * bytes of the union like the offset
+ */
+func (b *V4l2Buffer) Fd() int32 {
+	return *(*int32)(unsafe.Pointer(&b.m))
+}
+
+func (b *V4l2Buffer) SetFd(fd int32) {
+	b.m = 0
+	*(*int32)(unsafe.Pointer(&b.m)) = fd
+}
+
+/*
+ * Plane array of a multi-planar buffer, Length is the number of planes. The buffer
+ * refers to the array by its address only, the caller keeps the array alive while
+ * the buffer is in use.
+ */
+func (b *V4l2Buffer) SetPlanes(planes []V4l2Plane) {
+	b.m = 0
+	b.Length = uint32(len(planes))
+
+	if len(planes) > 0 {
+		*(*unsafe.Pointer)(unsafe.Pointer(&b.m)) = unsafe.Pointer(&planes[0])
+	}
+}
+
+func (b *V4l2Buffer) Planes() []V4l2Plane {
+	planes := *(*unsafe.Pointer)(unsafe.Pointer(&b.m))
+
+	if planes == nil {
+		return nil
+	}
+
+	return unsafe.Slice((*V4l2Plane)(planes), b.Length)
+}
+
+/*
+ * Returns the timestamp of the buffer as seconds and microseconds (struct timeval)
+ */
+func (b *V4l2Buffer) Timestamp() (int64, int64) {
+	return int64(b.timestamp.Sec), int64(b.timestamp.Usec)
+}
+
+func (b *V4l2Buffer) SetTimestamp(sec int64, usec int64) {
+	b.timestamp = V4l2Timeval{v4l2Long(sec), v4l2Long(usec)}
+}
+
+/* struct timeval */
+type V4l2Timeval struct {
+	Sec  v4l2Long
+	Usec v4l2Long
+}
+
+/*  Flags for 'flags' field */
+const (
+	/* Buffer is mapped (flag) */
+	V4L2_BUF_FLAG_MAPPED = 0x00000001
+	/* Buffer is queued for processing */
+	V4L2_BUF_FLAG_QUEUED = 0x00000002
+	/* Buffer is ready */
+	V4L2_BUF_FLAG_DONE = 0x00000004
+	/* Image is a keyframe (I-frame) */
+	V4L2_BUF_FLAG_KEYFRAME = 0x00000008
+	/* Image is a P-frame */
+	V4L2_BUF_FLAG_PFRAME = 0x00000010
+	/* Image is a B-frame */
+	V4L2_BUF_FLAG_BFRAME = 0x00000020
+	/* Buffer is ready, but the data contained within is corrupted. */
+	V4L2_BUF_FLAG_ERROR = 0x00000040
+	/* timecode field is valid */
+	V4L2_BUF_FLAG_TIMECODE = 0x00000100
+	/* Buffer is prepared for queuing */
+	V4L2_BUF_FLAG_PREPARED = 0x00000400
+	/* Cache handling flags */
+	V4L2_BUF_FLAG_NO_CACHE_INVALIDATE = 0x00000800
+	V4L2_BUF_FLAG_NO_CACHE_CLEAN      = 0x00001000
+	/* Timestamp type */
+	V4L2_BUF_FLAG_TIMESTAMP_MASK      = 0x0000e000
+	V4L2_BUF_FLAG_TIMESTAMP_UNKNOWN   = 0x00000000
+	V4L2_BUF_FLAG_TIMESTAMP_MONOTONIC = 0x00002000
+	V4L2_BUF_FLAG_TIMESTAMP_COPY      = 0x00004000
+	/* Timestamp sources. */
+	V4L2_BUF_FLAG_TSTAMP_SRC_MASK = 0x00070000
+	V4L2_BUF_FLAG_TSTAMP_SRC_EOF  = 0x00000000
+	V4L2_BUF_FLAG_TSTAMP_SRC_SOE  = 0x00010000
+	/* mem2mem encoder/decoder */
+	V4L2_BUF_FLAG_LAST = 0x00100000
+)
+
+/**
+ * struct v4l2_plane - plane info for multi-planar buffers
+ * @bytesused:		number of bytes occupied by data in the plane (payload)
+ * @length:		size of this plane (NOT the payload) in bytes
+ * @mem_offset:		when memory in the associated struct v4l2_buffer is
+ *			V4L2_MEMORY_MMAP, equals the offset from the start of
+ *			the device memory for this plane (or is a "cookie" that
+ *			should be passed to mmap() called on the video node)
+ * @userptr:		when memory is V4L2_MEMORY_USERPTR, a userspace pointer
+ *			pointing to this plane
+ * @fd:			when memory is V4L2_MEMORY_DMABUF, a userspace file
+ *			descriptor associated with this plane
+ * @data_offset:	offset in the plane to the start of data; usually 0,
+ *			unless there is a header in front of the data
+ *
+ * Multi-planar buffers consist of one or more planes, e.g. an YCbCr buffer
+ * with two planes can have one plane for Y, and another for interleaved CbCr
+ * components. Each plane can reside in a separate memory buffer, or even in
+ * a completely separate memory node (e.g. in embedded devices).
+ */
+type V4l2Plane struct {
+	Bytesused uint32
+	Length    uint32
+	m         v4l2Ulong
+	/*
+		union {
+			__u32		mem_offset;
+			unsigned long	userptr;
+			__s32		fd;
+		} m;*/
+	DataOffset uint32
+	Reserved   [11]uint32
+}
+
+func (p *V4l2Plane) MemOffset() uint32 {
+	return *(*uint32)(unsafe.Pointer(&p.m))
+}
+
+func (p *V4l2Plane) SetMemOffset(offset uint32) {
+	p.m = 0
+	*(*uint32)(unsafe.Pointer(&p.m)) = offset
+}
+
+func (p *V4l2Plane) Userptr() uintptr {
+	return uintptr(p.m)
+}
+
+func (p *V4l2Plane) SetUserptr(userptr uintptr) {
+	p.m = v4l2Ulong(userptr)
+}
+
+func (p *V4l2Plane) Fd() int32 {
+	return *(*int32)(unsafe.Pointer(&p.m))
+}
+
+func (p *V4l2Plane) SetFd(fd int32) {
+	p.m = 0
+	*(*int32)(unsafe.Pointer(&p.m)) = fd
+}
+
+/**
+ * struct v4l2_exportbuffer - export of video buffer as DMABUF file descriptor
+ *
+ * @index:	id number of the buffer
+ * @type:	enum v4l2_buf_type; buffer type (type == *_MPLANE for
+ *		multiplanar buffers);
+ * @plane:	index of the plane to be exported, 0 for single plane queues
+ * @flags:	flags for newly created file, currently only O_CLOEXEC is
+ *		supported, refer to manual of open syscall for more details
+ * @fd:		file descriptor associated with DMABUF (set by driver)
+ *
+ * Contains data used for exporting a video buffer as DMABUF file descriptor.
+ * The buffer is identified by a 'cookie' returned by VIDIOC_QUERYBUF
+ * (identical to the cookie used to mmap() the buffer to userspace). All
+ * reserved fields must be set to zero. The field reserved0 is expected to
+ * become a structure 'type' allowing an alternative layout of the structure
+ * content. Therefore this field should not be used for any other extensions.
+ */
+type V4l2ExportBuffer struct {
+	Type     uint32 /* enum v4l2_buf_type */
+	Index    uint32
+	Plane    uint32
+	Flags    uint32
+	Fd       int32
+	Reserved [11]uint32
+}
+
+/*
+ *	C O N T R O L S
+ */
+type V4l2Control struct {
+	Id    uint32
+	Value int32
+}
+
+/*
+ * struct v4l2_ext_control is packed in the kernel, the value union therefore
+ * starts right after the reserved field and occupies 8 bytes.
+ */
+type V4l2ExtControl struct {
+	Id        uint32
+	Size      uint32
+	Reserved2 [1]uint32
+	value     [8]byte
+	/*
+		union {
+			__s32 value;
+			__s64 value64;
+			char *string;
+			__u8 *p_u8;
+			__u16 *p_u16;
+			__u32 *p_u32;
+			void *ptr;
+		};*/
+}
+
+func (c *V4l2ExtControl) Value() int32 {
+	var value int32
+	copy((*[4]byte)(unsafe.Pointer(&value))[:], c.value[:4])
+	return value
+}
+
+func (c *V4l2ExtControl) SetValue(value int32) {
+	copy(c.value[:4], (*[4]byte)(unsafe.Pointer(&value))[:])
+}
+
+func (c *V4l2ExtControl) Value64() int64 {
+	var value int64
+	copy((*[8]byte)(unsafe.Pointer(&value))[:], c.value[:])
+	return value
+}
+
+func (c *V4l2ExtControl) SetValue64(value int64) {
+	copy(c.value[:], (*[8]byte)(unsafe.Pointer(&value))[:])
+}
+
+type V4l2ExtControls struct {
+	Which    uint32 /* union with ctrl_class */
+	Count    uint32
+	ErrorIdx uint32
+	Reserved [2]uint32
+	Controls *V4l2ExtControl
+}
+
+const (
+	V4L2_CTRL_ID_MASK       = 0x0fffffff
+	V4L2_CTRL_WHICH_CUR_VAL = 0
+	V4L2_CTRL_WHICH_DEF_VAL = 0x0f000000
+)
+
+func V4L2_CTRL_ID2CLASS(id uint32) uint32 {
+	return id & 0x0fff0000
+}
+
+/* enum v4l2_ctrl_type */
+const (
+	V4L2_CTRL_TYPE_INTEGER      = 1
+	V4L2_CTRL_TYPE_BOOLEAN      = 2
+	V4L2_CTRL_TYPE_MENU         = 3
+	V4L2_CTRL_TYPE_BUTTON       = 4
+	V4L2_CTRL_TYPE_INTEGER64    = 5
+	V4L2_CTRL_TYPE_CTRL_CLASS   = 6
+	V4L2_CTRL_TYPE_STRING       = 7
+	V4L2_CTRL_TYPE_BITMASK      = 8
+	V4L2_CTRL_TYPE_INTEGER_MENU = 9
+
+	/* Compound types are >= 0x0100 */
+	V4L2_CTRL_COMPOUND_TYPES = 0x0100
+	V4L2_CTRL_TYPE_U8        = 0x0100
+	V4L2_CTRL_TYPE_U16       = 0x0101
+	V4L2_CTRL_TYPE_U32       = 0x0102
+)
+
+/*  Used in the VIDIOC_QUERYCTRL ioctl for querying controls */
+type V4l2Queryctrl struct {
+	Id           uint32
+	Type         uint32    /* enum v4l2_ctrl_type */
+	Name         [32]uint8 /* Whatever */
+	Minimum      int32     /* Note signedness */
+	Maximum      int32
+	Step         int32
+	DefaultValue int32
+	Flags        uint32
+	Reserved     [2]uint32
+}
+
+/*
+ * Used in the VIDIOC_QUERYMENU ioctl for querying menu items.
+ * The kernel struct is packed, name and value share the same 32 bytes.
+ */
+type V4l2Querymenu struct {
+	Id    uint32
+	Index uint32
+	data  [32]uint8
+	/*
+		union {
+			__u8	name[32];
+			__s64	value;
+		};*/
+	Reserved uint32
+}
+
+func (m *V4l2Querymenu) Name() [32]uint8 {
+	return m.data
+}
+
+func (m *V4l2Querymenu) SetName(name [32]uint8) {
+	m.data = name
+}
+
+func (m *V4l2Querymenu) Value() int64 {
+	var value int64
+	copy((*[8]byte)(unsafe.Pointer(&value))[:], m.data[:8])
+	return value
+}
+
+/*  Control flags  */
+const (
+	V4L2_CTRL_FLAG_DISABLED         = 0x0001
+	V4L2_CTRL_FLAG_GRABBED          = 0x0002
+	V4L2_CTRL_FLAG_READ_ONLY        = 0x0004
+	V4L2_CTRL_FLAG_UPDATE           = 0x0008
+	V4L2_CTRL_FLAG_INACTIVE         = 0x0010
+	V4L2_CTRL_FLAG_SLIDER           = 0x0020
+	V4L2_CTRL_FLAG_WRITE_ONLY       = 0x0040
+	V4L2_CTRL_FLAG_VOLATILE         = 0x0080
+	V4L2_CTRL_FLAG_HAS_PAYLOAD      = 0x0100
+	V4L2_CTRL_FLAG_EXECUTE_ON_WRITE = 0x0200
+
+	/*  Query flags, to be ORed with the control ID */
+	V4L2_CTRL_FLAG_NEXT_CTRL     = 0x80000000
+	V4L2_CTRL_FLAG_NEXT_COMPOUND = 0x40000000
+)
+
+/*  IDs reserved for driver specific controls */
+const V4L2_CID_PRIVATE_BASE = 0x08000000
+
+/*
+ *	S T R E A M I N G   P A R A M E T E R S
+ */
+type V4l2Captureparm struct {
+	Capability   uint32    /*  Supported modes */
+	Capturemode  uint32    /*  Current mode */
+	Timeperframe V4l2Fract /*  Time per frame in seconds */
+	Extendedmode uint32    /*  Driver-specific extensions */
+	Readbuffers  uint32    /*  # of buffers for read */
+	Reserved     [4]uint32
+}
+
+/*  Flags for 'capability' and 'capturemode' fields */
+const (
+	V4L2_MODE_HIGHQUALITY = 0x0001 /*  High quality imaging mode */
+	V4L2_CAP_TIMEPERFRAME = 0x1000 /*  timeperframe field is supported */
+)
+
+type V4l2Outputparm struct {
+	Capability   uint32    /*  Supported modes */
+	Outputmode   uint32    /*  Current mode */
+	Timeperframe V4l2Fract /*  Time per frame in seconds */
+	Extendedmode uint32    /*  Driver-specific extensions */
+	Writebuffers uint32    /*  # of buffers for write */
+	Reserved     [4]uint32
+}
+
+type V4l2Streamparm struct {
+	Type uint32 /* enum v4l2_buf_type */
+
+	data [200]byte
+	/*
+		union {
+			struct v4l2_captureparm	capture;
+			struct v4l2_outputparm	output;
+			__u8	raw_data[200];
+		} parm;*/
+}
+
+func (p *V4l2Streamparm) Capture() *V4l2Captureparm {
+	return (*V4l2Captureparm)(unsafe.Pointer(&p.data))
+}
+
+func (p *V4l2Streamparm) Output() *V4l2Outputparm {
+	return (*V4l2Outputparm)(unsafe.Pointer(&p.data))
+}
+
+/*
+ *	C R O P P I N G   A N D   S E L E C T I O N
+ */
+
+type V4l2Rect struct {
+	Left   int32
+	Top    int32
+	Width  uint32
+	Height uint32
+}
+
+type V4l2Cropcap struct {
+	Type        uint32 /* enum v4l2_buf_type */
+	Bounds      V4l2Rect
+	Defrect     V4l2Rect
+	Pixelaspect V4l2Fract
+}
+
+type V4l2Crop struct {
+	Type uint32 /* enum v4l2_buf_type */
+	C    V4l2Rect
+}
+
+/*
+ * Multi-planar buffer types are accepted only by kernels since 4.13, the single-planar
+ * ones are used for both kinds of devices
+ */
+type V4l2Selection struct {
+	Type     uint32 /* enum v4l2_buf_type */
+	Target   uint32
+	Flags    uint32
+	R        V4l2Rect
+	Reserved [9]uint32
+}
+
+/* Selection targets */
+const (
+	V4L2_SEL_TGT_CROP            = 0x0000 /* Current cropping area */
+	V4L2_SEL_TGT_CROP_DEFAULT    = 0x0001 /* Default cropping area */
+	V4L2_SEL_TGT_CROP_BOUNDS     = 0x0002 /* Cropping bounds */
+	V4L2_SEL_TGT_NATIVE_SIZE     = 0x0003 /* Native frame size */
+	V4L2_SEL_TGT_COMPOSE         = 0x0100 /* Current composing area */
+	V4L2_SEL_TGT_COMPOSE_DEFAULT = 0x0101 /* Default composing area */
+	V4L2_SEL_TGT_COMPOSE_BOUNDS  = 0x0102 /* Composing bounds */
+	V4L2_SEL_TGT_COMPOSE_PADDED  = 0x0103 /* Current composing area plus all padding pixels */
+)
+
+/* Selection flags */
+const (
+	V4L2_SEL_FLAG_GE          = 1 << 0
+	V4L2_SEL_FLAG_LE          = 1 << 1
+	V4L2_SEL_FLAG_KEEP_CONFIG = 1 << 2
+)
+
+/*
+ *	A N A L O G   V I D E O   S T A N D A R D
+ */
+
+/* one bit for each */
+const (
+	V4L2_STD_PAL_B  = 0x00000001
+	V4L2_STD_PAL_B1 = 0x00000002
+	V4L2_STD_PAL_G  = 0x00000004
+	V4L2_STD_PAL_H  = 0x00000008
+	V4L2_STD_PAL_I  = 0x00000010
+	V4L2_STD_PAL_D  = 0x00000020
+	V4L2_STD_PAL_D1 = 0x00000040
+	V4L2_STD_PAL_K  = 0x00000080
+
+	V4L2_STD_PAL_M  = 0x00000100
+	V4L2_STD_PAL_N  = 0x00000200
+	V4L2_STD_PAL_Nc = 0x00000400
+	V4L2_STD_PAL_60 = 0x00000800
+
+	V4L2_STD_NTSC_M    = 0x00001000 /* BTSC */
+	V4L2_STD_NTSC_M_JP = 0x00002000 /* EIA-J */
+	V4L2_STD_NTSC_443  = 0x00004000
+	V4L2_STD_NTSC_M_KR = 0x00008000 /* FM A2 */
+
+	V4L2_STD_SECAM_B  = 0x00010000
+	V4L2_STD_SECAM_D  = 0x00020000
+	V4L2_STD_SECAM_G  = 0x00040000
+	V4L2_STD_SECAM_H  = 0x00080000
+	V4L2_STD_SECAM_K  = 0x00100000
+	V4L2_STD_SECAM_K1 = 0x00200000
+	V4L2_STD_SECAM_L  = 0x00400000
+	V4L2_STD_SECAM_LC = 0x00800000
+
+	/* ATSC/HDTV */
+	V4L2_STD_ATSC_8_VSB  = 0x01000000
+	V4L2_STD_ATSC_16_VSB = 0x02000000
+)
+
+/* some merged standards */
+const (
+	V4L2_STD_NTSC     = V4L2_STD_NTSC_M | V4L2_STD_NTSC_M_JP | V4L2_STD_NTSC_M_KR
+	V4L2_STD_SECAM_DK = V4L2_STD_SECAM_D | V4L2_STD_SECAM_K | V4L2_STD_SECAM_K1
+	V4L2_STD_SECAM    = V4L2_STD_SECAM_B | V4L2_STD_SECAM_G | V4L2_STD_SECAM_H | V4L2_STD_SECAM_DK | V4L2_STD_SECAM_L | V4L2_STD_SECAM_LC
+
+	V4L2_STD_PAL_BG = V4L2_STD_PAL_B | V4L2_STD_PAL_B1 | V4L2_STD_PAL_G
+	V4L2_STD_PAL_DK = V4L2_STD_PAL_D | V4L2_STD_PAL_D1 | V4L2_STD_PAL_K
+	V4L2_STD_PAL    = V4L2_STD_PAL_BG | V4L2_STD_PAL_DK | V4L2_STD_PAL_H | V4L2_STD_PAL_I
+
+	V4L2_STD_B  = V4L2_STD_PAL_B | V4L2_STD_PAL_B1 | V4L2_STD_SECAM_B
+	V4L2_STD_G  = V4L2_STD_PAL_G | V4L2_STD_SECAM_G
+	V4L2_STD_H  = V4L2_STD_PAL_H | V4L2_STD_SECAM_H
+	V4L2_STD_L  = V4L2_STD_SECAM_L | V4L2_STD_SECAM_LC
+	V4L2_STD_GH = V4L2_STD_G | V4L2_STD_H
+	V4L2_STD_DK = V4L2_STD_PAL_DK | V4L2_STD_SECAM_DK
+	V4L2_STD_BG = V4L2_STD_B | V4L2_STD_G
+	V4L2_STD_MN = V4L2_STD_PAL_M | V4L2_STD_PAL_N | V4L2_STD_PAL_Nc | V4L2_STD_NTSC
+
+	/* Standards with mono sound */
+	V4L2_STD_MTS = V4L2_STD_NTSC_M | V4L2_STD_PAL_M | V4L2_STD_PAL_N | V4L2_STD_PAL_Nc
+
+	/* Standards where MTS is not used */
+	V4L2_STD_525_60 = V4L2_STD_PAL_M | V4L2_STD_PAL_60 | V4L2_STD_NTSC | V4L2_STD_NTSC_443
+	V4L2_STD_625_50 = V4L2_STD_PAL | V4L2_STD_PAL_N | V4L2_STD_PAL_Nc | V4L2_STD_SECAM
+	V4L2_STD_ATSC   = V4L2_STD_ATSC_8_VSB | V4L2_STD_ATSC_16_VSB
+
+	V4L2_STD_UNKNOWN = 0
+	V4L2_STD_ALL     = V4L2_STD_525_60 | V4L2_STD_625_50
+)
+
+/*
+ * The id is aligned to 8 bytes except on 386, arm pads the struct to 8 bytes before
+ * the reserved words.
+ */
+type V4l2Standard struct {
+	Index       uint32
+	_           pad64
+	Id          uint64 /* v4l2_std_id */
+	Name        [24]uint8
+	Frameperiod V4l2Fract /* Frames, not fields */
+	Framelines  uint32
+	_           pad64
+	Reserved    [4]uint32
+}
+
+/*
+ *	D V	B T	T I M I N G S
+ */
+
+/*
+ * BT.656/BT.1120 timing data. The kernel struct is packed, the pixel clock is kept in two
+ * words to keep the 4 byte alignment of the struct inside V4l2DvTimings.
+ */
+type V4l2BtTimings struct {
+	Width         uint32
+	Height        uint32
+	Interlaced    uint32
+	Polarities    uint32
+	pixelclock    [2]uint32 /* __u64 pixelclock */
+	Hfrontporch   uint32
+	Hsync         uint32
+	Hbackporch    uint32
+	Vfrontporch   uint32
+	Vsync         uint32
+	Vbackporch    uint32
+	IlVfrontporch uint32
+	IlVsync       uint32
+	IlVbackporch  uint32
+	Standards     uint32
+	Flags         uint32
+	PictureAspect V4l2Fract
+	Cea861Vic     uint8
+	HdmiVic       uint8
+	Reserved      [46]uint8
+}
+
+/*
+ * Pixel clock in Hz, the words are in the byte order of the supported little-endian architectures
+ */
+func (t *V4l2BtTimings) Pixelclock() uint64 {
+	return uint64(t.pixelclock[0]) | uint64(t.pixelclock[1])<<32
+}
+
+func (t *V4l2BtTimings) SetPixelclock(pixelclock uint64) {
+	t.pixelclock[0] = uint32(pixelclock)
+	t.pixelclock[1] = uint32(pixelclock >> 32)
+}
+
+/* Interlaced or progressive format */
+const (
+	V4L2_DV_PROGRESSIVE = 0
+	V4L2_DV_INTERLACED  = 1
+)
+
+/* Polarities. If bit is not set, it is assumed to be negative polarity */
+const (
+	V4L2_DV_VSYNC_POS_POL = 0x00000001
+	V4L2_DV_HSYNC_POS_POL = 0x00000002
+)
+
+/* Timings standards */
+const (
+	V4L2_DV_BT_STD_CEA861 = 1 << 0 /* CEA-861 Digital TV Profile */
+	V4L2_DV_BT_STD_DMT    = 1 << 1 /* VESA Discrete Monitor Timings */
+	V4L2_DV_BT_STD_CVT    = 1 << 2 /* VESA Coordinated Video Timings */
+	V4L2_DV_BT_STD_GTF    = 1 << 3 /* VESA Generalized Timings Formula */
+	V4L2_DV_BT_STD_SDI    = 1 << 4 /* SDI Timings */
+)
+
+/* Flags */
+const (
+	V4L2_DV_FL_REDUCED_BLANKING       = 1 << 0
+	V4L2_DV_FL_CAN_REDUCE_FPS         = 1 << 1
+	V4L2_DV_FL_REDUCED_FPS            = 1 << 2
+	V4L2_DV_FL_HALF_LINE              = 1 << 3
+	V4L2_DV_FL_IS_CE_VIDEO            = 1 << 4
+	V4L2_DV_FL_FIRST_FIELD_EXTRA_LINE = 1 << 5
+	V4L2_DV_FL_HAS_PICTURE_ASPECT     = 1 << 6
+	V4L2_DV_FL_HAS_CEA861_VIC         = 1 << 7
+	V4L2_DV_FL_HAS_HDMI_VIC           = 1 << 8
+	V4L2_DV_FL_CAN_DETECT_REDUCED_FPS = 1 << 9
+)
+
+/* BT.656/1120 timing type */
+const V4L2_DV_BT_656_1120 = 0
+
+/*
+ * The union of the kernel struct also holds __u32 reserved[32], it is 4 bytes longer than
+ * the timings.
+ */
+type V4l2DvTimings struct {
+	Type uint32
+	Bt   V4l2BtTimings
+	_    [4]uint8
+}
+
+/*
+ * Used in the VIDIOC_ENUM_DV_TIMINGS ioctl
+ */
+type V4l2EnumDvTimings struct {
+	Index    uint32
+	Pad      uint32
+	Reserved [2]uint32
+	Timings  V4l2DvTimings
+}
+
+/*
+ *	V I D E O   I N P U T S
+ */
+
+/*
+ * The EABI pads the struct to 8 bytes on arm, the padding is made up for before the
+ * reserved words. On 386 it ends after them.
+ */
+type V4l2Input struct {
+	Index        uint32    /*  Which input */
+	Name         [32]uint8 /*  Label */
+	Type         uint32    /*  Type of input */
+	Audioset     uint32    /*  Associated audios (bitfield) */
+	Tuner        uint32    /*  Tuner index */
+	Std          uint64    /*  v4l2_std_id */
+	Status       uint32
+	Capabilities uint32
+	_            pad64
+	Reserved     [3]uint32
+}
+
+/*  Values for the 'type' field */
+const (
+	V4L2_INPUT_TYPE_TUNER  = 1
+	V4L2_INPUT_TYPE_CAMERA = 2
+	V4L2_INPUT_TYPE_TOUCH  = 3
+)
+
+/* field 'status' - general */
+const (
+	V4L2_IN_ST_NO_POWER  = 0x00000001 /* Attached device is off */
+	V4L2_IN_ST_NO_SIGNAL = 0x00000002
+	V4L2_IN_ST_NO_COLOR  = 0x00000004
+)
+
+/* field 'status' - sensor orientation */
+/* If sensor is mounted upside down set both bits */
+const (
+	V4L2_IN_ST_HFLIP = 0x00000010 /* Frames are flipped horizontally */
+	V4L2_IN_ST_VFLIP = 0x00000020 /* Frames are flipped vertically */
+)
+
+/* field 'status' - analog */
+const (
+	V4L2_IN_ST_NO_H_LOCK   = 0x00000100 /* No horizontal sync lock */
+	V4L2_IN_ST_COLOR_KILL  = 0x00000200 /* Color killer is active */
+	V4L2_IN_ST_NO_V_LOCK   = 0x00000400 /* No vertical sync lock */
+	V4L2_IN_ST_NO_STD_LOCK = 0x00000800 /* No standard format lock */
+)
+
+/* field 'status' - digital */
+const (
+	V4L2_IN_ST_NO_SYNC    = 0x00010000 /* No synchronization lock */
+	V4L2_IN_ST_NO_EQU     = 0x00020000 /* No equalizer lock */
+	V4L2_IN_ST_NO_CARRIER = 0x00040000 /* Carrier recovery failed */
+)
+
+/* field 'status' - VCR and set-top box */
+const (
+	V4L2_IN_ST_MACROVISION = 0x01000000 /* Macrovision detected */
+	V4L2_IN_ST_NO_ACCESS   = 0x02000000 /* Conditional access denied */
+	V4L2_IN_ST_VTR         = 0x04000000 /* VTR time constant */
+)
+
+/* capabilities flags */
+const (
+	V4L2_IN_CAP_DV_TIMINGS     = 0x00000002 /* Supports S_DV_TIMINGS */
+	V4L2_IN_CAP_CUSTOM_TIMINGS = V4L2_IN_CAP_DV_TIMINGS
+	V4L2_IN_CAP_STD            = 0x00000004 /* Supports S_STD */
+	V4L2_IN_CAP_NATIVE_SIZE    = 0x00000008 /* Supports setting native size */
+)
+
+/*
+ *	E V E N T S
+ */
+const (
+	V4L2_EVENT_ALL           = 0
+	V4L2_EVENT_VSYNC         = 1
+	V4L2_EVENT_EOS           = 2
+	V4L2_EVENT_CTRL          = 3
+	V4L2_EVENT_FRAME_SYNC    = 4
+	V4L2_EVENT_SOURCE_CHANGE = 5
+	V4L2_EVENT_MOTION_DET    = 6
+	V4L2_EVENT_PRIVATE_START = 0x08000000
+)
+
+/* Payload for V4L2_EVENT_CTRL */
+const (
+	V4L2_EVENT_CTRL_CH_VALUE = 1 << 0
+	V4L2_EVENT_CTRL_CH_FLAGS = 1 << 1
+	V4L2_EVENT_CTRL_CH_RANGE = 1 << 2
+)
+
+type V4l2EventCtrl struct {
+	Changes uint32
+	Type    uint32
+	value   [8]byte
+	/*
+		union {
+			__s32 value;
+			__s64 value64;
+		};*/
+	Flags        uint32
+	Minimum      int32
+	Maximum      int32
+	Step         int32
+	DefaultValue int32
+}
+
+func (c *V4l2EventCtrl) Value() int32 {
+	var value int32
+	copy((*[4]byte)(unsafe.Pointer(&value))[:], c.value[:4])
+	return value
+}
+
+func (c *V4l2EventCtrl) SetValue(value int32) {
+	copy(c.value[:4], (*[4]byte)(unsafe.Pointer(&value))[:])
+}
+
+func (c *V4l2EventCtrl) Value64() int64 {
+	var value int64
+	copy((*[8]byte)(unsafe.Pointer(&value))[:], c.value[:])
+	return value
+}
+
+func (c *V4l2EventCtrl) SetValue64(value int64) {
+	copy(c.value[:], (*[8]byte)(unsafe.Pointer(&value))[:])
+}
+
+type V4l2EventFrameSync struct {
+	FrameSequence uint32
+}
+
+const V4L2_EVENT_SRC_CH_RESOLUTION = 1 << 0
+
+type V4l2EventSrcChange struct {
+	Changes uint32
+}
+
+const V4L2_EVENT_MD_FL_HAVE_FRAME_SEQ = 1 << 0
+
+type V4l2EventMotionDet struct {
+	Flags         uint32
+	FrameSequence uint32
+	RegionMask    uint32
+}
+
+/*
+ * The payload union holds a 64-bit value, it is aligned to 8 bytes except on 386.
+ * The padding arm needs in front of it is made up for before the reserved words.
+ */
+type V4l2Event struct {
+	Type      uint32
+	_         pad64
+	u         eventUnion
+	Pending   uint32
+	Sequence  uint32
+	timestamp V4l2Timespec //struct timespec	timestamp;
+	Id        uint32
+	_         pad64
+	Reserved  [8]uint32
+}
+
+type eventUnion struct {
+	_   [0]uint64
+	raw [64]byte
+	/*
+		union {
+			struct v4l2_event_vsync		vsync;
+			struct v4l2_event_ctrl		ctrl;
+			struct v4l2_event_frame_sync	frame_sync;
+			struct v4l2_event_src_change	src_change;
+			struct v4l2_event_motion_det	motion_det;
+			__u8				data[64];
+		} u;*/
+}
+
+/*
+ * Field of V4L2_EVENT_VSYNC, V4L2_FIELD_*
+ */
+func (e *V4l2Event) VsyncField() uint8 {
+	return e.u.raw[0]
+}
+
+func (e *V4l2Event) Ctrl() *V4l2EventCtrl {
+	return (*V4l2EventCtrl)(unsafe.Pointer(&e.u.raw))
+}
+
+func (e *V4l2Event) FrameSync() *V4l2EventFrameSync {
+	return (*V4l2EventFrameSync)(unsafe.Pointer(&e.u.raw))
+}
+
+func (e *V4l2Event) SrcChange() *V4l2EventSrcChange {
+	return (*V4l2EventSrcChange)(unsafe.Pointer(&e.u.raw))
+}
+
+func (e *V4l2Event) MotionDet() *V4l2EventMotionDet {
+	return (*V4l2EventMotionDet)(unsafe.Pointer(&e.u.raw))
+}
+
+/*
+ * Raw payload, used by private events
+ */
+func (e *V4l2Event) Data() *[64]byte {
+	return &e.u.raw
+}
+
+/*
+ * Returns the timestamp of the event as seconds and nanoseconds of CLOCK_MONOTONIC
+ */
+func (e *V4l2Event) Timestamp() (int64, int64) {
+	return int64(e.timestamp.Sec), int64(e.timestamp.Nsec)
+}
+
+func (e *V4l2Event) SetTimestamp(sec int64, nsec int64) {
+	e.timestamp = V4l2Timespec{v4l2Long(sec), v4l2Long(nsec)}
+}
+
+/* struct timespec */
+type V4l2Timespec struct {
+	Sec  v4l2Long
+	Nsec v4l2Long
+}
+
+const (
+	V4L2_EVENT_SUB_FL_SEND_INITIAL   = 1 << 0
+	V4L2_EVENT_SUB_FL_ALLOW_FEEDBACK = 1 << 1
+)
+
+type V4l2EventSubscription struct {
+	Type     uint32
+	Id       uint32
+	Flags    uint32
+	Reserved [5]uint32
 }
@@ -0,0 +1,14 @@
+//go:build 386 || arm
+
+package v4l2
+
+/*
+ * C types whose width differs between architectures, ILP32 layout. The classic
+ * V4L2 ioctls use a 32-bit time_t here.
+ */
+
+/* long */
+type v4l2Long = int32
+
+/* unsigned long, also the width of pointers in unions */
+type v4l2Ulong = uint32
@@ -0,0 +1,13 @@
+//go:build amd64 || arm64
+
+package v4l2
+
+/*
+ * C types whose width differs between architectures, LP64 layout
+ */
+
+/* long */
+type v4l2Long = int64
+
+/* unsigned long, also the width of pointers in unions */
+type v4l2Ulong = uint64
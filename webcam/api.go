@@ -1,16 +1,31 @@
 package webcam
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"math"
+	"strings"
+	"syscall"
+	"time"
 	"v4l2"
 	"v4l2/ioctl"
 )
 
+/*
+* Categories of device failures, test them by errors.Is
+ */
+var (
+	ErrBusy          = ioctl.ErrBusy
+	ErrUnsupported   = ioctl.ErrUnsupported
+	ErrDisconnected  = ioctl.ErrDisconnected
+	ErrInvalidFormat = ioctl.ErrInvalidFormat
+	ErrTimeout       = ioctl.ErrTimeout
+)
+
 func OpenVideoDevice(path string) (VideoDevice, error) {
-	file, err := os.OpenFile(path, os.O_RDWR, 0666)
+	file, err := ioctl.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK)
 
 	log.Printf("Opening device %s\n", path)
 
@@ -22,23 +37,116 @@ func OpenVideoDevice(path string) (VideoDevice, error) {
 	cap, err := ioctl.QueryCapability(file.Fd())
 
 	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	capability := v4l2Capability{cap}
+
+	bufType, ok := bufferTypeOf(capability)
+
+	if !ok {
+		file.Close()
+		return nil, fmt.Errorf("Device %s is not a video capturing device: %w", file.Name(), ErrUnsupported)
+	}
+
+	ioMethod, ok := ioMethodOf(capability, bufType)
+
+	if !ok {
+		file.Close()
+		return nil, fmt.Errorf("Device %s is not able to stream or read frames: %w", file.Name(), ErrUnsupported)
+	}
+
+	var dev *device = &device{file, capability, bufType, ioMethod, supportedFormats{file}, &framesizes{file}, &frameintervals{file}, &controls{file}, &inputs{file}, &standards{file}, &dvTimings{file}, &crop{file, bufType}, &jpegQuality{file}, nil}
+	dev.negotiator = &negotiator{file, bufType, dev.formats, dev.intervals}
+
+	log.Printf("Device %s is a video device using %v I/O", file.Name(), ioMethod)
+	return dev, nil
+}
+
+/*
+* Opens a video output device like v4l2loopback, the frames written to it are passed
+* on to the applications capturing from it. Output needs streaming I/O.
+ */
+func OpenOutputDevice(path string) (OutputDevice, error) {
+	file, err := ioctl.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK)
+
+	log.Printf("Opening output device %s\n", path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Reading capability")
+	cap, err := ioctl.QueryCapability(file.Fd())
+
+	if err != nil {
+		file.Close()
 		return nil, err
 	}
 
-	var dev *device = &device{file, v4l2Capability{cap}, supportedFormats{file}, &framesizes{file}, &camera{file}}
+	capability := v4l2Capability{cap}
+
+	bufType, ok := outputBufferTypeOf(capability)
 
-	if !dev.Capability().HasCapability(v4l2.V4L2_CAP_VIDEO_CAPTURE) {
-		return nil, errors.New(fmt.Sprintf("Device %s is not a video capturing device.", dev.Name()))
+	if !ok {
+		file.Close()
+		return nil, fmt.Errorf("Device %s is not a video output device: %w", file.Name(), ErrUnsupported)
 	}
 
-	if !dev.Capability().HasCapability(v4l2.V4L2_CAP_STREAMING) {
-		return nil, errors.New(fmt.Sprintf("Device %s is not able to stream frames.", dev.Name()))
+	if !capability.HasCapability(v4l2.V4L2_CAP_STREAMING) {
+		file.Close()
+		return nil, fmt.Errorf("Device %s is not able to stream frames: %w", file.Name(), ErrUnsupported)
 	}
 
-	log.Printf("Device %s is a video device", file.Name())
+	var dev *outputDevice = &outputDevice{file, capability, bufType, supportedFormats{file}, &framesizes{file}, &controls{file}}
+
+	log.Printf("Device %s is a video output device", file.Name())
 	return dev, nil
 }
 
+/*
+* Single-planar capture is preferred by devices which support both
+ */
+func bufferTypeOf(capability Capability) (uint32, bool) {
+	if capability.HasCapability(v4l2.V4L2_CAP_VIDEO_CAPTURE) {
+		return v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE, true
+	}
+
+	if capability.HasCapability(v4l2.V4L2_CAP_VIDEO_CAPTURE_MPLANE) {
+		return v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE, true
+	}
+
+	return 0, false
+}
+
+func outputBufferTypeOf(capability Capability) (uint32, bool) {
+	if capability.HasCapability(v4l2.V4L2_CAP_VIDEO_OUTPUT) {
+		return v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT, true
+	}
+
+	if capability.HasCapability(v4l2.V4L2_CAP_VIDEO_OUTPUT_MPLANE) {
+		return v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE, true
+	}
+
+	return 0, false
+}
+
+/*
+* Streaming is preferred, read I/O is used by single-planar devices which do not support it
+ */
+func ioMethodOf(capability Capability, bufType uint32) (IOMethod, bool) {
+	if capability.HasCapability(v4l2.V4L2_CAP_STREAMING) {
+		return IO_METHOD_MMAP, true
+	}
+
+	if capability.HasCapability(v4l2.V4L2_CAP_READWRITE) && !multiPlanar(bufType) {
+		return IO_METHOD_READ, true
+	}
+
+	return 0, false
+}
+
 //-------------------------------------------------------------------------
 //MAIN INTERFACE
 //-------------------------------------------------------------------------
@@ -46,12 +154,82 @@ func OpenVideoDevice(path string) (VideoDevice, error) {
 type VideoDevice interface {
 	Name() string
 	Capability() Capability
+	/* way the frames are transferred, chosen by the capabilities of the device */
+	IOMethod() IOMethod
+	/* V4L2_BUF_TYPE_VIDEO_CAPTURE or V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE for multi-planar devices */
+	BufferType() uint32
+	Formats() SupportedFormats
+	FrameSizes() FrameSizes
+	FrameIntervals() FrameIntervals
+	Controls() Controls
+	/* video inputs, capture cards switch among connectors and webcams among sensors */
+	Inputs() Inputs
+	/* analog video standards of the current input */
+	Standards() Standards
+	/* digital video timings of the current input of HDMI and SDI receivers */
+	DVTimings() DVTimings
+	/* capture rectangle on the sensor, a new format may reset it */
+	Crop() Crop
+	/* compression quality of JPEG and MJPEG frames */
+	JPEGQuality() JPEGQuality
+	CurrentFormat() (Format, error)
+	Negotiate(prefs FormatPreferences) (Configuration, error)
+	/*
+	* Takes a snapshot set up like a stream, with JPEG quality, crop or I/O method, and gives
+	* up when the context ends. A single buffer is requested unless the config asks for more.
+	* The other TakeSnapshot methods are shortcuts for a frame size and pixel format and are
+	* set up the same way.
+	 */
+	TakeSnapshotConfig(ctx context.Context, config StreamConfig) (Snapshot, error)
+	TakeSnapshot(frameSize *DiscreteFrameSize, pixelFormat uint32) (Snapshot, error)
+	TakeSnapshotContext(ctx context.Context, frameSize *DiscreteFrameSize, pixelFormat uint32) (Snapshot, error)
+	/* the snapshot is not copied and valid only within the handler */
+	TakeSnapshotAsync(frameSize *DiscreteFrameSize, pixelFormat uint32, handler SnapshotHandler) error
+	TakeSnapshotChan(frameSize *DiscreteFrameSize, pixelFormat uint32, ch chan Snapshot) error
+	Stream(ctx context.Context, config StreamConfig) (<-chan Snapshot, <-chan error)
+	/*
+	* Subscribes the events of the config and delivers them until the context ends or
+	* the device fails, both channels are closed then. Changes made through this device
+	* are reported as well.
+	 */
+	Events(ctx context.Context, config EventConfig) (<-chan Event, <-chan error)
+	Close() error
+}
+
+type OutputDevice interface {
+	Name() string
+	Capability() Capability
+	/* V4L2_BUF_TYPE_VIDEO_OUTPUT or V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE for multi-planar devices */
+	BufferType() uint32
 	Formats() SupportedFormats
 	FrameSizes() FrameSizes
-	TakeSnapshot(frameSize *DiscreteFrameSize) (Snapshot, error)
-	TakeSnapshotAsync(frameSize *DiscreteFrameSize, handler SnapshotHandler) error
-	TakeSnapshotChan(frameSize *DiscreteFrameSize, ch chan Snapshot)
-	Stream(framesize *DiscreteFrameSize, tick chan bool, snapshots chan<- Snapshot)
+	Controls() Controls
+	CurrentFormat() (Format, error)
+	/* asks the driver which format it would choose without changing the device state */
+	TryFormat(frameSize *DiscreteFrameSize, pixelFormat uint32) (Format, error)
+	/* sets the format up and starts streaming, frames are passed to the returned writer */
+	NewWriter(config OutputConfig) (FrameWriter, error)
+	/*
+	* Writes the frames received from the channel until it is closed or the context ends.
+	* The error channel is closed when streaming ends, ending the context is not reported.
+	 */
+	Stream(ctx context.Context, config OutputConfig, frames <-chan []byte) <-chan error
+	Close() error
+}
+
+/*
+* Writer of an output stream, every write passes one whole frame. Frames of multi-planar
+* formats are split among the planes by the image sizes of the format.
+ */
+type FrameWriter interface {
+	/* format set up by the driver */
+	Format() Format
+	FrameInterval() Fraction
+	/* writes a frame, blocks while the driver holds all buffers up to the frame timeout */
+	Write(frame []byte) (int, error)
+	/* like Write, gives up when the context ends as well */
+	WriteContext(ctx context.Context, frame []byte) error
+	/* stops streaming and frees the buffers, a blocked write returns */
 	Close() error
 }
 
@@ -63,16 +241,493 @@ type Capability interface {
 	HasCapability(cap uint32) bool
 }
 
+/*
+* Method of transferring frames from the driver
+ */
+type IOMethod uint32
+
+const (
+	/* driver buffers mapped into the process, V4L2_CAP_STREAMING */
+	IO_METHOD_MMAP IOMethod = iota + 1
+	/* frames copied by read(), V4L2_CAP_READWRITE */
+	IO_METHOD_READ
+	/* streaming into memory of the application, see AllocateUserBuffer */
+	IO_METHOD_USERPTR
+	/* streaming into DMABUFs imported from another device or allocator */
+	IO_METHOD_DMABUF
+)
+
+func (m IOMethod) String() string {
+	switch m {
+	case IO_METHOD_MMAP:
+		return "mmap"
+	case IO_METHOD_READ:
+		return "read"
+	case IO_METHOD_USERPTR:
+		return "userptr"
+	case IO_METHOD_DMABUF:
+		return "dmabuf"
+	}
+	return fmt.Sprintf("IOMethod(%d)", uint32(m))
+}
+
 type SupportedFormats interface {
+	All(bufType uint32) ([]PixelFormat, error)
 	Supports(bufType uint32, format uint32) (bool, error)
 }
 
+type PixelFormat struct {
+	Format      uint32
+	Description string
+	Flags       uint32
+}
+
+func (f PixelFormat) Compressed() bool {
+	return (f.Flags & v4l2.V4L2_FMT_FLAG_COMPRESSED) > 0
+}
+
+func (f PixelFormat) Emulated() bool {
+	return (f.Flags & v4l2.V4L2_FMT_FLAG_EMULATED) > 0
+}
+
+func (f PixelFormat) FourCC() string {
+	return FourCC(f.Format)
+}
+
+func (f PixelFormat) String() string {
+	return fmt.Sprintf("PixelFormat[%s,%s,compressed=%t,emulated=%t]", f.FourCC(), f.Description, f.Compressed(), f.Emulated())
+}
+
+/*
+* Converts pixel format code to its four character representation, e.g. MJPG
+ */
+func FourCC(format uint32) string {
+	code := []byte{byte(format), byte(format >> 8), byte(format >> 16), byte(format >> 24 & 0x7f)}
+	return strings.TrimRight(string(code), " ")
+}
+
+/*
+* Converts four character code to the pixel format, the opposite of FourCC
+ */
+func ParseFourCC(code string) (uint32, error) {
+	if len(code) == 0 || len(code) > 4 {
+		return 0, errors.New(fmt.Sprintf("Invalid fourcc code '%s'", code))
+	}
+
+	padded := []byte(code + "    ")[:4]
+	return uint32(padded[0]) | uint32(padded[1])<<8 | uint32(padded[2])<<16 | uint32(padded[3])<<24, nil
+}
+
+/*
+* Pixel format used when none is requested: MJPEG or JPEG if the device offers one of them,
+* the first format it lists otherwise
+ */
+func DefaultPixelFormat(formats SupportedFormats, bufType uint32) (uint32, error) {
+	all, err := formats.All(bufType)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(all) == 0 {
+		return 0, fmt.Errorf("No pixel format offered for buffer type %d: %w", bufType, ErrInvalidFormat)
+	}
+
+	for _, preferred := range []uint32{v4l2.V4L2_PIX_FMT_MJPEG, v4l2.V4L2_PIX_FMT_JPEG} {
+		for _, format := range all {
+			if format.Format == preferred {
+				return preferred, nil
+			}
+		}
+	}
+
+	return all[0].Format, nil
+}
+
 type FrameSizes interface {
+	All(format uint32) ([]FrameSizeRange, error)
+	Supports(format uint32, width uint32, height uint32) (bool, error)
+	/* the discrete sizes only, devices with stepwise or continuous sizes have none */
 	AllDiscrete(format uint32) ([]DiscreteFrameSize, error)
 	AllDiscreteMJPEG() ([]DiscreteFrameSize, error)
 	SupportsDiscrete(format uint32, width uint32, height uint32) (bool, error)
 }
 
+/*
+* Range of frame sizes supported by a device. Discrete sizes have equal minimum and maximum
+* and no step, continuous ranges have step 1.
+ */
+type FrameSizeRange struct {
+	Type       uint32
+	MinWidth   uint32
+	MaxWidth   uint32
+	StepWidth  uint32
+	MinHeight  uint32
+	MaxHeight  uint32
+	StepHeight uint32
+}
+
+func (r FrameSizeRange) Contains(width uint32, height uint32) bool {
+	return contains(r.MinWidth, r.MaxWidth, r.StepWidth, width) && contains(r.MinHeight, r.MaxHeight, r.StepHeight, height)
+}
+
+/*
+* Returns the frame size of the range that is closest to the requested one
+ */
+func (r FrameSizeRange) Nearest(width uint32, height uint32) DiscreteFrameSize {
+	return DiscreteFrameSize{nearest(r.MinWidth, r.MaxWidth, r.StepWidth, width), nearest(r.MinHeight, r.MaxHeight, r.StepHeight, height)}
+}
+
+func (r FrameSizeRange) String() string {
+	if r.Type == v4l2.V4L2_FRMSIZE_TYPE_DISCRETE {
+		return fmt.Sprintf("FrameSizeRange[%dx%d]", r.MinWidth, r.MinHeight)
+	}
+	return fmt.Sprintf("FrameSizeRange[%dx%d - %dx%d, step %dx%d]", r.MinWidth, r.MinHeight, r.MaxWidth, r.MaxHeight, r.StepWidth, r.StepHeight)
+}
+
+func contains(min uint32, max uint32, step uint32, value uint32) bool {
+	if value < min || value > max {
+		return false
+	}
+
+	if step == 0 {
+		return value == min
+	}
+
+	return (value-min)%step == 0
+}
+
+func nearest(min uint32, max uint32, step uint32, value uint32) uint32 {
+	if value <= min || step == 0 {
+		return min
+	}
+
+	if value >= max {
+		return max
+	}
+
+	lower := min + (value-min)/step*step
+
+	if value-lower > step/2 && lower+step <= max {
+		return lower + step
+	}
+
+	return lower
+}
+
+type FrameIntervals interface {
+	All(format uint32, width uint32, height uint32) ([]FrameInterval, error)
+}
+
+/*
+* Fraction of two numbers, frame intervals are expressed as time per frame in seconds
+ */
+type Fraction struct {
+	Numerator   uint32
+	Denominator uint32
+}
+
+func (f Fraction) Float() float64 {
+	if f.Denominator == 0 {
+		return 0
+	}
+	return float64(f.Numerator) / float64(f.Denominator)
+}
+
+func (f Fraction) String() string {
+	return fmt.Sprintf("%d/%d", f.Numerator, f.Denominator)
+}
+
+/*
+* Frame interval supported by a device for given pixel format and frame size. Discrete
+* intervals have equal Min and Max and no Step, continuous intervals have Step 1/1.
+ */
+type FrameInterval struct {
+	Type uint32
+	Min  Fraction
+	Max  Fraction
+	Step Fraction
+}
+
+func (i FrameInterval) Contains(interval Fraction) bool {
+	if i.Type == v4l2.V4L2_FRMIVAL_TYPE_DISCRETE {
+		return uint64(interval.Numerator)*uint64(i.Min.Denominator) == uint64(i.Min.Numerator)*uint64(interval.Denominator)
+	}
+
+	value := interval.Float()
+
+	if value < i.Min.Float() || value > i.Max.Float() {
+		return false
+	}
+
+	if i.Type == v4l2.V4L2_FRMIVAL_TYPE_CONTINUOUS || i.Step.Float() == 0 {
+		return true
+	}
+
+	steps := (value - i.Min.Float()) / i.Step.Float()
+	return math.Abs(steps-math.Round(steps)) < 1e-6
+}
+
+func (i FrameInterval) String() string {
+	if i.Type == v4l2.V4L2_FRMIVAL_TYPE_DISCRETE {
+		return fmt.Sprintf("FrameInterval[%v]", i.Min)
+	}
+	return fmt.Sprintf("FrameInterval[min=%v,max=%v,step=%v]", i.Min, i.Max, i.Step)
+}
+
+type Controls interface {
+	All() ([]Control, error)
+	ByID(id uint32) (Control, error)
+	ByName(name string) (Control, error)
+	Get(id uint32) (int64, error)
+	Set(id uint32, value int64) error
+	GetByName(name string) (int64, error)
+	SetByName(name string, value int64) error
+}
+
+/*
+* Video inputs of a device. Snapshots and streams capture from the selected input.
+ */
+type Inputs interface {
+	All() ([]Input, error)
+	/* selected input with its current status */
+	Current() (Input, error)
+	/* selects the input frames are captured from, devices refuse it while streaming */
+	Select(index uint32) error
+}
+
+type Input struct {
+	Index uint32
+	Name  string
+	/* V4L2_INPUT_TYPE_* */
+	Type uint32
+	/* V4L2_IN_ST_* at the time of the query */
+	Status uint32
+	/* V4L2_IN_CAP_* */
+	Capabilities uint32
+	/* audio inputs associated with the input, one bit per index */
+	AudioSet uint32
+	/* index of the tuner of V4L2_INPUT_TYPE_TUNER inputs */
+	Tuner uint32
+	/* analog video standards the input supports, V4L2_STD_* */
+	Std uint64
+}
+
+func (i Input) HasStatus(status uint32) bool {
+	return (i.Status & status) > 0
+}
+
+func (i Input) HasCapability(cap uint32) bool {
+	return (i.Capabilities & cap) > 0
+}
+
+/*
+* Returns an error wrapping ErrNoSignal if the input is unpowered or receives no signal
+ */
+func (i Input) Signal() error {
+	if i.HasStatus(v4l2.V4L2_IN_ST_NO_POWER) {
+		return fmt.Errorf("Input %d (%s) has no power: %w", i.Index, i.Name, ErrNoSignal)
+	}
+
+	if i.HasStatus(v4l2.V4L2_IN_ST_NO_SIGNAL) {
+		return fmt.Errorf("Input %d (%s) receives no signal: %w", i.Index, i.Name, ErrNoSignal)
+	}
+
+	return nil
+}
+
+func (i Input) String() string {
+	return fmt.Sprintf("Input[index=%d,name=%s,type=%d,status=0x%x,capabilities=0x%x]", i.Index, i.Name, i.Type, i.Status, i.Capabilities)
+}
+
+/*
+* Region of interest the driver crops frames to before they reach memory. Drivers with a
+* scaler scale it to the frame size, others shrink the frame size to it. Devices which
+* cannot crop fail with ErrUnsupported.
+ */
+type Crop interface {
+	/* area which can be captured */
+	Bounds() (Rectangle, error)
+	/* rectangle covering the whole picture */
+	Default() (Rectangle, error)
+	Current() (Rectangle, error)
+	/* sets the rectangle up, returns the one the driver adjusted it to */
+	Set(rect Rectangle) (Rectangle, error)
+	/* sets the default rectangle up again */
+	Reset() (Rectangle, error)
+	/* crops the default rectangle around its centre by the factor, a digital zoom in hardware */
+	Zoom(factor float64) (Rectangle, error)
+}
+
+/*
+* JPEG compression quality from 1 to 100, drivers adjust it to the levels they support.
+* Devices without a quality setting fail with ErrUnsupported.
+ */
+type JPEGQuality interface {
+	Get() (int, error)
+	/* requests the quality, returns the one the device set up */
+	Set(quality int) (int, error)
+}
+
+type Rectangle struct {
+	Left   int32
+	Top    int32
+	Width  uint32
+	Height uint32
+}
+
+func (r Rectangle) String() string {
+	return fmt.Sprintf("Rectangle[%dx%d+%d+%d]", r.Width, r.Height, r.Left, r.Top)
+}
+
+/*
+* Analog video standards, v4l2_std_id values are sets of V4L2_STD_* bits. Inputs without
+* standards fail with ErrUnsupported.
+ */
+type Standards interface {
+	/* standards the current input supports */
+	All() ([]Standard, error)
+	/* standards set up for the current input */
+	Current() (uint64, error)
+	/* sets the standard up, the driver resets the format to its geometry */
+	Set(id uint64) error
+	/* senses the standards of the incoming signal, fails with ErrNoSignal if there is none */
+	Detect() (uint64, error)
+	/* sets up the detected standards and returns the ones the driver chose */
+	AutoDetect() (uint64, error)
+}
+
+type Standard struct {
+	Index uint32
+	/* V4L2_STD_* bits */
+	ID          uint64
+	Name        string
+	FramePeriod Fraction
+	FrameLines  uint32
+}
+
+func (s Standard) String() string {
+	return fmt.Sprintf("Standard[name=%s,id=0x%x,period=%v,lines=%d]", s.Name, s.ID, s.FramePeriod, s.FrameLines)
+}
+
+/*
+* Frame size of the digitized standards, 720x480 for the 525 line and 720x576 for the
+* 625 line ones. Sets mixing both have none.
+ */
+func StandardFrameSize(id uint64) (DiscreteFrameSize, bool) {
+	switch {
+	case id == v4l2.V4L2_STD_UNKNOWN:
+		return DiscreteFrameSize{}, false
+	case id&^v4l2.V4L2_STD_525_60 == 0:
+		return DiscreteFrameSize{Width: 720, Height: 480}, true
+	case id&^v4l2.V4L2_STD_625_50 == 0:
+		return DiscreteFrameSize{Width: 720, Height: 576}, true
+	}
+	return DiscreteFrameSize{}, false
+}
+
+/*
+* BT.656/BT.1120 timings of digital video receivers. They have to be set up before streaming,
+* inputs without them fail with ErrUnsupported.
+ */
+type DVTimings interface {
+	/* timings the receiver supports */
+	All() ([]DVTiming, error)
+	/* timings set up for the current input */
+	Current() (DVTiming, error)
+	/* sets the timings up, the driver resets the format to their frame size */
+	Set(timing DVTiming) error
+	/* senses the timings of the incoming signal, fails with ErrNoSignal if there is no stable one */
+	Detect() (DVTiming, error)
+	/* sets up the detected timings and returns the ones set up */
+	AutoDetect() (DVTiming, error)
+}
+
+type DVTiming struct {
+	Width      uint32
+	Height     uint32
+	Interlaced bool
+	/* V4L2_DV_*SYNC_POS_POL */
+	Polarities uint32
+	/* in Hz */
+	PixelClock    uint64
+	HFrontPorch   uint32
+	HSync         uint32
+	HBackPorch    uint32
+	VFrontPorch   uint32
+	VSync         uint32
+	VBackPorch    uint32
+	ILVFrontPorch uint32
+	ILVSync       uint32
+	ILVBackPorch  uint32
+	/* V4L2_DV_BT_STD_* */
+	Standards uint32
+	/* V4L2_DV_FL_* */
+	Flags         uint32
+	PictureAspect Fraction
+	CEA861VIC     uint8
+	HDMIVIC       uint8
+}
+
+func (t DVTiming) FrameSize() DiscreteFrameSize {
+	return DiscreteFrameSize{Width: t.Width, Height: t.Height}
+}
+
+/*
+* Frames per second given by the pixel clock and the blanking intervals
+ */
+func (t DVTiming) FrameRate() float64 {
+	width := uint64(t.Width + t.HFrontPorch + t.HSync + t.HBackPorch)
+	height := uint64(t.Height + t.VFrontPorch + t.VSync + t.VBackPorch)
+
+	if t.Interlaced {
+		height += uint64(t.ILVFrontPorch + t.ILVSync + t.ILVBackPorch)
+	}
+
+	if width == 0 || height == 0 {
+		return 0
+	}
+
+	return float64(t.PixelClock) / float64(width*height)
+}
+
+func (t DVTiming) String() string {
+	scan := "p"
+
+	if t.Interlaced {
+		scan = "i"
+	}
+
+	return fmt.Sprintf("DVTiming[%dx%d%s%.2f,pixelclock=%d]", t.Width, t.Height, scan, t.FrameRate(), t.PixelClock)
+}
+
+type Control struct {
+	ID   uint32
+	Type uint32
+	Name string
+	/* VIDIOC_QUERYCTRL reports no range of V4L2_CTRL_TYPE_INTEGER64 controls, they are not range checked */
+	Minimum int32
+	Maximum int32
+	Step    int32
+	Default int32
+	Flags   uint32
+	Menu    []ControlMenuItem
+}
+
+func (c Control) HasFlag(flag uint32) bool {
+	return (c.Flags & flag) > 0
+}
+
+func (c Control) String() string {
+	return fmt.Sprintf("Control[id=0x%08x,name=%s,type=%d,min=%d,max=%d,step=%d,default=%d,flags=0x%x]", c.ID, c.Name, c.Type, c.Minimum, c.Maximum, c.Step, c.Default, c.Flags)
+}
+
+type ControlMenuItem struct {
+	Index uint32
+	Name  string
+	Value int64
+}
+
 type DiscreteFrameSize struct {
 	Width  uint32
 	Height uint32
@@ -82,10 +737,245 @@ func (d DiscreteFrameSize) String() string {
 	return fmt.Sprintf("DiscreteFrame[%dx%d]", d.Width, d.Height)
 }
 
+/*
+* Image format negotiated with the driver. BytesPerLine and SizeImage describe the first
+* memory plane, Planes all of them.
+ */
+type Format struct {
+	Width        uint32
+	Height       uint32
+	PixelFormat  uint32
+	Field        uint32
+	BytesPerLine uint32
+	SizeImage    uint32
+	Colorspace   uint32
+	/* memory planes, single-planar formats have one */
+	Planes []PlaneFormat
+}
+
+func (f Format) String() string {
+	if len(f.Planes) > 1 {
+		return fmt.Sprintf("Format[%s,%dx%d,planes=%v]", FourCC(f.PixelFormat), f.Width, f.Height, f.Planes)
+	}
+	return fmt.Sprintf("Format[%s,%dx%d,bytesperline=%d,sizeimage=%d]", FourCC(f.PixelFormat), f.Width, f.Height, f.BytesPerLine, f.SizeImage)
+}
+
+type PlaneFormat struct {
+	BytesPerLine uint32
+	SizeImage    uint32
+}
+
+func (p PlaneFormat) String() string {
+	return fmt.Sprintf("Plane[bytesperline=%d,sizeimage=%d]", p.BytesPerLine, p.SizeImage)
+}
+
+type FormatPreferences struct {
+	/* pixel formats in the order of preference, empty means any format of the device */
+	PixelFormats []uint32
+	FrameSize    DiscreteFrameSize
+	/* minimal frames per second, zero accepts any frame rate */
+	MinFrameRate uint32
+}
+
+/*
+* Result of the format negotiation, FrameInterval is the shortest interval the device
+* offers for the format, empty if the driver does not enumerate intervals.
+ */
+type Configuration struct {
+	Format        Format
+	FrameInterval Fraction
+}
+
+type StreamConfig struct {
+	FrameSize DiscreteFrameSize
+	/* pixel format of the frames, zero stands for DefaultPixelFormat of the device */
+	PixelFormat uint32
+	/* requested frames per second, zero keeps the rate the driver is set to */
+	FrameRate uint32
+	/* number of buffers kept queued in the driver, zero stands for DEFAULT_BUFFER_COUNT and a single one for snapshots */
+	Buffers uint32
+	/* deliver corrupt frames flagged by Snapshot.Err instead of dropping them */
+	KeepCorrupt bool
+	/* deliver pooled copies, the driver buffers are queued again right after copying */
+	CopyFrames bool
+	/* maximum wait for a frame, zero stands for DEFAULT_FRAME_TIMEOUT, negative waits forever */
+	FrameTimeout time.Duration
+	/* zero stands for the I/O method of the device, USERPTR and DMABUF need streaming support */
+	IOMethod IOMethod
+	/*
+	* memory captured into by USERPTR streams instead of Buffers, each block has to hold
+	* a whole image. Empty means page aligned blocks allocated for the stream.
+	 */
+	UserBuffers [][]byte
+	/* DMABUF descriptors DMABUF streams capture into, they stay owned by the caller */
+	DmabufFds []int
+	/* MMAP streams export their buffers as DMABUF, see Snapshot.DmabufFd */
+	ExportDmabuf bool
+	/*
+	* sets up the DV timings of the incoming signal before streaming and restarts the
+	* stream when the source changes, frames then have the frame size of the timings
+	 */
+	DetectTimings bool
+	/* capture rectangle set up after the format, the frame size shrinks to it on devices without scaler */
+	Crop *Rectangle
+	/* JPEG compression quality from 1 to 100, zero keeps the setting of the device, see Snapshot.JPEGQuality */
+	JPEGQuality int
+}
+
+type OutputConfig struct {
+	FrameSize DiscreteFrameSize
+	/* pixel format of the frames, zero stands for V4L2_PIX_FMT_YUYV */
+	PixelFormat uint32
+	/* frames per second announced to the consumers, zero keeps the rate the driver is set to */
+	FrameRate uint32
+	/* number of buffers, zero stands for DEFAULT_BUFFER_COUNT */
+	Buffers uint32
+	/* maximum wait for a free buffer, zero stands for DEFAULT_FRAME_TIMEOUT, negative waits forever */
+	FrameTimeout time.Duration
+}
+
+type EventConfig struct {
+	/*
+	* V4L2_EVENT_* types to subscribe. Empty subscribes the control, source change, frame
+	* sync and end of stream events the device supports.
+	 */
+	Types []uint32
+	/* controls whose changes are reported, empty means all controls of the device */
+	Controls []uint32
+	/* deliver a control event with the current state of every control right after subscribing */
+	SendInitial bool
+}
+
 type Snapshot interface {
 	FrameSize() *DiscreteFrameSize
+	Format() Format
+	FrameInterval() Fraction
+	/* capture time of the frame */
+	Timestamp() time.Time
+	/* raw CLOCK_MONOTONIC capture time, zero if the driver uses another clock */
+	MonotonicTimestamp() time.Duration
+	Sequence() uint32
+	Flags() uint32
+	HasFlag(flag uint32) bool
+	Field() uint32
+	/* number of frames lost since the previous snapshot of the stream */
+	Dropped() uint32
+	/* ErrCorruptFrame based error if the frame failed validation, nil otherwise */
+	Err() error
+	/* size of the payload, bytesused reported by the driver, of the first plane of multi-planar frames */
 	Length() uint32
+	/*
+	* payload, valid only until Release is called. Multi-planar frames return the first plane,
+	* empty if an imported DMABUF cannot be mapped.
+	 */
 	Data() []byte
+	/* DMABUF holding the frame, valid only until Release is called, -1 if there is none */
+	DmabufFd() int
+	/* memory planes of the frame, single-planar frames have one holding Data */
+	Planes() []Plane
+	/*
+	* compression quality the device set up for the quality the stream requested, zero if
+	* none was requested or the device ignores it
+	 */
+	JPEGQuality() int
+	/*
+	* Gives the memory back, a streamed snapshot keeps its driver buffer dequeued
+	* until then. Calling it more than once is a no-op.
+	 */
+	Release()
+	/* independent copy of the snapshot which survives Release, it has to be released as well */
+	Copy() Snapshot
+}
+
+/*
+* Memory plane of a frame
+ */
+type Plane struct {
+	/* payload of the plane, valid only until Release is called */
+	Data []byte
+	/* distance between two lines in bytes, zero for compressed formats */
+	BytesPerLine uint32
+	/* bytes filled by the driver, including a header in front of Data */
+	BytesUsed uint32
+	/* DMABUF holding the plane, -1 if there is none */
+	DmabufFd int
 }
 
 type SnapshotHandler func(snapshot Snapshot)
+
+/*
+* Event of a device. It is one of ControlEvent, SourceChangeEvent, FrameSyncEvent,
+* EndOfStreamEvent and RawEvent for events of all other types.
+ */
+type Event interface {
+	Header() EventHeader
+}
+
+type EventHeader struct {
+	/* V4L2_EVENT_* */
+	Type uint32
+	/* control of control events, input or pad of source change events */
+	ID       uint32
+	Sequence uint32
+	/* events still queued in the driver */
+	Pending   uint32
+	Timestamp time.Time
+}
+
+func (h EventHeader) Header() EventHeader {
+	return h
+}
+
+/*
+* Value, flags or range of a control changed, V4L2_EVENT_CTRL
+ */
+type ControlEvent struct {
+	EventHeader
+	/* V4L2_EVENT_CTRL_CH_* */
+	Changes uint32
+	/* V4L2_CTRL_TYPE_* */
+	ControlType uint32
+	Value       int64
+	Flags       uint32
+	Minimum     int32
+	Maximum     int32
+	Step        int32
+	Default     int32
+}
+
+func (e ControlEvent) HasChange(change uint32) bool {
+	return e.Changes&change > 0
+}
+
+/*
+* Signal of the input changed, capture cards report a new resolution by V4L2_EVENT_SRC_CH_RESOLUTION.
+* The format has to be queried and streaming restarted.
+ */
+type SourceChangeEvent struct {
+	EventHeader
+	/* V4L2_EVENT_SRC_CH_* */
+	Changes uint32
+}
+
+/*
+* The device started to receive a frame, V4L2_EVENT_FRAME_SYNC
+ */
+type FrameSyncEvent struct {
+	EventHeader
+	FrameSequence uint32
+}
+
+/*
+* The last frame of the stream has been delivered, V4L2_EVENT_EOS
+ */
+type EndOfStreamEvent struct {
+	EventHeader
+}
+
+/*
+* Event of another type, private events of drivers included
+ */
+type RawEvent struct {
+	EventHeader
+	Data [64]byte
+}
@@ -0,0 +1,525 @@
+package webcam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+const DEFAULT_BUFFER_COUNT uint32 = 4
+
+/*
+* Buffer of a frame, single-planar buffers have one plane
+ */
+type frameBuffer struct {
+	index  uint32
+	planes []bufferPlane
+}
+
+type bufferPlane struct {
+	/* memory of the plane, nil if an imported DMABUF cannot be mapped */
+	data []byte
+	/* DMABUF descriptor of the plane, exported or imported, -1 if there is none */
+	fd int
+	/* filled by the driver when the buffer is dequeued, bytesused includes dataOffset */
+	bytesused  uint32
+	dataOffset uint32
+}
+
+/*
+* Source of the frames of a stream, implemented for every I/O method. A dequeued buffer
+* is leased to the caller until it is given back.
+ */
+type frameSource interface {
+	start() error
+	dequeue(ctx context.Context) (*frameBuffer, v4l2.V4l2Buffer, error)
+	giveBack(index uint32) error
+	/* ends capturing, memory of the buffers still leased is freed once they are given back */
+	stop() error
+}
+
+/*
+* Set of streaming buffers of the driver, in memory mapped from the driver, memory of
+* the application (USERPTR) or imported DMABUFs. All buffers but the ones leased to
+* a consumer are kept queued in the driver. The buffers are freed only after the ring
+* is stopped and every lease has been given back. Multi-planar buffers are supported
+* with MMAP memory only.
+*
+* Output rings work the other way round, their buffers start leased to the writer
+* which fills them and gives them back to queue them. Dequeued buffers are the ones
+* the driver is done with.
+ */
+type bufferRing struct {
+	file    *ioctl.File
+	bufType uint32
+	memory  uint32
+	buffers []frameBuffer
+
+	mutex       sync.Mutex
+	stopped     bool
+	outstanding int
+}
+
+/*
+* Maps count driver buffers into the process, with export each of their planes is
+* exported as DMABUF as well
+ */
+func newMmapRing(file *ioctl.File, bufType uint32, count uint32, export bool) (*bufferRing, error) {
+
+	ring, err := requestRing(file, bufType, v4l2.V4L2_MEMORY_MMAP, count)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for index := uint32(0); index < uint32(cap(ring.buffers)); index++ {
+
+		planes, err := queryMmapBuffer(file.Fd(), bufType, index)
+
+		if err != nil {
+			ring.free()
+			return nil, err
+		}
+
+		ring.buffers = append(ring.buffers, frameBuffer{index, make([]bufferPlane, 0, len(planes))})
+		buf := &ring.buffers[index]
+
+		for plane, p := range planes {
+			log.Printf("Retrieving mapped memory block %d/%d, offset=%d, length=%d", index, plane, p.MemOffset(), p.Length)
+			data, err := mapBuffer(file.Fd(), p.MemOffset(), p.Length)
+
+			if err != nil {
+				ring.free()
+				return nil, err
+			}
+
+			buf.planes = append(buf.planes, bufferPlane{data: data, fd: -1})
+
+			if export {
+				fd, err := exportBuffer(file.Fd(), bufType, index, uint32(plane))
+
+				if err != nil {
+					ring.free()
+					return nil, err
+				}
+
+				log.Printf("Buffer %d/%d exported as DMABUF %d", index, plane, fd)
+				buf.planes[plane].fd = fd
+			}
+		}
+	}
+
+	return ring, nil
+}
+
+/*
+* Captures into memory of the application, every block has to hold size bytes.
+* The memory stays referenced by the ring, the driver writes to it while it is queued.
+ */
+func newUserptrRing(file *ioctl.File, bufType uint32, memory [][]byte, size uint32) (*bufferRing, error) {
+
+	if size == 0 {
+		return nil, errors.New(fmt.Sprintf("Device %s reports no image size", file.Name()))
+	}
+
+	for i, data := range memory {
+		if uint32(len(data)) < size {
+			return nil, errors.New(fmt.Sprintf("User buffer %d of %d bytes cannot hold image of %d bytes", i, len(data), size))
+		}
+	}
+
+	ring, err := requestRing(file, bufType, v4l2.V4L2_MEMORY_USERPTR, uint32(len(memory)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(ring.buffers) > len(memory) {
+		ring.free()
+		return nil, errors.New(fmt.Sprintf("Device %s needs %d buffers, %d user buffers given", file.Name(), cap(ring.buffers), len(memory)))
+	}
+
+	for index := uint32(0); index < uint32(cap(ring.buffers)); index++ {
+		ring.buffers = append(ring.buffers, frameBuffer{index, []bufferPlane{{data: memory[index], fd: -1}}})
+	}
+
+	return ring, nil
+}
+
+/*
+* Captures into DMABUFs allocated elsewhere, each of them has to hold size bytes. The
+* buffers are mapped for reading the frames, a DMABUF which cannot be mapped delivers
+* frames without data. The descriptors stay owned by the caller.
+ */
+func newDmabufRing(file *ioctl.File, bufType uint32, fds []int, size uint32) (*bufferRing, error) {
+
+	ring, err := requestRing(file, bufType, v4l2.V4L2_MEMORY_DMABUF, uint32(len(fds)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(ring.buffers) > len(fds) {
+		ring.free()
+		return nil, errors.New(fmt.Sprintf("Device %s needs %d buffers, %d DMABUFs given", file.Name(), cap(ring.buffers), len(fds)))
+	}
+
+	for index := uint32(0); index < uint32(cap(ring.buffers)); index++ {
+		fd := fds[index]
+		data, err := mapBuffer(uintptr(fd), 0, size)
+
+		if err != nil {
+			log.Printf("Cannot map DMABUF %d, its frames carry no data: %v", fd, err)
+			data = nil
+		}
+
+		ring.buffers = append(ring.buffers, frameBuffer{index, []bufferPlane{{data: data, fd: fd}}})
+	}
+
+	return ring, nil
+}
+
+/*
+* Requests the buffers from the driver, the ring has room for as many as granted
+ */
+func requestRing(file *ioctl.File, bufType uint32, memory uint32, count uint32) (*bufferRing, error) {
+
+	if multiPlanar(bufType) && memory != v4l2.V4L2_MEMORY_MMAP {
+		return nil, fmt.Errorf("Multi-planar device %s supports only MMAP buffers: %w", file.Name(), ErrUnsupported)
+	}
+
+	log.Printf("Requesting %d buffers", count)
+	granted, err := requestBuffers(file.Fd(), bufType, memory, count)
+
+	if memory != v4l2.V4L2_MEMORY_MMAP && errors.Is(err, syscall.EINVAL) {
+		return nil, fmt.Errorf("Device %s does not support %s buffers: %w", file.Name(), memoryName(memory), ErrUnsupported)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if granted == 0 {
+		return nil, errors.New(fmt.Sprintf("Device %s granted no buffers", file.Name()))
+	}
+
+	log.Printf("%d buffers granted", granted)
+
+	return &bufferRing{file: file, bufType: bufType, memory: memory, buffers: make([]frameBuffer, 0, granted)}, nil
+}
+
+func memoryName(memory uint32) string {
+	switch memory {
+	case v4l2.V4L2_MEMORY_MMAP:
+		return "MMAP"
+	case v4l2.V4L2_MEMORY_USERPTR:
+		return "USERPTR"
+	case v4l2.V4L2_MEMORY_DMABUF:
+		return "DMABUF"
+	}
+	return fmt.Sprintf("memory %d", memory)
+}
+
+/*
+* Queues all buffers and activates streaming, the buffers are freed on failure. The
+* buffers of output rings stay with the writer.
+ */
+func (r *bufferRing) start() error {
+
+	if outputType(r.bufType) {
+		r.outstanding = len(r.buffers)
+		log.Println("Activating streaming")
+
+		if err := activateStreaming(r.file.Fd(), r.bufType); err != nil {
+			r.free()
+			return err
+		}
+
+		return nil
+	}
+
+	log.Println("Queueing buffers")
+	for _, b := range r.buffers {
+		if err := r.requeue(b.index); err != nil {
+			r.free()
+			return err
+		}
+	}
+
+	log.Println("Activating streaming")
+	if err := activateStreaming(r.file.Fd(), r.bufType); err != nil {
+		r.free()
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Waits until the driver fills a buffer, or is done with an output buffer, or the
+* context ends. The buffer stays owned by the caller until it is given back by giveBack,
+* the payload of its planes is updated from the dequeued buffer.
+ */
+func (r *bufferRing) dequeue(ctx context.Context) (*frameBuffer, v4l2.V4l2Buffer, error) {
+
+	var buffer v4l2.V4l2Buffer
+	var planes []v4l2.V4l2Plane
+
+	wait, ready := waitForFrame, int16(ioctl.POLLIN)
+
+	if outputType(r.bufType) {
+		wait, ready = waitForOutput, ioctl.POLLOUT
+	}
+
+	if multiPlanar(r.bufType) {
+		planes = make([]v4l2.V4l2Plane, v4l2.VIDEO_MAX_PLANES)
+	}
+
+	dequeue := func() (bool, error) {
+		buffer = v4l2.V4l2Buffer{}
+		buffer.Type = r.bufType
+		buffer.Memory = r.memory
+
+		if planes != nil {
+			buffer.SetPlanes(planes)
+		}
+
+		ok, err := dequeueBuffer(r.file.Fd(), &buffer)
+		runtime.KeepAlive(planes)
+		return ok, err
+	}
+
+	for {
+		ok, err := dequeue()
+
+		if err != nil {
+			return nil, buffer, err
+		}
+
+		if ok {
+			break
+		}
+
+		revents, err := wait(ctx, r.file.Fd())
+
+		if err != nil {
+			return nil, buffer, err
+		}
+
+		if revents&ready > 0 {
+			continue
+		}
+
+		/* poll signalled an error, the dequeue reports it precisely unless a buffer arrived meanwhile */
+		if ok, err = dequeue(); err != nil {
+			return nil, buffer, err
+		}
+
+		if !ok {
+			return nil, buffer, fmt.Errorf("Device %s signalled an error while waiting for a buffer: %w", r.file.Name(), ErrDisconnected)
+		}
+
+		break
+	}
+
+	if int(buffer.Index) >= len(r.buffers) {
+		return nil, buffer, errors.New(fmt.Sprintf("Driver returned unknown buffer %d", buffer.Index))
+	}
+
+	buf := &r.buffers[buffer.Index]
+
+	if multiPlanar(r.bufType) {
+		for i := range buf.planes {
+			if i < int(buffer.Length) {
+				buf.planes[i].bytesused = planes[i].Bytesused
+				buf.planes[i].dataOffset = planes[i].DataOffset
+			}
+		}
+	} else {
+		buf.planes[0].bytesused = buffer.Bytesused
+	}
+
+	r.mutex.Lock()
+	r.outstanding++
+	r.mutex.Unlock()
+
+	return buf, buffer, nil
+}
+
+/*
+* Queues a buffer, output buffers carry the payload of their planes and the time they are written
+ */
+func (r *bufferRing) requeue(index uint32) error {
+
+	var buffer v4l2.V4l2Buffer
+	buffer.Index = index
+	buffer.Type = r.bufType
+	buffer.Memory = r.memory
+
+	plane := r.buffers[index].planes[0]
+
+	if outputType(r.bufType) {
+		buffer.Bytesused = plane.bytesused
+		buffer.Field = v4l2.V4L2_FIELD_NONE
+
+		if stamp, err := monotonicClock(); err == nil {
+			buffer.SetTimestamp(int64(stamp/time.Second), int64(stamp%time.Second/time.Microsecond))
+		}
+	}
+
+	switch r.memory {
+	case v4l2.V4L2_MEMORY_USERPTR:
+		buffer.SetUserptr(uintptr(unsafe.Pointer(&plane.data[0])))
+		buffer.Length = uint32(len(plane.data))
+	case v4l2.V4L2_MEMORY_DMABUF:
+		buffer.SetFd(int32(plane.fd))
+	}
+
+	if !multiPlanar(r.bufType) {
+		return queueBuffer(r.file.Fd(), &buffer)
+	}
+
+	planes := make([]v4l2.V4l2Plane, len(r.buffers[index].planes))
+
+	if outputType(r.bufType) {
+		for i, p := range r.buffers[index].planes {
+			planes[i].Bytesused = p.bytesused
+		}
+	}
+
+	buffer.SetPlanes(planes)
+
+	err := queueBuffer(r.file.Fd(), &buffer)
+	runtime.KeepAlive(planes)
+	return err
+}
+
+/*
+* Returns a dequeued buffer. It is queued again while the ring runs, once the ring
+* is stopped the last returned buffer frees the memory.
+ */
+func (r *bufferRing) giveBack(index uint32) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.outstanding--
+
+	if !r.stopped {
+		return r.requeue(index)
+	}
+
+	if r.outstanding == 0 {
+		return r.free()
+	}
+
+	return nil
+}
+
+/*
+* Deactivates streaming and marks the ring as stopped. Memory is released now or as
+* soon as the last leased buffer is given back, also when unplugged devices fail to
+* deactivate streaming. That error is returned then.
+ */
+func (r *bufferRing) stop() error {
+
+	log.Println("Deactivating streaming")
+	streamErr := deactivateStreaming(r.file.Fd(), r.bufType)
+
+	if streamErr != nil {
+		log.Printf("Cannot deactivate streaming: %v", streamErr)
+	}
+
+	log.Printf("Releasing mapped memory blocks")
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopped = true
+
+	if r.outstanding > 0 {
+		log.Printf("Releasing mapped memory postponed, %d buffers are still leased", r.outstanding)
+		return streamErr
+	}
+
+	if err := r.free(); err != nil && streamErr == nil {
+		return err
+	}
+
+	return streamErr
+}
+
+/*
+* Unmaps all buffers, closes the exported DMABUFs and frees the buffers in the driver.
+* Memory of the application and imported DMABUFs are left to the caller.
+ */
+func (r *bufferRing) free() error {
+
+	var result error
+
+	for _, b := range r.buffers {
+		for _, p := range b.planes {
+			if r.memory != v4l2.V4L2_MEMORY_USERPTR && p.data != nil {
+				if err := munmapBuffer(p.data); err != nil && result == nil {
+					result = err
+				}
+			}
+
+			if r.memory == v4l2.V4L2_MEMORY_MMAP && p.fd >= 0 {
+				if err := closeDmabuf(p.fd); err != nil && result == nil {
+					result = err
+				}
+			}
+		}
+	}
+
+	r.buffers = nil
+
+	if _, err := requestBuffers(r.file.Fd(), r.bufType, r.memory, 0); err != nil && result == nil {
+		result = err
+	}
+
+	return result
+}
+
+/*
+* Allocates memory for USERPTR streaming which starts at a page boundary
+ */
+func AllocateUserBuffer(size int) []byte {
+	pageSize := os.Getpagesize()
+	data := make([]byte, size+pageSize)
+	offset := (pageSize - int(uintptr(unsafe.Pointer(&data[0]))%uintptr(pageSize))) % pageSize
+	return data[offset : offset+size : offset+size]
+}
+
+//--------------------------------------------------------------------------------------------------
+//FRAME COPIES
+//--------------------------------------------------------------------------------------------------
+
+/*
+* Allocator of frame copies, buffers are reused once the snapshots holding them are released
+ */
+type framePool struct {
+	pool sync.Pool
+}
+
+var frames = &framePool{}
+
+func (p *framePool) get(size int) []byte {
+	if b, ok := p.pool.Get().(*[]byte); ok && cap(*b) >= size {
+		return (*b)[:size]
+	}
+	return make([]byte, size)
+}
+
+func (p *framePool) put(b []byte) {
+	p.pool.Put(&b)
+}
@@ -0,0 +1,111 @@
+package webcam
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+	"unsafe"
+	"v4l2"
+	"v4l2/fake"
+	"v4l2/ioctl"
+)
+
+/*
+* Fake driver whose device can be unplugged, every request fails with ENODEV then
+ */
+type unpluggable struct {
+	*fake.Driver
+
+	mutex     sync.Mutex
+	unplugged bool
+	/* requests attempted after unplugging, buffers queued after STREAMOFF and buffers unmapped */
+	requests      map[uintptr]int
+	queuedStopped int
+	unmapped      int
+}
+
+func (u *unpluggable) unplug() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.unplugged = true
+	u.requests = make(map[uintptr]int)
+}
+
+func (u *unpluggable) Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	u.mutex.Lock()
+	unplugged := u.unplugged
+
+	if unplugged {
+		if request == ioctl.VIDIOC_QBUF && u.requests[ioctl.VIDIOC_STREAMOFF] > 0 {
+			u.queuedStopped++
+		}
+
+		u.requests[request]++
+	}
+
+	u.mutex.Unlock()
+
+	if unplugged {
+		return syscall.ENODEV
+	}
+
+	return u.Driver.Ioctl(fd, request, arg)
+}
+
+func (u *unpluggable) Munmap(data []byte) error {
+	u.mutex.Lock()
+	u.unmapped++
+	u.mutex.Unlock()
+
+	return u.Driver.Munmap(data)
+}
+
+/*
+* Unplugged devices fail STREAMOFF, the memory is released anyway once the last leased
+* buffer is given back and nothing is queued after stopping
+ */
+func TestStopUnplugged(t *testing.T) {
+	driver, device := openFake(t, fake.DefaultConfig())
+
+	backend := &unpluggable{Driver: driver}
+	ioctl.SetBackend(backend)
+	defer ioctl.SetBackend(driver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots, errs := device.Stream(ctx, StreamConfig{FrameSize: DiscreteFrameSize{640, 480}, PixelFormat: v4l2.V4L2_PIX_FMT_MJPEG, Buffers: 2, FrameTimeout: -1})
+
+	held := receive(t, snapshots, errs)
+
+	backend.unplug()
+	cancel()
+	drain(t, snapshots, errs)
+
+	backend.mutex.Lock()
+	unmapped := backend.unmapped
+	backend.mutex.Unlock()
+
+	if unmapped != 0 {
+		t.Errorf("Memory of a leased buffer released, %d buffers unmapped", unmapped)
+	}
+
+	held.Release()
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if backend.requests[ioctl.VIDIOC_STREAMOFF] != 1 {
+		t.Errorf("Expected one STREAMOFF, got %d", backend.requests[ioctl.VIDIOC_STREAMOFF])
+	}
+
+	if backend.queuedStopped != 0 {
+		t.Errorf("Buffers queued %d times on the stopped device", backend.queuedStopped)
+	}
+
+	if backend.unmapped != 2 {
+		t.Errorf("Expected both buffers unmapped, got %d", backend.unmapped)
+	}
+}
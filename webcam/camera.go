@@ -1,256 +1,669 @@
 package webcam
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"sync"
+	"time"
 	"v4l2"
+	"v4l2/ioctl"
 )
 
 //-----------------------------------------------------
 //SNAPSHOT
 //-----------------------------------------------------
 
+/*
+* Frame captured by the device. Snapshots backed by driver memory lease the driver
+* buffer until Release is called, copies hold memory of the frame pool.
+ */
 type snapshot struct {
 	framesize *DiscreteFrameSize
-	data      []byte
+	format    Format
+	interval  Fraction
+	info      frameInfo
+	err       error
+	planes    []Plane
 	length    uint32
+	quality   int
+	release   func()
+	once      sync.Once
 }
 
 func (s *snapshot) FrameSize() *DiscreteFrameSize {
 	return s.framesize
 }
 
+func (s *snapshot) Format() Format {
+	return s.format
+}
+
+func (s *snapshot) FrameInterval() Fraction {
+	return s.interval
+}
+
+func (s *snapshot) Timestamp() time.Time {
+	return s.info.timestamp
+}
+
+func (s *snapshot) MonotonicTimestamp() time.Duration {
+	return s.info.monotonic
+}
+
+func (s *snapshot) Sequence() uint32 {
+	return s.info.sequence
+}
+
+func (s *snapshot) Flags() uint32 {
+	return s.info.flags
+}
+
+func (s *snapshot) HasFlag(flag uint32) bool {
+	return (s.info.flags & flag) > 0
+}
+
+func (s *snapshot) Field() uint32 {
+	return s.info.field
+}
+
+func (s *snapshot) Dropped() uint32 {
+	return s.info.dropped
+}
+
+func (s *snapshot) Err() error {
+	return s.err
+}
+
 func (s *snapshot) Data() []byte {
-	return s.data
+	if len(s.planes) == 0 {
+		return nil
+	}
+	return s.planes[0].Data
 }
 
 func (s *snapshot) Length() uint32 {
 	return s.length
 }
 
-//-----------------------------------------------------
-//STILL CAMERA
-//-----------------------------------------------------
+func (s *snapshot) DmabufFd() int {
+	if len(s.planes) == 0 {
+		return -1
+	}
+	return s.planes[0].DmabufFd
+}
+
+func (s *snapshot) Planes() []Plane {
+	return s.planes
+}
 
-type camera struct {
-	file *os.File
+func (s *snapshot) JPEGQuality() int {
+	return s.quality
 }
 
-func (s *camera) takeSnapshotChan(frameSize *DiscreteFrameSize, ch chan Snapshot) {
+func (s *snapshot) Release() {
+	s.once.Do(func() {
+		if s.release != nil {
+			s.release()
+		}
+		s.planes = nil
+	})
+}
 
-	stream := &stream{file: s.file, frameSize: frameSize}
+func (s *snapshot) Copy() Snapshot {
+	planes := make([]Plane, len(s.planes))
 
-	if err := stream.open(); err != nil {
-		log.Fatalf("%v\n", err)
+	for i, p := range s.planes {
+		data := frames.get(len(p.Data))
+		copy(data, p.Data)
+		planes[i] = Plane{Data: data, BytesPerLine: p.BytesPerLine, BytesUsed: uint32(len(data)), DmabufFd: -1}
 	}
 
-	defer stream.close()
+	return &snapshot{
+		framesize: s.framesize,
+		format:    s.format,
+		interval:  s.interval,
+		info:      s.info,
+		err:       s.err,
+		planes:    planes,
+		length:    s.length,
+		quality:   s.quality,
+		release: func() {
+			for _, p := range planes {
+				frames.put(p.Data)
+			}
+		},
+	}
+}
 
-	snap, err := stream.snapshot()
+//--------------------------------------------------------------------------------------------------
+//STREAMING
+//--------------------------------------------------------------------------------------------------
 
-	if err != nil {
-		panic(fmt.Sprintf("%v\n", err))
-	}
+/* time to wait for a frame before the device is considered stuck */
+const DEFAULT_FRAME_TIMEOUT = 5 * time.Second
+
+/* time to wait for leased buffers to be released before restarting a stream again */
+const RESTART_INTERVAL = 50 * time.Millisecond
 
-	ch <- snap
-	close(ch)
+type stream struct {
+	file        *ioctl.File
+	bufType     uint32
+	ioMethod    IOMethod
+	frameSize   *DiscreteFrameSize
+	pixelFormat uint32
+	frameRate   uint32
+	bufferCount uint32
+	keepCorrupt bool
+	copyFrames  bool
+	/* memory of USERPTR and DMABUF streams, MMAP buffers exported as DMABUF */
+	userBuffers  [][]byte
+	dmabufFds    []int
+	exportDmabuf bool
+	/* DV timings are detected on open, source changes restart the stream */
+	detectTimings bool
+	/* capture rectangle set up after the format */
+	crop *Rectangle
+	/* JPEG quality requested and the one the device set up */
+	jpegQuality int
+	quality     int
+	/* maximum wait for a single frame, negative waits forever */
+	frameTimeout time.Duration
+	format       Format
+	interval     Fraction
+	source       frameSource
+	sequence     sequenceTracker
+	/* frames lost since the last delivered one, and the corrupt ones among them in a row */
+	discarded uint32
+	corrupt   uint32
 }
 
-func (s *camera) takeSnapshot(frameSize *DiscreteFrameSize) (Snapshot, error) {
+/*
+* Delivers frames until the context is done or an error occurs. Every received snapshot
+* has to be released, its driver buffer is queued again only then. Both channels are closed
+* when streaming ends, ending the context is not reported as an error.
+ */
+func (s *stream) run(ctx context.Context, snapshots chan<- Snapshot, errs chan<- error) {
 
-	var sn *snapshot
+	defer close(errs)
+	defer close(snapshots)
 
-	err := s.takeSnapshotAsync(frameSize, func(snap Snapshot) {
-		var dataCopy []byte = make([]byte, snap.Length())
-		copy(dataCopy, snap.Data())
-		sn = &snapshot{snap.FrameSize(), dataCopy, snap.Length()}
-	})
+	defer func() {
+		if err := s.close(); err != nil {
+			select {
+			case errs <- err:
+			default:
+				log.Printf("Cannot close stream: %v\n", err)
+			}
+		}
+	}()
 
-	if err != nil {
-		return nil, err
+	var changes <-chan Event
+
+	if s.detectTimings {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		changes = s.watchSource(watchCtx)
 	}
 
-	return sn, nil
-}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
 
-func (s *camera) takeSnapshotAsync(frameSize *DiscreteFrameSize, handler SnapshotHandler) error {
+		snap, changed, err := s.nextOrChange(ctx, changes)
 
-	log.Printf("Setting up frame size %dx%d", frameSize.Width, frameSize.Height)
-	if err := setFrameSize(s.file.Fd(), frameSize, v4l2.V4L2_PIX_FMT_MJPEG); err != nil {
-		return err
+		if changed {
+			err = s.restart(ctx, changes)
+
+			if err == nil {
+				continue
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				errs <- err
+			}
+			return
+		}
+
+		select {
+		case snapshots <- snap:
+		case <-ctx.Done():
+			snap.Release()
+			return
+		}
 	}
+}
 
-	log.Printf("Frame size set up")
-	log.Printf("Requesting buffer")
-	if err := requestMmapBuffer(s.file.Fd()); err != nil {
-		return err
+func (s *stream) open() error {
+	if s.detectTimings {
+		timing, err := (&dvTimings{s.file}).AutoDetect()
+
+		if err != nil {
+			return err
+		}
+
+		s.frameSize = &DiscreteFrameSize{timing.Width, timing.Height}
 	}
-	log.Printf("Buffer requested successfully")
-	log.Printf("Querying mmap buffer")
-	offset, length, err := queryMmapBuffer(s.file.Fd())
+
+	log.Printf("Setting up frame size %dx%d, format %s", s.frameSize.Width, s.frameSize.Height, FourCC(s.pixelFormat))
+	format, err := setFrameSize(s.file.Fd(), s.bufType, s.frameSize, s.pixelFormat)
 
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Mmap buffer obtained. Offset=%v, length=%v\n", offset, length)
-	log.Printf("Retrieving mapped memory block, offset=%d, length=%d", offset, length)
+	if format.PixelFormat != s.pixelFormat {
+		return fmt.Errorf("Device %s replaced format %s by %s: %w", s.file.Name(), FourCC(s.pixelFormat), FourCC(format.PixelFormat), ErrInvalidFormat)
+	}
 
-	data, err := mapBuffer(s.file.Fd(), offset, length)
-	if err != nil {
-		return err
+	if s.crop != nil {
+		if format, err = s.setCrop(); err != nil {
+			return err
+		}
 	}
 
-	log.Println("Activating streaming")
-	if err := activateStreaming(s.file.Fd()); err != nil {
-		return err
+	s.format = format
+	log.Printf("Frame size set up: %v", format)
+
+	if s.jpegQuality > 0 {
+		if s.quality, err = s.setQuality(); err != nil {
+			return err
+		}
 	}
 
-	log.Println("Queueing buffer")
-	var buffer v4l2.V4l2Buffer
-	buffer.Index = uint32(0)
-	buffer.Type = v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
-	buffer.Memory = v4l2.V4L2_MEMORY_MMAP
+	if s.frameRate > 0 {
+		log.Printf("Setting up frame rate %d fps", s.frameRate)
+		if err := setFrameRate(s.file.Fd(), s.bufType, s.frameRate); err != nil {
+			return err
+		}
+	}
+
+	interval, err := getFrameInterval(s.file.Fd(), s.bufType)
 
-	if err := queueBuffer(s.file.Fd(), &buffer); err != nil {
+	if err != nil {
 		return err
 	}
-	log.Println(fmt.Sprintf("Buffer filled with %d bytes", buffer.Length))
 
-	log.Println("Dequeuing the buffer")
-	if err := dequeueBuffer(s.file.Fd(), &buffer); err != nil {
-		return err
+	s.interval = interval
+	log.Printf("Frame interval set to %v", interval)
+
+	if s.bufferCount == 0 {
+		s.bufferCount = DEFAULT_BUFFER_COUNT
 	}
 
-	snapshot := &snapshot{frameSize, data, length}
-	handler(snapshot)
+	source, err := s.newSource()
 
-	log.Printf("Releasing mapped memory block")
-	if err := munmapBuffer(data); err != nil {
+	if err != nil {
 		return err
 	}
 
-	log.Println("Deactivating streaming")
-	if err := deactivateStreaming(s.file.Fd()); err != nil {
+	if err := source.start(); err != nil {
 		return err
 	}
 
+	s.source = source
 	return nil
 }
 
-//--------------------------------------------------------------------------------------------------
-//STREAMING
-//--------------------------------------------------------------------------------------------------
+/*
+* Raw formats and devices without a quality setting stream anyway, their frames report no quality
+ */
+func (s *stream) setQuality() (int, error) {
+	if s.pixelFormat != v4l2.V4L2_PIX_FMT_MJPEG && s.pixelFormat != v4l2.V4L2_PIX_FMT_JPEG {
+		log.Printf("JPEG quality %d ignored for format %s", s.jpegQuality, FourCC(s.pixelFormat))
+		return 0, nil
+	}
 
-type stream struct {
-	file      *os.File
-	frameSize *DiscreteFrameSize
-	length    uint32
-	data      []byte
-}
+	quality, err := (&jpegQuality{s.file}).Set(s.jpegQuality)
 
-func (s *stream) stream(ticks chan bool, snapshots chan<- Snapshot) {
+	if errors.Is(err, ErrUnsupported) {
+		log.Printf("Device %s ignores JPEG quality %d: %v", s.file.Name(), s.jpegQuality, err)
+		return 0, nil
+	}
 
-	if err := s.open(); err != nil {
-		log.Fatalf("%v\n", err)
+	if err != nil {
+		return 0, err
 	}
 
-	defer func() {
-		if err := s.close(); err != nil {
-			log.Fatalf("%v\n", err)
-		}
-	}()
+	log.Printf("JPEG quality set up: %d", quality)
+	return quality, nil
+}
 
-	defer close(snapshots)
+/*
+* Drivers without scaler shrink the frame size to the crop rectangle, the format is read again
+ */
+func (s *stream) setCrop() (Format, error) {
+	rect, err := (&crop{s.file, s.bufType}).Set(*s.crop)
 
-	for range ticks {
-		fmt.Printf("snapshot\n")
+	if err != nil {
+		return Format{}, err
+	}
 
-		snap, err := s.snapshot()
+	log.Printf("Crop rectangle set up: %v", rect)
+	return getFormat(s.file.Fd(), s.bufType)
+}
 
-		if err != nil {
-			close(snapshots)
-			ticks <- false
-			log.Fatalf("%v\n", err)
+func (s *stream) newSource() (frameSource, error) {
+	switch s.ioMethod {
+	case IO_METHOD_MMAP:
+		return newMmapRing(s.file, s.bufType, s.bufferCount, s.exportDmabuf)
+	case IO_METHOD_READ:
+		return newFrameReader(s.file, s.format, s.bufferCount)
+	case IO_METHOD_USERPTR:
+		memory := s.userBuffers
+
+		if len(memory) == 0 {
+			for i := uint32(0); i < s.bufferCount; i++ {
+				memory = append(memory, AllocateUserBuffer(int(s.format.SizeImage)))
+			}
+		}
+
+		return newUserptrRing(s.file, s.bufType, memory, s.format.SizeImage)
+	case IO_METHOD_DMABUF:
+		if len(s.dmabufFds) == 0 {
+			return nil, errors.New(fmt.Sprintf("No DMABUFs given to stream of device %s", s.file.Name()))
 		}
 
-		ticks <- true
-		snapshots <- snap
+		return newDmabufRing(s.file, s.bufType, s.dmabufFds, s.format.SizeImage)
 	}
+	return nil, fmt.Errorf("Device %s has no usable I/O method: %w", s.file.Name(), ErrUnsupported)
 }
 
-func (s *stream) open() error {
-	log.Printf("Setting up frame size %dx%d", s.frameSize.Width, s.frameSize.Height)
-	if err := setFrameSize(s.file.Fd(), s.frameSize, v4l2.V4L2_PIX_FMT_MJPEG); err != nil {
-		return err
-	}
+/*
+* Opens the stream for a single frame, the snapshot is valid only within the handler
+ */
+func (s *stream) snapshot(ctx context.Context, handler SnapshotHandler) error {
 
-	log.Printf("Frame size set up")
-	log.Printf("Requesting buffer")
-	if err := requestMmapBuffer(s.file.Fd()); err != nil {
+	if err := s.open(); err != nil {
 		return err
 	}
-	log.Printf("Buffer requested successfully")
-	log.Printf("Querying mmap buffer")
-	offset, length, err := queryMmapBuffer(s.file.Fd())
+
+	snapshot, err := s.next(ctx)
 
 	if err != nil {
+		s.close()
 		return err
 	}
 
-	s.length = length
+	handler(snapshot)
+	snapshot.Release()
 
-	log.Printf("Mmap buffer obtained. Offset=%v, length=%v\n", offset, length)
-	log.Printf("Retrieving mapped memory block, offset=%d, length=%d", offset, length)
+	return s.close()
+}
 
-	data, err := mapBuffer(s.file.Fd(), offset, length)
-	if err != nil {
-		return err
+/*
+* Waits for the next filled buffer and leases it to the returned snapshot, or copies it
+* and gives it back immediately when the stream copies frames. Corrupt frames are given
+* back to the driver unless the stream keeps them.
+ */
+func (s *stream) next(ctx context.Context) (Snapshot, error) {
+
+	for {
+		buf, buffer, err := s.dequeue(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		info := frameInfoOf(&buffer)
+		info.dropped = s.sequence.track(buffer.Sequence)
+
+		if info.dropped > 0 {
+			log.Printf("%d frames dropped before frame %d", info.dropped, buffer.Sequence)
+		}
+
+		planes, length, frameErr := s.planesOf(buf, buffer.Flags)
+
+		if frameErr != nil && !s.keepCorrupt {
+			log.Printf("Discarding frame %d: %v", buffer.Sequence, frameErr)
+
+			if err := s.source.giveBack(buf.index); err != nil {
+				return nil, err
+			}
+
+			s.discarded += info.dropped + 1
+			s.corrupt++
+
+			if s.corrupt > MAX_CORRUPT_FRAMES {
+				return nil, frameErr
+			}
+
+			continue
+		}
+
+		info.dropped += s.discarded
+		s.discarded = 0
+		s.corrupt = 0
+
+		source, index := s.source, buf.index
+
+		snap := &snapshot{
+			framesize: &DiscreteFrameSize{s.format.Width, s.format.Height},
+			format:    s.format,
+			interval:  s.interval,
+			info:      info,
+			err:       frameErr,
+			planes:    planes,
+			length:    length,
+			quality:   s.quality,
+			release: func() {
+				if err := source.giveBack(index); err != nil {
+					log.Printf("Cannot give buffer %d back to the driver: %v\n", index, err)
+				}
+			},
+		}
+
+		if !s.copyFrames {
+			return snap, nil
+		}
+
+		frameCopy := snap.Copy()
+		snap.Release()
+		return frameCopy, nil
 	}
+}
 
-	s.data = data
+/*
+* Payload of every plane of a dequeued buffer and the length of the first one. The frame
+* is validated by its first plane, further planes have to be filled up to their image size.
+* An imported DMABUF which cannot be mapped is not inspected.
+ */
+func (s *stream) planesOf(buf *frameBuffer, flags uint32) ([]Plane, uint32, error) {
 
-	log.Println("Activating streaming")
-	if err := activateStreaming(s.file.Fd()); err != nil {
-		return err
+	planes := make([]Plane, 0, len(buf.planes))
+
+	for i, p := range buf.planes {
+		bytesused := p.bytesused
+
+		if p.data != nil && bytesused > uint32(len(p.data)) {
+			bytesused = uint32(len(p.data))
+		}
+
+		plane := Plane{BytesUsed: bytesused, DmabufFd: p.fd}
+
+		if i < len(s.format.Planes) {
+			plane.BytesPerLine = s.format.Planes[i].BytesPerLine
+		}
+
+		if p.data != nil && p.dataOffset <= bytesused {
+			plane.Data = p.data[p.dataOffset:bytesused]
+		}
+
+		planes = append(planes, plane)
 	}
 
-	return nil
+	if buf.planes[0].data == nil {
+		if flags&v4l2.V4L2_BUF_FLAG_ERROR > 0 {
+			return planes, planes[0].BytesUsed, corrupt("driver marked the buffer as erroneous")
+		}
+		return planes, planes[0].BytesUsed, nil
+	}
+
+	length := uint32(len(planes[0].Data))
+
+	if err := ValidateFrame(s.format, flags, planes[0].Data); err != nil {
+		return planes, length, err
+	}
+
+	for i, p := range planes[1:] {
+		if i+1 < len(s.format.Planes) && p.Data != nil && uint32(len(p.Data)) < s.format.Planes[i+1].SizeImage {
+			return planes, length, corrupt("plane %d of %d bytes is shorter than its image size %d", i+1, len(p.Data), s.format.Planes[i+1].SizeImage)
+		}
+	}
+
+	return planes, length, nil
 }
 
-func (s *stream) snapshot() (Snapshot, error) {
+/*
+* Dequeues the next buffer within the frame timeout of the stream
+ */
+func (s *stream) dequeue(ctx context.Context) (*frameBuffer, v4l2.V4l2Buffer, error) {
+	if s.frameTimeout < 0 {
+		return s.source.dequeue(ctx)
+	}
 
-	log.Println("Queueing buffer")
-	var buffer v4l2.V4l2Buffer
-	buffer.Index = uint32(0)
-	buffer.Type = v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
-	buffer.Memory = v4l2.V4L2_MEMORY_MMAP
+	timeout := s.frameTimeout
 
-	if err := queueBuffer(s.file.Fd(), &buffer); err != nil {
-		return nil, err
+	if timeout == 0 {
+		timeout = DEFAULT_FRAME_TIMEOUT
 	}
-	log.Println(fmt.Sprintf("Buffer filled with %d bytes", buffer.Length))
 
-	log.Println("Dequeuing the buffer")
-	if err := dequeueBuffer(s.file.Fd(), &buffer); err != nil {
-		return nil, err
+	frameCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	buf, buffer, err := s.source.dequeue(frameCtx)
+
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, buffer, fmt.Errorf("Device %s delivered no frame within %v: %w", s.file.Name(), timeout, ErrTimeout)
 	}
 
-	snapshot := &snapshot{s.frameSize, s.data, s.length}
-	return snapshot, nil
+	return buf, buffer, err
 }
 
 func (s *stream) close() error {
-	log.Printf("Releasing mapped memory block")
-	if err := munmapBuffer(s.data); err != nil {
-		return err
+	if s.source == nil {
+		return nil
+	}
+
+	source := s.source
+	s.source = nil
+	return source.stop()
+}
+
+/*
+* Source change events of the device, the stream keeps running without restarts if
+* the device does not report them
+ */
+func (s *stream) watchSource(ctx context.Context) <-chan Event {
+
+	listener, err := newEventListener(s.file, nil, EventConfig{Types: []uint32{v4l2.V4L2_EVENT_SOURCE_CHANGE}})
+
+	if err != nil {
+		log.Printf("Stream of device %s is not restarted on source changes: %v", s.file.Name(), err)
+		return nil
 	}
 
-	log.Println("Deactivating streaming")
-	if err := deactivateStreaming(s.file.Fd()); err != nil {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go listener.run(ctx, events, errs)
+
+	go func() {
+		for err := range errs {
+			log.Printf("Stream of device %s is no longer restarted on source changes: %v", s.file.Name(), err)
+		}
+	}()
+
+	return events
+}
+
+/*
+* Next frame unless the source changes before it arrives. A frame dequeued meanwhile
+* is given back, it belongs to the old source.
+ */
+func (s *stream) nextOrChange(ctx context.Context, changes <-chan Event) (Snapshot, bool, error) {
+
+	if changes == nil {
+		snap, err := s.next(ctx)
+		return snap, false, err
+	}
+
+	frameCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := make(chan struct{})
+	changed := make(chan bool, 1)
+
+	go func() {
+		select {
+		case _, ok := <-changes:
+			if ok {
+				cancel()
+			}
+			changed <- ok
+		case <-stop:
+			changed <- false
+		}
+	}()
+
+	snap, err := s.next(frameCtx)
+	close(stop)
+
+	if <-changed {
+		if snap != nil {
+			snap.Release()
+		}
+		return nil, true, nil
+	}
+
+	return snap, false, err
+}
+
+/*
+* Sets up the timings of the new source and starts streaming again. Drivers keep the
+* buffers until all leased frames are released, a source without signal is waited for.
+ */
+func (s *stream) restart(ctx context.Context, changes <-chan Event) error {
+
+	log.Printf("Source of device %s changed, restarting stream", s.file.Name())
+
+	if err := s.close(); err != nil {
 		return err
 	}
 
-	return nil
+	for {
+		err := s.open()
+
+		if err == nil {
+			return nil
+		}
+
+		switch {
+		case errors.Is(err, ErrBusy):
+			select {
+			case <-time.After(RESTART_INTERVAL):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+		case errors.Is(err, ErrNoSignal):
+			log.Printf("Waiting for a signal on device %s: %v", s.file.Name(), err)
+
+			select {
+			case _, ok := <-changes:
+				if !ok {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+		default:
+			return err
+		}
+	}
 }
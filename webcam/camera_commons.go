@@ -1,13 +1,65 @@
 package webcam
 
 import (
+	"context"
+	"errors"
+	"runtime"
 	"syscall"
 	"v4l2"
 	"v4l2/ioctl"
 )
 
-func setFrameSize(fd uintptr, frameSize *DiscreteFrameSize, pixelFormat uint32) error {
+func setFrameSize(fd uintptr, bufType uint32, frameSize *DiscreteFrameSize, pixelFormat uint32) (Format, error) {
+	format := requestedFormat(bufType, frameSize, pixelFormat)
+
+	if err := ioctl.SetFrameSize(fd, &format); err != nil {
+		return Format{}, err
+	}
+
+	return formatOf(&format), nil
+}
+
+/*
+* Asks the driver which format it would choose for the request without changing the device state
+ */
+func tryFrameSize(fd uintptr, bufType uint32, frameSize *DiscreteFrameSize, pixelFormat uint32) (Format, error) {
+	format := requestedFormat(bufType, frameSize, pixelFormat)
+
+	if err := ioctl.TryFormat(fd, &format); err != nil {
+		return Format{}, err
+	}
+
+	return formatOf(&format), nil
+}
+
+func getFormat(fd uintptr, bufType uint32) (Format, error) {
 	var format v4l2.V4l2Format
+	format.Type = bufType
+
+	if err := ioctl.GetFormat(fd, &format); err != nil {
+		return Format{}, err
+	}
+
+	return formatOf(&format), nil
+}
+
+/*
+* The setters of the format union select capture buffers, the type is replaced for output devices
+ */
+func requestedFormat(bufType uint32, frameSize *DiscreteFrameSize, pixelFormat uint32) v4l2.V4l2Format {
+	var format v4l2.V4l2Format
+
+	if multiPlanar(bufType) {
+		var pixFormat v4l2.V4l2PixFormatMplane
+		pixFormat.Width = frameSize.Width
+		pixFormat.Height = frameSize.Height
+		pixFormat.Pixelformat = pixelFormat
+		pixFormat.Field = v4l2.V4L2_FIELD_NONE
+
+		format.SetPixFormatMplane(&pixFormat)
+		format.Type = bufType
+		return format
+	}
 
 	var pixFormat v4l2.V4l2PixFormat
 	pixFormat.Width = frameSize.Width
@@ -16,46 +68,125 @@ func setFrameSize(fd uintptr, frameSize *DiscreteFrameSize, pixelFormat uint32)
 	pixFormat.Field = v4l2.V4L2_FIELD_NONE
 
 	format.SetPixFormat(&pixFormat)
+	format.Type = bufType
+	return format
+}
 
-	return ioctl.SetFrameSize(fd, &format)
+/*
+* BytesPerLine and SizeImage of multi-planar formats are the ones of the first plane
+ */
+func formatOf(format *v4l2.V4l2Format) Format {
+	if !multiPlanar(format.Type) {
+		f := format.PixFormat()
+		return Format{f.Width, f.Height, f.Pixelformat, f.Field, f.Bytesperline, f.Sizeimage, f.Colorspace, []PlaneFormat{{f.Bytesperline, f.Sizeimage}}}
+	}
+
+	f := format.PixFormatMplane()
+	planes := make([]PlaneFormat, 0, f.NumPlanes)
+
+	for i := 0; i < int(f.NumPlanes) && i < len(f.PlaneFmt); i++ {
+		planes = append(planes, PlaneFormat{f.PlaneFmt[i].Bytesperline, f.PlaneFmt[i].Sizeimage})
+	}
+
+	result := Format{Width: f.Width, Height: f.Height, PixelFormat: f.Pixelformat, Field: f.Field, Colorspace: f.Colorspace, Planes: planes}
+
+	if len(planes) > 0 {
+		result.BytesPerLine = planes[0].BytesPerLine
+		result.SizeImage = planes[0].SizeImage
+	}
+
+	return result
+}
+
+func multiPlanar(bufType uint32) bool {
+	return bufType == v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE || bufType == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE
 }
 
-func requestMmapBuffer(fd uintptr) error {
+func outputType(bufType uint32) bool {
+	return bufType == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT || bufType == v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE
+}
+
+/*
+* Requests count buffers of the memory type, the driver may grant a different number which is returned
+ */
+func requestBuffers(fd uintptr, bufType uint32, memory uint32, count uint32) (uint32, error) {
 
 	var request v4l2.V4l2RequestBuffers
-	request.Count = 1
-	request.Type = v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
-	request.Memory = v4l2.V4L2_MEMORY_MMAP
+	request.Count = count
+	request.Type = bufType
+	request.Memory = memory
 
-	return ioctl.RequestBuffer(fd, &request)
+	if err := ioctl.RequestBuffer(fd, &request); err != nil {
+		return 0, err
+	}
+
+	return request.Count, nil
 }
 
-func queryMmapBuffer(fd uintptr) (uint32, uint32, error) {
+/*
+* Returns offsets and lengths of the memory planes of a buffer, single-planar buffers have one
+ */
+func queryMmapBuffer(fd uintptr, bufType uint32, index uint32) ([]v4l2.V4l2Plane, error) {
+
+	planes := make([]v4l2.V4l2Plane, v4l2.VIDEO_MAX_PLANES)
 
 	buffer := &v4l2.V4l2Buffer{}
-	buffer.Index = uint32(0)
-	buffer.Type = v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
+	buffer.Index = index
+	buffer.Type = bufType
 	buffer.Memory = v4l2.V4L2_MEMORY_MMAP
 
-	ioctl.QueryBuffer(fd, buffer)
+	if multiPlanar(bufType) {
+		buffer.SetPlanes(planes)
+	}
+
+	err := ioctl.QueryBuffer(fd, buffer)
+	runtime.KeepAlive(planes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if multiPlanar(bufType) {
+		return planes[:buffer.Length], nil
+	}
 
-	return buffer.Offset(), buffer.Length, nil
+	planes[0].Length = buffer.Length
+	planes[0].SetMemOffset(buffer.Offset())
+	return planes[:1], nil
 }
 
 func mapBuffer(fd uintptr, offset uint32, length uint32) ([]byte, error) {
-	return syscall.Mmap(int(fd), int64(offset), int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	return ioctl.Mmap(fd, int64(offset), int(length))
 }
 
 func munmapBuffer(data []byte) error {
-	return syscall.Munmap(data)
+	return ioctl.Munmap(data)
+}
+
+func exportBuffer(fd uintptr, bufType uint32, index uint32, plane uint32) (int, error) {
+	var export v4l2.V4l2ExportBuffer
+	export.Type = bufType
+	export.Index = index
+	export.Plane = plane
+	export.Flags = syscall.O_RDWR | syscall.O_CLOEXEC
+
+	if err := ioctl.ExportBuffer(fd, &export); err != nil {
+		return -1, err
+	}
+
+	return int(export.Fd), nil
 }
 
-func activateStreaming(fd uintptr) error {
-	return ioctl.ActivateStreaming(fd, v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE)
+func closeDmabuf(fd int) error {
+	return ioctl.CloseFd(uintptr(fd))
 }
 
-func deactivateStreaming(fd uintptr) error {
-	return ioctl.DeactivateStreaming(fd, v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE)
+func activateStreaming(fd uintptr, bufType uint32) error {
+	return ioctl.ActivateStreaming(fd, bufType)
+}
+
+func deactivateStreaming(fd uintptr, bufType uint32) error {
+	return ioctl.DeactivateStreaming(fd, bufType)
 }
 
 func queueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
@@ -67,13 +198,114 @@ func queueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
 	return nil
 }
 
-func dequeueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) error {
+/*
+* Returns false if no buffer has been filled yet
+ */
+func dequeueBuffer(fd uintptr, buffer *v4l2.V4l2Buffer) (bool, error) {
+	return ioctl.DequeueBuffer(fd, buffer)
+}
+
+/*
+* Reads a frame using read I/O, returns false if no frame has been captured yet
+ */
+func readFrame(fd uintptr, data []byte) (int, bool, error) {
+	n, err := ioctl.Read(fd, data)
 
-	err := ioctl.DequeueBuffer(fd, buffer)
+	if errors.Is(err, syscall.EAGAIN) {
+		return 0, false, nil
+	}
 
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 
-	return nil
+	return n, true, nil
+}
+
+/*
+* Waits until the device can deliver a filled buffer or the context ends
+ */
+func waitForFrame(ctx context.Context, fd uintptr) (int16, error) {
+	return ioctl.Poll(ctx, fd, ioctl.POLLIN)
+}
+
+/*
+* Subscribes an event of the handle, the id selects the control of control events
+ */
+func subscribeEvent(fd uintptr, eventType uint32, id uint32, flags uint32) error {
+	var subscription v4l2.V4l2EventSubscription
+	subscription.Type = eventType
+	subscription.Id = id
+	subscription.Flags = flags
+
+	return ioctl.SubscribeEvent(fd, &subscription)
+}
+
+/*
+* Returns false if no event is pending
+ */
+func dequeueEvent(fd uintptr, event *v4l2.V4l2Event) (bool, error) {
+	return ioctl.DequeueEvent(fd, event)
+}
+
+/*
+* Waits until an event is pending or the context ends
+ */
+func waitForEvent(ctx context.Context, fd uintptr) (int16, error) {
+	return ioctl.Poll(ctx, fd, ioctl.POLLPRI)
+}
+
+/*
+* Waits until the device is done with a written buffer or the context ends
+ */
+func waitForOutput(ctx context.Context, fd uintptr) (int16, error) {
+	return ioctl.Poll(ctx, fd, ioctl.POLLOUT)
+}
+
+func cstring(data []uint8) string {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
+
+func setFrameRate(fd uintptr, bufType uint32, frameRate uint32) error {
+	var param v4l2.V4l2Streamparm
+	param.Type = bufType
+
+	timePerFrame := timePerFrameOf(&param)
+	timePerFrame.Numerator = 1
+	timePerFrame.Denominator = frameRate
+
+	return ioctl.SetStreamParameters(fd, &param)
+}
+
+/*
+* Frame interval in the member of the parameter union which matches the buffer type
+ */
+func timePerFrameOf(param *v4l2.V4l2Streamparm) *v4l2.V4l2Fract {
+	if outputType(param.Type) {
+		return &param.Output().Timeperframe
+	}
+	return &param.Capture().Timeperframe
+}
+
+/*
+* Reads the frame interval the driver actually uses. Drivers that do not implement
+* VIDIOC_G_PARM report an empty fraction.
+ */
+func getFrameInterval(fd uintptr, bufType uint32) (Fraction, error) {
+	var param v4l2.V4l2Streamparm
+	param.Type = bufType
+
+	if err := ioctl.GetStreamParameters(fd, &param); err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			return Fraction{}, nil
+		}
+		return Fraction{}, err
+	}
+
+	return fraction(*timePerFrameOf(&param)), nil
 }
@@ -0,0 +1,314 @@
+package webcam
+
+import (
+	"context"
+	"testing"
+	"time"
+	"unsafe"
+	"v4l2"
+	"v4l2/fake"
+	"v4l2/ioctl"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+
+	snap, err := device.TakeSnapshot(&DiscreteFrameSize{1280, 720}, v4l2.V4L2_PIX_FMT_MJPEG)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer snap.Release()
+
+	data := snap.Data()
+
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 || data[len(data)-2] != 0xff || data[len(data)-1] != 0xd9 {
+		t.Errorf("Expected a JPEG frame, got %d bytes", len(data))
+	}
+
+	if *snap.FrameSize() != (DiscreteFrameSize{1280, 720}) || snap.Format().PixelFormat != v4l2.V4L2_PIX_FMT_MJPEG {
+		t.Errorf("Expected MJPEG 1280x720, got %v", snap.Format())
+	}
+
+	current, err := device.CurrentFormat()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current.Width != 1280 || current.Height != 720 {
+		t.Errorf("Expected the device to keep 1280x720, got %v", current)
+	}
+}
+
+/*
+* Every way of taking a snapshot captures in the default format when none is given
+ */
+func TestTakeSnapshotDefaultFormat(t *testing.T) {
+	config := fake.DefaultConfig()
+	config.Formats = config.Formats[1:]
+	_, device := openFake(t, config)
+
+	size := &DiscreteFrameSize{640, 480}
+
+	takes := map[string]func() (Snapshot, error){
+		"TakeSnapshot": func() (Snapshot, error) {
+			return device.TakeSnapshot(size, 0)
+		},
+		"TakeSnapshotContext": func() (Snapshot, error) {
+			return device.TakeSnapshotContext(context.Background(), size, 0)
+		},
+		"TakeSnapshotConfig": func() (Snapshot, error) {
+			return device.TakeSnapshotConfig(context.Background(), StreamConfig{FrameSize: *size})
+		},
+		"TakeSnapshotAsync": func() (Snapshot, error) {
+			var sn Snapshot
+			err := device.TakeSnapshotAsync(size, 0, func(snap Snapshot) { sn = snap.Copy() })
+			return sn, err
+		},
+		"TakeSnapshotChan": func() (Snapshot, error) {
+			ch := make(chan Snapshot, 1)
+			err := device.TakeSnapshotChan(size, 0, ch)
+			return <-ch, err
+		},
+	}
+
+	for name, take := range takes {
+		t.Run(name, func(t *testing.T) {
+			snap, err := take()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer snap.Release()
+
+			if snap.Format().PixelFormat != v4l2.V4L2_PIX_FMT_YUYV {
+				t.Errorf("Expected YUYV, got %s", FourCC(snap.Format().PixelFormat))
+			}
+		})
+	}
+}
+
+/*
+* Fake driver recording the number of buffers requested
+ */
+type bufferRecorder struct {
+	*fake.Driver
+	requested []uint32
+}
+
+func (r *bufferRecorder) Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if request == ioctl.VIDIOC_REQBUFS {
+		r.requested = append(r.requested, (*v4l2.V4l2RequestBuffers)(arg).Count)
+	}
+
+	return r.Driver.Ioctl(fd, request, arg)
+}
+
+/*
+* Snapshots take a single buffer unless the config asks for more
+ */
+func TestTakeSnapshotBuffers(t *testing.T) {
+	driver, device := openFake(t, fake.DefaultConfig())
+
+	recorder := &bufferRecorder{Driver: driver}
+	ioctl.SetBackend(recorder)
+	defer ioctl.SetBackend(driver)
+
+	for _, test := range []struct {
+		buffers  uint32
+		expected uint32
+	}{
+		{0, 1},
+		{3, 3},
+	} {
+		recorder.requested = nil
+		snap, err := device.TakeSnapshotConfig(context.Background(), StreamConfig{FrameSize: DiscreteFrameSize{640, 480}, Buffers: test.buffers})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snap.Release()
+
+		if len(recorder.requested) != 2 || recorder.requested[0] != test.expected || recorder.requested[1] != 0 {
+			t.Errorf("Buffers %d: expected %d buffers requested and freed, got requests %v", test.buffers, test.expected, recorder.requested)
+		}
+	}
+}
+
+/*
+* Frames arrive in sequence, and the buffers are queued again so that a later stream can
+* request its own
+ */
+func TestStream(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+
+	for round := 0; round < 2; round++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		snapshots, errs := device.Stream(ctx, StreamConfig{FrameSize: DiscreteFrameSize{640, 480}, PixelFormat: v4l2.V4L2_PIX_FMT_YUYV, Buffers: 3})
+
+		for i := uint32(0); i < 10; i++ {
+			snap := receive(t, snapshots, errs)
+
+			if snap.Sequence() != i || snap.Dropped() != 0 || snap.Err() != nil {
+				t.Errorf("Round %d: expected frame %d, got frame %d with %d dropped and error %v", round, i, snap.Sequence(), snap.Dropped(), snap.Err())
+			}
+
+			if snap.Length() != 640*480*2 || len(snap.Data()) != int(snap.Length()) {
+				t.Errorf("Expected %d bytes, got length %d and %d bytes", 640*480*2, snap.Length(), len(snap.Data()))
+			}
+
+			snap.Release()
+		}
+
+		cancel()
+
+		if err := drain(t, snapshots, errs); err != nil {
+			t.Fatalf("Round %d: stream failed: %v", round, err)
+		}
+	}
+}
+
+/*
+* Every buffer leased to a snapshot stays dequeued until it is released, the stream waits
+* for one then
+ */
+func TestStreamLease(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots, errs := device.Stream(ctx, StreamConfig{FrameSize: DiscreteFrameSize{640, 480}, PixelFormat: v4l2.V4L2_PIX_FMT_MJPEG, Buffers: 2, FrameTimeout: -1})
+
+	first := receive(t, snapshots, errs)
+	second := receive(t, snapshots, errs)
+	payload := append([]byte(nil), first.Data()...)
+
+	select {
+	case snap := <-snapshots:
+		t.Fatalf("Frame %d delivered while all buffers are leased", snap.Sequence())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	copied := first.Copy()
+	first.Release()
+	first.Release()
+
+	third := receive(t, snapshots, errs)
+
+	if third.Sequence() != 2 {
+		t.Errorf("Expected frame 2 in the requeued buffer, got %d", third.Sequence())
+	}
+
+	if string(copied.Data()) != string(payload) {
+		t.Error("Copy of the first frame changed after its buffer was requeued")
+	}
+
+	copied.Release()
+	second.Release()
+	third.Release()
+
+	cancel()
+
+	if err := drain(t, snapshots, errs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamMultiPlanar(t *testing.T) {
+	_, device := openFake(t, fake.MultiPlanarConfig())
+
+	if device.BufferType() != v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE {
+		t.Fatalf("Expected a multi-planar device, got buffer type %d", device.BufferType())
+	}
+
+	/* sizes and lines of the planes, the chroma planes of YUV420M are subsampled horizontally as well */
+	for _, test := range []struct {
+		format uint32
+		sizes  []int
+		lines  []uint32
+	}{
+		{v4l2.V4L2_PIX_FMT_NV12M, []int{640 * 480, 640 * 480 / 2}, []uint32{640, 640}},
+		{v4l2.V4L2_PIX_FMT_YUV420M, []int{640 * 480, 640 * 480 / 4, 640 * 480 / 4}, []uint32{640, 320, 320}},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		snapshots, errs := device.Stream(ctx, StreamConfig{FrameSize: DiscreteFrameSize{640, 480}, PixelFormat: test.format})
+
+		snap := receive(t, snapshots, errs)
+		planes := snap.Planes()
+
+		if len(planes) != len(test.sizes) {
+			t.Fatalf("%s: expected %d planes, got %d", FourCC(test.format), len(test.sizes), len(planes))
+		}
+
+		for i, plane := range planes {
+			if len(plane.Data) != test.sizes[i] || plane.BytesPerLine != test.lines[i] {
+				t.Errorf("%s plane %d: expected %d bytes of %d per line, got %d of %d", FourCC(test.format), i, test.sizes[i], test.lines[i], len(plane.Data), plane.BytesPerLine)
+			}
+		}
+
+		if string(snap.Data()) != string(planes[0].Data) {
+			t.Errorf("%s: Data is not the first plane", FourCC(test.format))
+		}
+
+		snap.Release()
+		cancel()
+
+		if err := drain(t, snapshots, errs); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/*
+* A receiver restarts streaming in the size of new timings of its source, also when the
+* source comes back after it was unplugged
+ */
+func TestStreamRestartOnSourceChange(t *testing.T) {
+	driver, device := openFake(t, fake.HDMIConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots, errs := device.Stream(ctx, StreamConfig{FrameSize: DiscreteFrameSize{1920, 1080}, DetectTimings: true})
+
+	snap := receive(t, snapshots, errs)
+
+	if *snap.FrameSize() != (DiscreteFrameSize{1920, 1080}) {
+		t.Fatalf("Expected 1920x1080 frames, got %v", snap.FrameSize())
+	}
+
+	snap.Release()
+
+	for _, timings := range []v4l2.V4l2BtTimings{fake.Timings720p60, {}, fake.Timings640x480p60} {
+		if err := driver.SetSourceTimings(fakePath, 0, timings); err != nil {
+			t.Fatal(err)
+		}
+
+		if timings.Width == 0 {
+			continue
+		}
+
+		expected := DiscreteFrameSize{timings.Width, timings.Height}
+
+		for {
+			snap := receive(t, snapshots, errs)
+			size := *snap.FrameSize()
+			snap.Release()
+
+			if size == expected {
+				break
+			}
+		}
+	}
+
+	cancel()
+
+	if err := drain(t, snapshots, errs); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,316 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type controls struct {
+	file *ioctl.File
+}
+
+func (c *controls) All() ([]Control, error) {
+
+	result := make([]Control, 0, 20)
+
+	err := c.iterateControls(func(query v4l2.V4l2Queryctrl) error {
+
+		if query.Type == v4l2.V4L2_CTRL_TYPE_CTRL_CLASS || query.Flags&v4l2.V4L2_CTRL_FLAG_DISABLED > 0 {
+			return nil
+		}
+
+		control, err := c.control(query)
+
+		if err != nil {
+			return err
+		}
+
+		result = append(result, control)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *controls) ByID(id uint32) (Control, error) {
+
+	query, err := c.query(id)
+
+	if err != nil {
+		return Control{}, err
+	}
+
+	return c.control(query)
+}
+
+func (c *controls) ByName(name string) (Control, error) {
+
+	all, err := c.All()
+
+	if err != nil {
+		return Control{}, err
+	}
+
+	for _, control := range all {
+		if strings.EqualFold(control.Name, name) {
+			return control, nil
+		}
+	}
+
+	return Control{}, errors.New(fmt.Sprintf("Device %s has no control '%s'", c.file.Name(), name))
+}
+
+func (c *controls) Get(id uint32) (int64, error) {
+
+	query, err := c.query(id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !isExtendedControl(query) {
+		ctrl := v4l2.V4l2Control{Id: id}
+
+		if err := ioctl.GetControl(c.file.Fd(), &ctrl); err != nil {
+			return 0, err
+		}
+
+		return int64(ctrl.Value), nil
+	}
+
+	ctrl := v4l2.V4l2ExtControl{Id: id}
+	ctrls := v4l2.V4l2ExtControls{Which: v4l2.V4L2_CTRL_ID2CLASS(id), Count: 1, Controls: &ctrl}
+
+	if err := ioctl.GetExtControls(c.file.Fd(), &ctrls); err != nil {
+		return 0, err
+	}
+
+	if query.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+		return ctrl.Value64(), nil
+	}
+
+	return int64(ctrl.Value()), nil
+}
+
+func (c *controls) Set(id uint32, value int64) error {
+
+	query, err := c.query(id)
+
+	if err != nil {
+		return err
+	}
+
+	if query.Flags&v4l2.V4L2_CTRL_FLAG_READ_ONLY > 0 {
+		return errors.New(fmt.Sprintf("Control %s of device %s is read only", cstring(query.Name[:]), c.file.Name()))
+	}
+
+	/* 32 bit controls would get a wrapped value, buttons ignore it */
+	if query.Type != v4l2.V4L2_CTRL_TYPE_INTEGER64 && query.Type != v4l2.V4L2_CTRL_TYPE_BUTTON {
+		if value < int64(query.Minimum) || value > int64(query.Maximum) {
+			return errors.New(fmt.Sprintf("Value %d of control %s of device %s is out of range %d to %d", value, cstring(query.Name[:]), c.file.Name(), query.Minimum, query.Maximum))
+		}
+	}
+
+	if !isExtendedControl(query) {
+		ctrl := v4l2.V4l2Control{Id: id, Value: int32(value)}
+		return ioctl.SetControl(c.file.Fd(), &ctrl)
+	}
+
+	ctrl := v4l2.V4l2ExtControl{Id: id}
+
+	if query.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+		ctrl.SetValue64(value)
+	} else {
+		ctrl.SetValue(int32(value))
+	}
+
+	ctrls := v4l2.V4l2ExtControls{Which: v4l2.V4L2_CTRL_ID2CLASS(id), Count: 1, Controls: &ctrl}
+
+	return ioctl.SetExtControls(c.file.Fd(), &ctrls)
+}
+
+func (c *controls) GetByName(name string) (int64, error) {
+
+	control, err := c.ByName(name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Get(control.ID)
+}
+
+func (c *controls) SetByName(name string, value int64) error {
+
+	control, err := c.ByName(name)
+
+	if err != nil {
+		return err
+	}
+
+	return c.Set(control.ID, value)
+}
+
+/*
+* Old style user class controls are accessed with VIDIOC_G_CTRL/VIDIOC_S_CTRL,
+* everything else needs the extended variants.
+ */
+func isExtendedControl(query v4l2.V4l2Queryctrl) bool {
+	return query.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 || v4l2.V4L2_CTRL_ID2CLASS(query.Id) != v4l2.V4L2_CTRL_CLASS_USER
+}
+
+func (c *controls) query(id uint32) (v4l2.V4l2Queryctrl, error) {
+
+	query := v4l2.V4l2Queryctrl{Id: id}
+
+	ok, err := ioctl.QueryControl(c.file.Fd(), &query)
+
+	if err != nil {
+		return query, err
+	}
+
+	if !ok {
+		return query, errors.New(fmt.Sprintf("Device %s has no control with id 0x%08x", c.file.Name(), id))
+	}
+
+	return query, nil
+}
+
+func (c *controls) control(query v4l2.V4l2Queryctrl) (Control, error) {
+
+	control := Control{
+		ID:      query.Id,
+		Type:    query.Type,
+		Name:    cstring(query.Name[:]),
+		Minimum: query.Minimum,
+		Maximum: query.Maximum,
+		Step:    query.Step,
+		Default: query.DefaultValue,
+		Flags:   query.Flags,
+	}
+
+	if query.Type != v4l2.V4L2_CTRL_TYPE_MENU && query.Type != v4l2.V4L2_CTRL_TYPE_INTEGER_MENU {
+		return control, nil
+	}
+
+	control.Menu = make([]ControlMenuItem, 0)
+
+	/* menu indices are unsigned like the index of VIDIOC_QUERYMENU, the counter cannot wrap at the maximum */
+	for index := uint64(uint32(query.Minimum)); index <= uint64(uint32(query.Maximum)); index++ {
+
+		menu := v4l2.V4l2Querymenu{Id: query.Id, Index: uint32(index)}
+
+		ok, err := ioctl.QueryMenu(c.file.Fd(), &menu)
+
+		if err != nil {
+			return control, err
+		}
+
+		/* drivers are allowed to skip menu indices */
+		if !ok {
+			continue
+		}
+
+		item := ControlMenuItem{Index: menu.Index}
+
+		if query.Type == v4l2.V4L2_CTRL_TYPE_INTEGER_MENU {
+			item.Value = menu.Value()
+			item.Name = fmt.Sprintf("%d", item.Value)
+		} else {
+			name := menu.Name()
+			item.Name = cstring(name[:])
+			item.Value = int64(index)
+		}
+
+		control.Menu = append(control.Menu, item)
+	}
+
+	return control, nil
+}
+
+/*
+* Callback function that accepts filled structure describing a control
+ */
+type controlCallback func(query v4l2.V4l2Queryctrl) error
+
+/*
+* Walks all controls of the device using V4L2_CTRL_FLAG_NEXT_CTRL. Drivers which
+* do not support the flag are walked through the predefined user class range
+* and the driver private range instead.
+ */
+func (c *controls) iterateControls(callback controlCallback) error {
+
+	var id uint32 = 0
+	var found bool = false
+
+	for {
+		query := v4l2.V4l2Queryctrl{Id: id | v4l2.V4L2_CTRL_FLAG_NEXT_CTRL}
+
+		ok, err := ioctl.QueryControl(c.file.Fd(), &query)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			break
+		}
+
+		found = true
+
+		if err := callback(query); err != nil {
+			return err
+		}
+
+		id = query.Id
+	}
+
+	if found {
+		return nil
+	}
+
+	for id = v4l2.V4L2_CID_BASE; id < v4l2.V4L2_CID_LASTP1; id++ {
+
+		query := v4l2.V4l2Queryctrl{Id: id}
+
+		ok, err := ioctl.QueryControl(c.file.Fd(), &query)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := callback(query); err != nil {
+			return err
+		}
+	}
+
+	for id = v4l2.V4L2_CID_PRIVATE_BASE; ; id++ {
+
+		query := v4l2.V4l2Queryctrl{Id: id}
+
+		ok, err := ioctl.QueryControl(c.file.Fd(), &query)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return nil
+		}
+
+		if err := callback(query); err != nil {
+			return err
+		}
+	}
+}
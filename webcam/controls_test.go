@@ -0,0 +1,112 @@
+package webcam
+
+import (
+	"errors"
+	"testing"
+	"v4l2"
+	"v4l2/fake"
+)
+
+func TestControls(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+	controls := device.Controls()
+
+	all, err := controls.All()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != len(fake.DefaultConfig().Controls) {
+		t.Errorf("Expected %d controls, got %v", len(fake.DefaultConfig().Controls), all)
+	}
+
+	menu, err := controls.ByName("Power Line Frequency")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if menu.ID != v4l2.V4L2_CID_POWER_LINE_FREQUENCY || len(menu.Menu) != 3 {
+		t.Errorf("Expected the power line frequency menu with 3 items, got %v %v", menu, menu.Menu)
+	}
+
+	for _, test := range []struct {
+		name     string
+		id       uint32
+		value    int64
+		expected int64
+		fails    bool
+	}{
+		{"in range", v4l2.V4L2_CID_BRIGHTNESS, 200, 200, false},
+		{"above maximum", v4l2.V4L2_CID_BRIGHTNESS, 256, 200, true},
+		{"beyond int32", v4l2.V4L2_CID_BRIGHTNESS, 1<<32 + 100, 200, true},
+		{"below minimum", v4l2.V4L2_CID_EXPOSURE_ABSOLUTE, 2, 250, true},
+		{"menu item", v4l2.V4L2_CID_POWER_LINE_FREQUENCY, 2, 2, false},
+		{"no menu item", v4l2.V4L2_CID_POWER_LINE_FREQUENCY, 3, 2, true},
+		{"rounded to step", v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY, 42, 40, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := controls.Set(test.id, test.value)
+
+			if (err != nil) != test.fails {
+				t.Errorf("Set(%d): expected failure %t, got %v", test.value, test.fails, err)
+			}
+
+			value, err := controls.Get(test.id)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != test.expected {
+				t.Errorf("Expected value %d, got %d", test.expected, value)
+			}
+		})
+	}
+
+	if _, err := controls.Get(v4l2.V4L2_CID_HUE); err == nil {
+		t.Error("Getting a control the device lacks succeeded")
+	}
+}
+
+func TestJPEGQuality(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+	quality := device.JPEGQuality()
+
+	for _, test := range []struct {
+		requested int
+		expected  int
+	}{
+		{80, 80},
+		{100, 95},
+		{1, 10},
+		{63, 60},
+	} {
+		set, err := quality.Set(test.requested)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		current, err := quality.Get()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if set != test.expected || current != test.expected {
+			t.Errorf("Set(%d): expected %d, got %d and current %d", test.requested, test.expected, set, current)
+		}
+	}
+
+	if _, err := quality.Set(101); err == nil {
+		t.Error("Quality 101 was accepted")
+	}
+
+	_, device = openFake(t, fake.MultiPlanarConfig())
+
+	if _, err := device.JPEGQuality().Get(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from a device without JPEG quality, got %v", err)
+	}
+}
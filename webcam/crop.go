@@ -0,0 +1,159 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type crop struct {
+	file    *ioctl.File
+	bufType uint32
+}
+
+func (c *crop) Bounds() (Rectangle, error) {
+	return c.selection(v4l2.V4L2_SEL_TGT_CROP_BOUNDS)
+}
+
+func (c *crop) Default() (Rectangle, error) {
+	return c.selection(v4l2.V4L2_SEL_TGT_CROP_DEFAULT)
+}
+
+func (c *crop) Current() (Rectangle, error) {
+	return c.selection(v4l2.V4L2_SEL_TGT_CROP)
+}
+
+/*
+* Drivers without the selection API are set up by the legacy VIDIOC_S_CROP, which does
+* not return the adjusted rectangle
+ */
+func (c *crop) Set(rect Rectangle) (Rectangle, error) {
+
+	selection := v4l2.V4l2Selection{Type: c.selectionType(), Target: v4l2.V4L2_SEL_TGT_CROP, R: rect.v4l2Rect()}
+	err := ioctl.SetSelection(c.file.Fd(), &selection)
+
+	if err == nil {
+		return rectangleOf(selection.R), nil
+	}
+
+	if !errors.Is(err, ErrUnsupported) {
+		return Rectangle{}, c.wrap(err)
+	}
+
+	legacy := v4l2.V4l2Crop{Type: c.selectionType(), C: rect.v4l2Rect()}
+
+	if err := ioctl.SetCrop(c.file.Fd(), &legacy); err != nil {
+		return Rectangle{}, c.wrap(err)
+	}
+
+	return c.Current()
+}
+
+func (c *crop) Reset() (Rectangle, error) {
+
+	rect, err := c.Default()
+
+	if err != nil {
+		return Rectangle{}, err
+	}
+
+	return c.Set(rect)
+}
+
+func (c *crop) Zoom(factor float64) (Rectangle, error) {
+
+	if factor < 1 {
+		return Rectangle{}, errors.New(fmt.Sprintf("Zoom factor %v is less than 1", factor))
+	}
+
+	rect, err := c.Default()
+
+	if err != nil {
+		return Rectangle{}, err
+	}
+
+	width := uint32(float64(rect.Width) / factor)
+	height := uint32(float64(rect.Height) / factor)
+
+	zoomed := Rectangle{
+		Left:   rect.Left + int32(rect.Width-width)/2,
+		Top:    rect.Top + int32(rect.Height-height)/2,
+		Width:  width,
+		Height: height,
+	}
+
+	return c.Set(zoomed)
+}
+
+/*
+* Reads a target of the selection API, drivers without it are asked by the legacy
+* VIDIOC_CROPCAP and VIDIOC_G_CROP
+ */
+func (c *crop) selection(target uint32) (Rectangle, error) {
+
+	selection := v4l2.V4l2Selection{Type: c.selectionType(), Target: target}
+	err := ioctl.GetSelection(c.file.Fd(), &selection)
+
+	if err == nil {
+		return rectangleOf(selection.R), nil
+	}
+
+	if !errors.Is(err, ErrUnsupported) {
+		return Rectangle{}, c.wrap(err)
+	}
+
+	if target == v4l2.V4L2_SEL_TGT_CROP {
+		legacy := v4l2.V4l2Crop{Type: c.selectionType()}
+
+		if err := ioctl.GetCrop(c.file.Fd(), &legacy); err != nil {
+			return Rectangle{}, c.wrap(err)
+		}
+
+		return rectangleOf(legacy.C), nil
+	}
+
+	cropcap := v4l2.V4l2Cropcap{Type: c.selectionType()}
+
+	if err := ioctl.QueryCropCapability(c.file.Fd(), &cropcap); err != nil {
+		return Rectangle{}, c.wrap(err)
+	}
+
+	if target == v4l2.V4L2_SEL_TGT_CROP_BOUNDS {
+		return rectangleOf(cropcap.Bounds), nil
+	}
+
+	return rectangleOf(cropcap.Defrect), nil
+}
+
+/*
+* Kernels before 4.13 accept only single-planar buffer types for selections and cropping
+ */
+func (c *crop) selectionType() uint32 {
+	switch c.bufType {
+	case v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE_MPLANE:
+		return v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
+	case v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT_MPLANE:
+		return v4l2.V4L2_BUF_TYPE_VIDEO_OUTPUT
+	}
+	return c.bufType
+}
+
+/*
+* Drivers report EINVAL for targets they do not support and ENODATA if they cannot crop at all
+ */
+func (c *crop) wrap(err error) error {
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENODATA) || errors.Is(err, ErrUnsupported) {
+		return fmt.Errorf("Device %s cannot crop: %w", c.file.Name(), ErrUnsupported)
+	}
+	return err
+}
+
+func rectangleOf(rect v4l2.V4l2Rect) Rectangle {
+	return Rectangle{rect.Left, rect.Top, rect.Width, rect.Height}
+}
+
+func (r Rectangle) v4l2Rect() v4l2.V4l2Rect {
+	return v4l2.V4l2Rect{Left: r.Left, Top: r.Top, Width: r.Width, Height: r.Height}
+}
@@ -1,16 +1,28 @@
 package webcam
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"os"
+	"v4l2"
+	"v4l2/ioctl"
 )
 
 type device struct {
-	file       *os.File
+	file       *ioctl.File
 	capability v4l2Capability
+	bufType    uint32
+	ioMethod   IOMethod
 	formats    supportedFormats
 	framesizes *framesizes
-	camera     *camera
+	intervals  *frameintervals
+	controls   *controls
+	inputs     *inputs
+	standards  *standards
+	dvTimings  *dvTimings
+	crop       *crop
+	quality    *jpegQuality
+	negotiator *negotiator
 }
 
 func (d *device) Name() string {
@@ -21,6 +33,14 @@ func (d *device) Capability() Capability {
 	return d.capability
 }
 
+func (d *device) IOMethod() IOMethod {
+	return d.ioMethod
+}
+
+func (d *device) BufferType() uint32 {
+	return d.bufType
+}
+
 func (d *device) Formats() SupportedFormats {
 	return d.formats
 }
@@ -29,21 +49,192 @@ func (d *device) FrameSizes() FrameSizes {
 	return d.framesizes
 }
 
-func (d *device) TakeSnapshot(frameSize *DiscreteFrameSize) (Snapshot, error) {
-	return d.camera.takeSnapshot(frameSize)
+func (d *device) FrameIntervals() FrameIntervals {
+	return d.intervals
 }
 
-func (d *device) TakeSnapshotAsync(frameSize *DiscreteFrameSize, handler SnapshotHandler) error {
-	return d.camera.takeSnapshotAsync(frameSize, handler)
+func (d *device) Controls() Controls {
+	return d.controls
 }
 
-func (d *device) TakeSnapshotChan(frameSize *DiscreteFrameSize, ch chan Snapshot) {
-	d.camera.takeSnapshotChan(frameSize, ch)
+func (d *device) Inputs() Inputs {
+	return d.inputs
 }
 
-func (d *device) Stream(framesize *DiscreteFrameSize, ticks chan bool, snapshots chan<- Snapshot) {
-	stream := &stream{file: d.file, frameSize: framesize}
-	stream.stream(ticks, snapshots)
+func (d *device) Standards() Standards {
+	return d.standards
+}
+
+func (d *device) DVTimings() DVTimings {
+	return d.dvTimings
+}
+
+func (d *device) Crop() Crop {
+	return d.crop
+}
+
+func (d *device) JPEGQuality() JPEGQuality {
+	return d.quality
+}
+
+func (d *device) CurrentFormat() (Format, error) {
+	return getFormat(d.file.Fd(), d.bufType)
+}
+
+func (d *device) Negotiate(prefs FormatPreferences) (Configuration, error) {
+	return d.negotiator.negotiate(prefs)
+}
+
+func (d *device) TakeSnapshot(frameSize *DiscreteFrameSize, pixelFormat uint32) (Snapshot, error) {
+	return d.TakeSnapshotContext(context.Background(), frameSize, pixelFormat)
+}
+
+func (d *device) TakeSnapshotContext(ctx context.Context, frameSize *DiscreteFrameSize, pixelFormat uint32) (Snapshot, error) {
+	return d.TakeSnapshotConfig(ctx, StreamConfig{FrameSize: *frameSize, PixelFormat: pixelFormat})
+}
+
+func (d *device) TakeSnapshotAsync(frameSize *DiscreteFrameSize, pixelFormat uint32, handler SnapshotHandler) error {
+	/* shares the set up of TakeSnapshotConfig without copying the frame */
+	return d.snapshot(context.Background(), StreamConfig{FrameSize: *frameSize, PixelFormat: pixelFormat}, handler)
+}
+
+func (d *device) TakeSnapshotChan(frameSize *DiscreteFrameSize, pixelFormat uint32, ch chan Snapshot) error {
+
+	defer close(ch)
+
+	sn, err := d.TakeSnapshot(frameSize, pixelFormat)
+
+	if err != nil {
+		return err
+	}
+
+	ch <- sn
+	return nil
+}
+
+func (d *device) TakeSnapshotConfig(ctx context.Context, config StreamConfig) (Snapshot, error) {
+
+	var sn Snapshot
+
+	err := d.snapshot(ctx, config, func(snap Snapshot) {
+		sn = snap.Copy()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sn, nil
+}
+
+/*
+* Captures a single frame, every snapshot is set up like a stream so that they all default
+* to the same pixel format, check the I/O method and set up the JPEG quality
+ */
+func (d *device) snapshot(ctx context.Context, config StreamConfig, handler SnapshotHandler) error {
+	stream, err := d.newStream(config)
+
+	if err != nil {
+		return err
+	}
+
+	if stream.bufferCount == 0 {
+		stream.bufferCount = 1
+	}
+
+	if err := d.checkIOMethod(stream.ioMethod); err != nil {
+		return err
+	}
+
+	return stream.snapshot(ctx, handler)
+}
+
+func (d *device) Stream(ctx context.Context, config StreamConfig) (<-chan Snapshot, <-chan error) {
+	snapshots := make(chan Snapshot)
+	errs := make(chan error, 1)
+
+	stream, err := d.newStream(config)
+
+	if err == nil {
+		/* one buffer is always held by the consumer, another one has to be filled meanwhile */
+		if stream.bufferCount == 1 {
+			stream.bufferCount = 2
+		}
+
+		err = d.checkIOMethod(stream.ioMethod)
+	}
+
+	if err == nil {
+		err = stream.open()
+	}
+
+	if err != nil {
+		errs <- err
+		close(errs)
+		close(snapshots)
+		return snapshots, errs
+	}
+
+	go stream.run(ctx, snapshots, errs)
+
+	return snapshots, errs
+}
+
+func (d *device) Events(ctx context.Context, config EventConfig) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	listener, err := newEventListener(d.file, d.controls, config)
+
+	if err != nil {
+		errs <- err
+		close(errs)
+		close(events)
+		return events, errs
+	}
+
+	go listener.run(ctx, events, errs)
+
+	return events, errs
+}
+
+func (d *device) newStream(config StreamConfig) (*stream, error) {
+	pixelFormat := config.PixelFormat
+
+	if pixelFormat == 0 {
+		var err error
+
+		if pixelFormat, err = DefaultPixelFormat(d.formats, d.bufType); err != nil {
+			return nil, err
+		}
+	}
+
+	ioMethod := config.IOMethod
+
+	if ioMethod == 0 {
+		ioMethod = d.ioMethod
+	}
+
+	return &stream{file: d.file, bufType: d.bufType, ioMethod: ioMethod, frameSize: &config.FrameSize, pixelFormat: pixelFormat, frameRate: config.FrameRate, bufferCount: config.Buffers, keepCorrupt: config.KeepCorrupt, copyFrames: config.CopyFrames, frameTimeout: config.FrameTimeout,
+		userBuffers: config.UserBuffers, dmabufFds: config.DmabufFds, exportDmabuf: config.ExportDmabuf, detectTimings: config.DetectTimings, crop: config.Crop, jpegQuality: config.JPEGQuality}, nil
+}
+
+/*
+* Read I/O needs V4L2_CAP_READWRITE, all other methods are streaming I/O. Multi-planar
+* devices stream into MMAP buffers only.
+ */
+func (d *device) checkIOMethod(ioMethod IOMethod) error {
+	capability := uint32(v4l2.V4L2_CAP_STREAMING)
+
+	if ioMethod == IO_METHOD_READ {
+		capability = v4l2.V4L2_CAP_READWRITE
+	}
+
+	if !d.capability.HasCapability(capability) || (multiPlanar(d.bufType) && ioMethod != IO_METHOD_MMAP) {
+		return fmt.Errorf("Device %s does not support %v I/O: %w", d.file.Name(), ioMethod, ErrUnsupported)
+	}
+
+	return nil
 }
 
 func (d *device) Close() error {
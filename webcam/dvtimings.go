@@ -0,0 +1,154 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type dvTimings struct {
+	file *ioctl.File
+}
+
+func (t *dvTimings) All() ([]DVTiming, error) {
+
+	result := make([]DVTiming, 0, 16)
+
+	for index := uint32(0); ; index++ {
+		var timings v4l2.V4l2EnumDvTimings
+		timings.Index = index
+
+		ok, err := ioctl.QueryDvTimingsEnum(t.file.Fd(), &timings)
+
+		if err != nil {
+			return nil, t.wrap(err)
+		}
+
+		if !ok {
+			return result, nil
+		}
+
+		result = append(result, dvTimingOf(&timings.Timings.Bt))
+	}
+}
+
+func (t *dvTimings) Current() (DVTiming, error) {
+
+	var timings v4l2.V4l2DvTimings
+
+	if err := ioctl.GetDvTimings(t.file.Fd(), &timings); err != nil {
+		return DVTiming{}, t.wrap(err)
+	}
+
+	return dvTimingOf(&timings.Bt), nil
+}
+
+func (t *dvTimings) Set(timing DVTiming) error {
+
+	timings := timing.v4l2Timings()
+	return t.wrap(ioctl.SetDvTimings(t.file.Fd(), &timings))
+}
+
+func (t *dvTimings) Detect() (DVTiming, error) {
+
+	var timings v4l2.V4l2DvTimings
+
+	if err := ioctl.DetectDvTimings(t.file.Fd(), &timings); err != nil {
+		return DVTiming{}, t.wrap(err)
+	}
+
+	return dvTimingOf(&timings.Bt), nil
+}
+
+func (t *dvTimings) AutoDetect() (DVTiming, error) {
+
+	timing, err := t.Detect()
+
+	if err != nil {
+		return DVTiming{}, err
+	}
+
+	log.Printf("Device %s detected timings %v", t.file.Name(), timing)
+
+	if err := t.Set(timing); err != nil {
+		return DVTiming{}, err
+	}
+
+	return t.Current()
+}
+
+/*
+* Drivers report ENODATA if the current input has no DV timings, ENOLINK and ENOLCK if the
+* receiver has no stable signal and ERANGE if the signal is out of its range
+ */
+func (t *dvTimings) wrap(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENODATA):
+		return fmt.Errorf("Current input of device %s has no DV timings: %w", t.file.Name(), ErrUnsupported)
+	case errors.Is(err, syscall.ENOLINK):
+		return fmt.Errorf("Device %s receives no signal: %w", t.file.Name(), ErrNoSignal)
+	case errors.Is(err, syscall.ENOLCK):
+		return fmt.Errorf("Device %s cannot lock to the signal: %w", t.file.Name(), ErrNoSignal)
+	case errors.Is(err, syscall.ERANGE):
+		return fmt.Errorf("Device %s receives timings out of its range: %w", t.file.Name(), ErrUnsupported)
+	}
+	return err
+}
+
+func dvTimingOf(bt *v4l2.V4l2BtTimings) DVTiming {
+	return DVTiming{
+		Width:         bt.Width,
+		Height:        bt.Height,
+		Interlaced:    bt.Interlaced == v4l2.V4L2_DV_INTERLACED,
+		Polarities:    bt.Polarities,
+		PixelClock:    bt.Pixelclock(),
+		HFrontPorch:   bt.Hfrontporch,
+		HSync:         bt.Hsync,
+		HBackPorch:    bt.Hbackporch,
+		VFrontPorch:   bt.Vfrontporch,
+		VSync:         bt.Vsync,
+		VBackPorch:    bt.Vbackporch,
+		ILVFrontPorch: bt.IlVfrontporch,
+		ILVSync:       bt.IlVsync,
+		ILVBackPorch:  bt.IlVbackporch,
+		Standards:     bt.Standards,
+		Flags:         bt.Flags,
+		PictureAspect: Fraction{bt.PictureAspect.Numerator, bt.PictureAspect.Denominator},
+		CEA861VIC:     bt.Cea861Vic,
+		HDMIVIC:       bt.HdmiVic,
+	}
+}
+
+func (t DVTiming) v4l2Timings() v4l2.V4l2DvTimings {
+	timings := v4l2.V4l2DvTimings{Type: v4l2.V4L2_DV_BT_656_1120}
+	bt := &timings.Bt
+
+	bt.Width = t.Width
+	bt.Height = t.Height
+
+	if t.Interlaced {
+		bt.Interlaced = v4l2.V4L2_DV_INTERLACED
+	}
+
+	bt.Polarities = t.Polarities
+	bt.SetPixelclock(t.PixelClock)
+	bt.Hfrontporch = t.HFrontPorch
+	bt.Hsync = t.HSync
+	bt.Hbackporch = t.HBackPorch
+	bt.Vfrontporch = t.VFrontPorch
+	bt.Vsync = t.VSync
+	bt.Vbackporch = t.VBackPorch
+	bt.IlVfrontporch = t.ILVFrontPorch
+	bt.IlVsync = t.ILVSync
+	bt.IlVbackporch = t.ILVBackPorch
+	bt.Standards = t.Standards
+	bt.Flags = t.Flags
+	bt.PictureAspect = v4l2.V4l2Fract{Numerator: t.PictureAspect.Numerator, Denominator: t.PictureAspect.Denominator}
+	bt.Cea861Vic = t.CEA861VIC
+	bt.HdmiVic = t.HDMIVIC
+
+	return timings
+}
@@ -0,0 +1,247 @@
+package webcam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/* event types subscribed when the config names none */
+var defaultEventTypes = []uint32{v4l2.V4L2_EVENT_CTRL, v4l2.V4L2_EVENT_SOURCE_CHANGE, v4l2.V4L2_EVENT_FRAME_SYNC, v4l2.V4L2_EVENT_EOS}
+
+/*
+* Subscriptions of a handle of the device the listener opens itself. Drivers report
+* the events of all other handles to it, control changes made through the VideoDevice
+* included. Closing the handle ends the subscriptions.
+ */
+type eventListener struct {
+	file *ioctl.File
+}
+
+func newEventListener(device *ioctl.File, controls Controls, config EventConfig) (*eventListener, error) {
+
+	log.Printf("Opening device %s for events", device.Name())
+	file, err := ioctl.Open(device.Name(), syscall.O_RDWR|syscall.O_NONBLOCK)
+
+	if err != nil {
+		return nil, err
+	}
+
+	types := config.Types
+
+	if len(types) == 0 {
+		types = defaultEventTypes
+	}
+
+	var flags uint32
+
+	if config.SendInitial {
+		flags = v4l2.V4L2_EVENT_SUB_FL_SEND_INITIAL
+	}
+
+	subscribed := 0
+
+	for _, eventType := range types {
+
+		/* only event types and controls the config names have to be supported */
+		explicit := len(config.Types) > 0 || (eventType == v4l2.V4L2_EVENT_CTRL && len(config.Controls) > 0)
+		ids, err := eventIds(eventType, controls, config.Controls)
+
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		for _, id := range ids {
+			err := subscribeEvent(file.Fd(), eventType, id, flags)
+
+			if err == nil {
+				subscribed++
+				continue
+			}
+
+			unsupported := errors.Is(err, ErrUnsupported) || errors.Is(err, syscall.EINVAL)
+
+			if unsupported && !explicit {
+				log.Printf("Device %s does not report %s events", device.Name(), eventName(eventType))
+				break
+			}
+
+			file.Close()
+
+			if unsupported && eventType == v4l2.V4L2_EVENT_CTRL {
+				return nil, fmt.Errorf("Device %s does not report changes of control 0x%08x: %w", device.Name(), id, ErrUnsupported)
+			}
+
+			if unsupported {
+				return nil, fmt.Errorf("Device %s does not report %s events: %w", device.Name(), eventName(eventType), ErrUnsupported)
+			}
+
+			return nil, err
+		}
+	}
+
+	if subscribed == 0 {
+		file.Close()
+		return nil, fmt.Errorf("Device %s reports none of the events: %w", device.Name(), ErrUnsupported)
+	}
+
+	log.Printf("%d event subscriptions on device %s", subscribed, device.Name())
+	return &eventListener{file}, nil
+}
+
+/*
+* Control events are subscribed per control, all other events once
+ */
+func eventIds(eventType uint32, controls Controls, ids []uint32) ([]uint32, error) {
+
+	if eventType != v4l2.V4L2_EVENT_CTRL {
+		return []uint32{0}, nil
+	}
+
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	all, err := controls.All()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range all {
+		ids = append(ids, c.ID)
+	}
+
+	return ids, nil
+}
+
+func eventName(eventType uint32) string {
+	switch eventType {
+	case v4l2.V4L2_EVENT_VSYNC:
+		return "vsync"
+	case v4l2.V4L2_EVENT_EOS:
+		return "end of stream"
+	case v4l2.V4L2_EVENT_CTRL:
+		return "control"
+	case v4l2.V4L2_EVENT_FRAME_SYNC:
+		return "frame sync"
+	case v4l2.V4L2_EVENT_SOURCE_CHANGE:
+		return "source change"
+	case v4l2.V4L2_EVENT_MOTION_DET:
+		return "motion detection"
+	}
+	return fmt.Sprintf("0x%08x", eventType)
+}
+
+/*
+* Delivers events until the context is done or an error occurs, like stream.run delivers
+* frames. Both channels are closed afterwards, ending the context is not reported.
+ */
+func (l *eventListener) run(ctx context.Context, events chan<- Event, errs chan<- error) {
+
+	defer close(errs)
+	defer close(events)
+
+	defer func() {
+		log.Printf("Closing events of device %s", l.file.Name())
+		if err := l.file.Close(); err != nil {
+			select {
+			case errs <- err:
+			default:
+				log.Printf("Cannot close events: %v\n", err)
+			}
+		}
+	}()
+
+	for {
+		event, err := l.next(ctx)
+
+		if err != nil {
+			if ctx.Err() == nil {
+				errs <- err
+			}
+			return
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *eventListener) next(ctx context.Context) (Event, error) {
+
+	var event v4l2.V4l2Event
+
+	for {
+		ok, err := dequeueEvent(l.file.Fd(), &event)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return eventOf(&event), nil
+		}
+
+		revents, err := waitForEvent(ctx, l.file.Fd())
+
+		if err != nil {
+			return nil, err
+		}
+
+		if revents&ioctl.POLLPRI > 0 {
+			continue
+		}
+
+		/* poll signalled an error, the dequeue reports it precisely unless an event arrived meanwhile */
+		if ok, err = dequeueEvent(l.file.Fd(), &event); err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("Device %s signalled an error while waiting for an event: %w", l.file.Name(), ErrDisconnected)
+		}
+
+		return eventOf(&event), nil
+	}
+}
+
+/*
+* Event timestamps are taken from CLOCK_MONOTONIC
+ */
+func eventOf(event *v4l2.V4l2Event) Event {
+	sec, nsec := event.Timestamp()
+	header := EventHeader{event.Type, event.Id, event.Sequence, event.Pending, monotonicToTime(time.Duration(sec)*time.Second + time.Duration(nsec))}
+
+	switch event.Type {
+	case v4l2.V4L2_EVENT_CTRL:
+		ctrl := event.Ctrl()
+		value := int64(ctrl.Value())
+
+		if ctrl.Type == v4l2.V4L2_CTRL_TYPE_INTEGER64 {
+			value = ctrl.Value64()
+		}
+
+		return ControlEvent{header, ctrl.Changes, ctrl.Type, value, ctrl.Flags, ctrl.Minimum, ctrl.Maximum, ctrl.Step, ctrl.DefaultValue}
+
+	case v4l2.V4L2_EVENT_SOURCE_CHANGE:
+		return SourceChangeEvent{header, event.SrcChange().Changes}
+
+	case v4l2.V4L2_EVENT_FRAME_SYNC:
+		return FrameSyncEvent{header, event.FrameSync().FrameSequence}
+
+	case v4l2.V4L2_EVENT_EOS:
+		return EndOfStreamEvent{header}
+	}
+
+	return RawEvent{header, *event.Data()}
+}
@@ -0,0 +1,85 @@
+package webcam
+
+import (
+	"testing"
+	"time"
+	"v4l2/fake"
+	"v4l2/ioctl"
+)
+
+const fakePath = "/dev/video0"
+
+/*
+* Replaces the kernel by a fake driver emulating the config at fakePath and opens the device,
+* it is closed and the kernel restored when the test ends
+ */
+func openFake(t *testing.T, config fake.Config) (*fake.Driver, VideoDevice) {
+	t.Helper()
+
+	driver := installFake(t, config)
+	device, err := OpenVideoDevice(fakePath)
+
+	if err != nil {
+		t.Fatalf("Cannot open fake device: %v", err)
+	}
+
+	t.Cleanup(func() { device.Close() })
+	return driver, device
+}
+
+func openFakeOutput(t *testing.T, config fake.Config) (*fake.Driver, OutputDevice) {
+	t.Helper()
+
+	driver := installFake(t, config)
+	device, err := OpenOutputDevice(fakePath)
+
+	if err != nil {
+		t.Fatalf("Cannot open fake output device: %v", err)
+	}
+
+	t.Cleanup(func() { device.Close() })
+	return driver, device
+}
+
+func installFake(t *testing.T, config fake.Config) *fake.Driver {
+	driver := fake.NewDriver()
+	driver.AddDevice(fakePath, config)
+
+	previous := ioctl.SetBackend(driver)
+	t.Cleanup(func() { ioctl.SetBackend(previous) })
+
+	return driver
+}
+
+/*
+* Next snapshot of the stream, fails the test if the stream ends or stalls
+ */
+func receive(t *testing.T, snapshots <-chan Snapshot, errs <-chan error) Snapshot {
+	t.Helper()
+
+	select {
+	case snap, ok := <-snapshots:
+		if !ok {
+			t.Fatalf("Stream ended: %v", <-errs)
+		}
+		return snap
+
+	case <-time.After(2 * time.Second):
+		t.Fatal("No frame within 2s")
+	}
+
+	return nil
+}
+
+/*
+* Waits until the stream ends, the snapshots still delivered are released
+ */
+func drain(t *testing.T, snapshots <-chan Snapshot, errs <-chan error) error {
+	t.Helper()
+
+	for snap := range snapshots {
+		snap.Release()
+	}
+
+	return <-errs
+}
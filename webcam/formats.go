@@ -1,39 +1,77 @@
 package webcam
 
 import (
-	"os"
 	"v4l2"
 	"v4l2/ioctl"
 )
 
 type supportedFormats struct {
-	file *os.File
+	file *ioctl.File
+}
+
+func (f supportedFormats) All(bufType uint32) ([]PixelFormat, error) {
+
+	result := make([]PixelFormat, 0, 10)
+
+	err := f.iterateFormats(bufType, func(desc v4l2.V4l2Fmtdesc) bool {
+		result = append(result, PixelFormat{desc.Pixelformat, cstring(desc.Description[:]), desc.Flags})
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (f supportedFormats) Supports(bufType uint32, format uint32) (bool, error) {
 
-	var desc v4l2.V4l2Fmtdesc
-	desc.Index = 0
-	desc.Typ = bufType
+	var result bool = false
+
+	err := f.iterateFormats(bufType, func(desc v4l2.V4l2Fmtdesc) bool {
+		if desc.Pixelformat == format {
+			result = true
+			return false
+		}
+		return true
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
 
-	var index *uint32 = &desc.Index
+/*
+* Callback function that accepts filled format description, returning false stops the iteration
+ */
+type formatCallback func(desc v4l2.V4l2Fmtdesc) bool
 
+func (f supportedFormats) iterateFormats(bufType uint32, callback formatCallback) error {
+
+	var index uint32 = 0
 	for {
 
+		var desc v4l2.V4l2Fmtdesc
+		desc.Index = index
+		desc.Typ = bufType
+
 		ok, err := ioctl.QueryFormat(f.file.Fd(), &desc)
 
 		if err != nil {
-			return false, err
+			return err
 		}
-		if ok {
-			return true, nil
+
+		if !ok {
+			return nil
 		}
 
-		if desc.Pixelformat == format {
-			return true, nil
+		if !callback(desc) {
+			return nil
 		}
 
-		*index++
+		index++
 	}
-	return false, nil
 }
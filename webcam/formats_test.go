@@ -0,0 +1,143 @@
+package webcam
+
+import (
+	"testing"
+	"v4l2"
+	"v4l2/fake"
+)
+
+func TestFormats(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+
+	formats, err := device.Formats().All(device.BufferType())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []uint32{v4l2.V4L2_PIX_FMT_MJPEG, v4l2.V4L2_PIX_FMT_YUYV}
+
+	if len(formats) != len(expected) {
+		t.Fatalf("Expected %d formats, got %v", len(expected), formats)
+	}
+
+	for i, format := range formats {
+		if format.Format != expected[i] {
+			t.Errorf("Format %d: expected %s, got %s", i, FourCC(expected[i]), format.FourCC())
+		}
+	}
+
+	if !formats[0].Compressed() || formats[1].Compressed() {
+		t.Errorf("Only MJPEG is compressed: %v", formats)
+	}
+
+	for _, test := range []struct {
+		format    uint32
+		supported bool
+	}{
+		{v4l2.V4L2_PIX_FMT_MJPEG, true},
+		{v4l2.V4L2_PIX_FMT_YUYV, true},
+		{v4l2.V4L2_PIX_FMT_NV12M, false},
+	} {
+		supported, err := device.Formats().Supports(device.BufferType(), test.format)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if supported != test.supported {
+			t.Errorf("Supports(%s): expected %t, got %t", FourCC(test.format), test.supported, supported)
+		}
+	}
+}
+
+func TestDefaultPixelFormat(t *testing.T) {
+	yuyvOnly := fake.DefaultConfig()
+	yuyvOnly.Formats = yuyvOnly.Formats[1:]
+
+	for _, test := range []struct {
+		name     string
+		config   fake.Config
+		expected uint32
+	}{
+		{"MJPEG preferred", fake.DefaultConfig(), v4l2.V4L2_PIX_FMT_MJPEG},
+		{"first format", yuyvOnly, v4l2.V4L2_PIX_FMT_YUYV},
+		{"multi-planar", fake.MultiPlanarConfig(), v4l2.V4L2_PIX_FMT_NV12M},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, device := openFake(t, test.config)
+
+			format, err := DefaultPixelFormat(device.Formats(), device.BufferType())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if format != test.expected {
+				t.Errorf("Expected %s, got %s", FourCC(test.expected), FourCC(format))
+			}
+		})
+	}
+}
+
+func TestFrameSizes(t *testing.T) {
+	_, device := openFake(t, fake.DefaultConfig())
+
+	sizes, err := device.FrameSizes().All(v4l2.V4L2_PIX_FMT_YUYV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sizes) != 2 || sizes[0].Type != v4l2.V4L2_FRMSIZE_TYPE_DISCRETE || sizes[1].MinWidth != 1280 || sizes[1].MinHeight != 720 {
+		t.Errorf("Expected discrete 640x480 and 1280x720, got %v", sizes)
+	}
+
+	discretes, err := device.FrameSizes().AllDiscrete(v4l2.V4L2_PIX_FMT_MJPEG)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(discretes) != 3 || discretes[2] != (DiscreteFrameSize{1920, 1080}) {
+		t.Errorf("Expected 3 MJPEG sizes up to 1920x1080, got %v", discretes)
+	}
+
+	for _, test := range []struct {
+		format    uint32
+		width     uint32
+		height    uint32
+		supported bool
+	}{
+		{v4l2.V4L2_PIX_FMT_MJPEG, 1920, 1080, true},
+		{v4l2.V4L2_PIX_FMT_YUYV, 1920, 1080, false},
+		{v4l2.V4L2_PIX_FMT_YUYV, 640, 480, true},
+		{v4l2.V4L2_PIX_FMT_YUYV, 641, 480, false},
+	} {
+		supported, err := device.FrameSizes().Supports(test.format, test.width, test.height)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		discrete, err := device.FrameSizes().SupportsDiscrete(test.format, test.width, test.height)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if supported != test.supported || discrete != test.supported {
+			t.Errorf("%s %dx%d: expected %t, got %t and discrete %t", FourCC(test.format), test.width, test.height, test.supported, supported, discrete)
+		}
+	}
+
+	intervals, err := device.FrameIntervals().All(v4l2.V4L2_PIX_FMT_YUYV, 1280, 720)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(intervals) != 2 || intervals[0].Min != (Fraction{1, 10}) {
+		t.Errorf("Expected 10 and 5 fps, got %v", intervals)
+	}
+}
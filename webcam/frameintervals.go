@@ -0,0 +1,53 @@
+package webcam
+
+import (
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type frameintervals struct {
+	file *ioctl.File
+}
+
+func (f *frameintervals) All(format uint32, width uint32, height uint32) ([]FrameInterval, error) {
+
+	result := make([]FrameInterval, 0, 10)
+
+	var index uint32 = 0
+	for {
+
+		var str v4l2.V4l2Frmivalenum
+		str.Index = index
+		str.PixelFormat = format
+		str.Width = width
+		str.Height = height
+
+		ok, err := ioctl.QueryFrameInterval(f.file.Fd(), &str)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return result, nil
+		}
+
+		switch str.Type {
+		case v4l2.V4L2_FRMIVAL_TYPE_DISCRETE:
+			discrete := fraction(str.Discrete())
+			result = append(result, FrameInterval{str.Type, discrete, discrete, Fraction{}})
+
+		case v4l2.V4L2_FRMIVAL_TYPE_CONTINUOUS, v4l2.V4L2_FRMIVAL_TYPE_STEPWISE:
+			stepwise := str.Stepwise()
+			result = append(result, FrameInterval{str.Type, fraction(stepwise.Min), fraction(stepwise.Max), fraction(stepwise.Step)})
+			/* stepwise and continuous intervals are reported only once */
+			return result, nil
+		}
+
+		index++
+	}
+}
+
+func fraction(f v4l2.V4l2Fract) Fraction {
+	return Fraction{f.Numerator, f.Denominator}
+}
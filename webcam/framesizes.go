@@ -1,28 +1,30 @@
 package webcam
 
 import (
-	"os"
 	"v4l2"
 	"v4l2/ioctl"
 )
 
 type framesizes struct {
-	file *os.File
+	file *ioctl.File
 }
 
-func (f *framesizes) SupportsDiscrete(format uint32, width uint32, height uint32) (bool, error) {
+func (f *framesizes) All(format uint32) ([]FrameSizeRange, error) {
 
-	var result bool = false
+	result := make([]FrameSizeRange, 0, 10)
 
 	err := f.iterateFrameSizes(f.file.Fd(), format, func(str v4l2.V4l2Frmsizeenum) bool {
-		if str.Type != v4l2.V4L2_FRMSIZE_TYPE_DISCRETE {
-			return true
-		}
 
-		discrete := str.Discrete()
+		switch str.Type {
+		case v4l2.V4L2_FRMSIZE_TYPE_DISCRETE:
+			discrete := str.Discrete()
+			result = append(result, FrameSizeRange{str.Type, discrete.Width, discrete.Width, 0, discrete.Height, discrete.Height, 0})
+			return true
 
-		if discrete.Width == width && discrete.Height == height {
-			result = true
+		case v4l2.V4L2_FRMSIZE_TYPE_STEPWISE, v4l2.V4L2_FRMSIZE_TYPE_CONTINUOUS:
+			stepwise := str.Stepwise()
+			result = append(result, FrameSizeRange{str.Type, stepwise.Min_width, stepwise.Max_width, stepwise.Step_width, stepwise.Min_height, stepwise.Max_height, stepwise.Step_height})
+			/* stepwise and continuous sizes are reported only once */
 			return false
 		}
 
@@ -30,41 +32,78 @@ func (f *framesizes) SupportsDiscrete(format uint32, width uint32, height uint32
 	})
 
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	return result, nil
 }
-func (f *framesizes) AllDiscreteMJPEG() ([]DiscreteFrameSize, error) {
-	return f.AllDiscrete(v4l2.V4L2_PIX_FMT_MJPEG)
+
+func (f *framesizes) Supports(format uint32, width uint32, height uint32) (bool, error) {
+
+	ranges, err := f.All(format)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range ranges {
+		if r.Contains(width, height) {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
 
-func (f *framesizes) AllDiscrete(format uint32) ([]DiscreteFrameSize, error) {
+/*
+* Only discrete sizes are matched, devices with stepwise or continuous sizes never support
+* any. Supports checks all kinds.
+ */
+func (f *framesizes) SupportsDiscrete(format uint32, width uint32, height uint32) (bool, error) {
 
-	result := make([]DiscreteFrameSize, 0, 10)
+	discretes, err := f.AllDiscrete(format)
 
-	err := f.iterateFrameSizes(f.file.Fd(), format, func(str v4l2.V4l2Frmsizeenum) bool {
+	if err != nil {
+		return false, err
+	}
 
-		if str.Type != v4l2.V4L2_FRMSIZE_TYPE_DISCRETE {
-			return true
+	for _, discrete := range discretes {
+		if discrete.Width == width && discrete.Height == height {
+			return true, nil
 		}
+	}
 
-		discrete := str.Discrete()
+	return false, nil
+}
 
-		result = append(result, DiscreteFrameSize{discrete.Width, discrete.Height})
+func (f *framesizes) AllDiscreteMJPEG() ([]DiscreteFrameSize, error) {
+	return f.AllDiscrete(v4l2.V4L2_PIX_FMT_MJPEG)
+}
 
-		return true
-	})
+/*
+* Discrete sizes of the ranges All returns, stepwise and continuous ones are left out
+ */
+func (f *framesizes) AllDiscrete(format uint32) ([]DiscreteFrameSize, error) {
+
+	ranges, err := f.All(format)
 
 	if err != nil {
 		return nil, err
 	}
 
+	result := make([]DiscreteFrameSize, 0, len(ranges))
+
+	for _, r := range ranges {
+		if r.Type == v4l2.V4L2_FRMSIZE_TYPE_DISCRETE {
+			result = append(result, DiscreteFrameSize{r.MinWidth, r.MinHeight})
+		}
+	}
+
 	return result, nil
 }
 
 /*
-* Callback function that accepts filled structure with frame size
+* Callback function that accepts filled structure with frame size, returning false stops the iteration
  */
 type frameSizeCallback func(str v4l2.V4l2Frmsizeenum) bool
 
@@ -79,7 +118,7 @@ func (f *framesizes) iterateFrameSizes(fd uintptr, format uint32, callback frame
 		var str v4l2.V4l2Frmsizeenum
 		str.Index = index
 		str.PixelFormat = format
-		ok, err := ioctl.QueryFrameSize(f.file.Fd(), &str)
+		ok, err := ioctl.QueryFrameSize(fd, &str)
 
 		if err != nil {
 			return err
@@ -89,7 +128,10 @@ func (f *framesizes) iterateFrameSizes(fd uintptr, format uint32, callback frame
 			return nil
 		}
 
-		callback(str)
+		if !callback(str) {
+			return nil
+		}
+
 		index++
 	}
 }
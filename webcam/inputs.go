@@ -0,0 +1,83 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/* input the frames are captured from reports that there is nothing to capture */
+var ErrNoSignal = errors.New("no signal")
+
+type inputs struct {
+	file *ioctl.File
+}
+
+func (i *inputs) All() ([]Input, error) {
+
+	result := make([]Input, 0, 4)
+
+	for index := uint32(0); ; index++ {
+		input, ok, err := i.query(index)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return result, nil
+		}
+
+		result = append(result, input)
+	}
+}
+
+/*
+* The status is queried again, drivers update it on every enumeration
+ */
+func (i *inputs) Current() (Input, error) {
+
+	index, err := ioctl.GetInput(i.file.Fd())
+
+	if err != nil {
+		return Input{}, err
+	}
+
+	input, ok, err := i.query(index)
+
+	if err != nil {
+		return Input{}, err
+	}
+
+	if !ok {
+		return Input{}, errors.New(fmt.Sprintf("Device %s does not enumerate its current input %d", i.file.Name(), index))
+	}
+
+	return input, nil
+}
+
+func (i *inputs) Select(index uint32) error {
+
+	if _, ok, err := i.query(index); err != nil {
+		return err
+	} else if !ok {
+		return errors.New(fmt.Sprintf("Device %s has no input %d", i.file.Name(), index))
+	}
+
+	return ioctl.SetInput(i.file.Fd(), index)
+}
+
+func (i *inputs) query(index uint32) (Input, bool, error) {
+
+	var input v4l2.V4l2Input
+	input.Index = index
+
+	ok, err := ioctl.QueryInput(i.file.Fd(), &input)
+
+	if err != nil || !ok {
+		return Input{}, ok, err
+	}
+
+	return Input{input.Index, cstring(input.Name[:]), input.Type, input.Status, input.Capabilities, input.Audioset, input.Tuner, input.Std}, true, nil
+}
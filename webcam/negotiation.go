@@ -0,0 +1,109 @@
+package webcam
+
+import (
+	"fmt"
+	"log"
+	"v4l2/ioctl"
+)
+
+type negotiator struct {
+	file      *ioctl.File
+	bufType   uint32
+	formats   supportedFormats
+	intervals *frameintervals
+}
+
+/*
+* Walks the preferred pixel formats in their order and returns the first configuration
+* the driver accepts that satisfies the minimal frame rate. Only VIDIOC_TRY_FMT is used,
+* so the format of a running stream is left untouched.
+ */
+func (n *negotiator) negotiate(prefs FormatPreferences) (Configuration, error) {
+
+	available, err := n.formats.All(n.bufType)
+
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	candidates := prefs.PixelFormats
+
+	if len(candidates) == 0 {
+		for _, f := range available {
+			candidates = append(candidates, f.Format)
+		}
+	}
+
+	for _, pixelFormat := range candidates {
+
+		if !containsFormat(available, pixelFormat) {
+			log.Printf("Format %s is not offered by the device", FourCC(pixelFormat))
+			continue
+		}
+
+		format, err := tryFrameSize(n.file.Fd(), n.bufType, &prefs.FrameSize, pixelFormat)
+
+		if err != nil {
+			return Configuration{}, err
+		}
+
+		if format.PixelFormat != pixelFormat {
+			log.Printf("Driver replaced format %s by %s", FourCC(pixelFormat), FourCC(format.PixelFormat))
+			continue
+		}
+
+		interval, err := n.fastestInterval(format)
+
+		if err != nil {
+			return Configuration{}, err
+		}
+
+		if prefs.MinFrameRate > 0 && !reachesFrameRate(interval, prefs.MinFrameRate) {
+			log.Printf("Format %v cannot reach %d fps", format, prefs.MinFrameRate)
+			continue
+		}
+
+		return Configuration{format, interval}, nil
+	}
+
+	return Configuration{}, fmt.Errorf("Device %s accepts none of the preferred formats: %w", n.file.Name(), ErrInvalidFormat)
+}
+
+/*
+* Returns the shortest frame interval supported for the format or an empty fraction
+* if the driver does not enumerate frame intervals.
+ */
+func (n *negotiator) fastestInterval(format Format) (Fraction, error) {
+
+	intervals, err := n.intervals.All(format.PixelFormat, format.Width, format.Height)
+
+	if err != nil {
+		return Fraction{}, err
+	}
+
+	var result Fraction
+
+	for _, interval := range intervals {
+		if result.Denominator == 0 || interval.Min.Float() < result.Float() {
+			result = interval.Min
+		}
+	}
+
+	return result, nil
+}
+
+func reachesFrameRate(interval Fraction, frameRate uint32) bool {
+	if interval.Numerator == 0 {
+		return false
+	}
+	return uint64(interval.Denominator) >= uint64(frameRate)*uint64(interval.Numerator)
+}
+
+func containsFormat(formats []PixelFormat, pixelFormat uint32) bool {
+	for _, f := range formats {
+		if f.Format == pixelFormat {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,351 @@
+package webcam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type outputDevice struct {
+	file       *ioctl.File
+	capability v4l2Capability
+	bufType    uint32
+	formats    supportedFormats
+	framesizes *framesizes
+	controls   *controls
+}
+
+func (d *outputDevice) Name() string {
+	return d.file.Name()
+}
+
+func (d *outputDevice) Capability() Capability {
+	return d.capability
+}
+
+func (d *outputDevice) BufferType() uint32 {
+	return d.bufType
+}
+
+func (d *outputDevice) Formats() SupportedFormats {
+	return d.formats
+}
+
+func (d *outputDevice) FrameSizes() FrameSizes {
+	return d.framesizes
+}
+
+func (d *outputDevice) Controls() Controls {
+	return d.controls
+}
+
+func (d *outputDevice) CurrentFormat() (Format, error) {
+	return getFormat(d.file.Fd(), d.bufType)
+}
+
+func (d *outputDevice) TryFormat(frameSize *DiscreteFrameSize, pixelFormat uint32) (Format, error) {
+	return tryFrameSize(d.file.Fd(), d.bufType, frameSize, pixelFormat)
+}
+
+func (d *outputDevice) NewWriter(config OutputConfig) (FrameWriter, error) {
+	pixelFormat := config.PixelFormat
+
+	if pixelFormat == 0 {
+		pixelFormat = v4l2.V4L2_PIX_FMT_YUYV
+	}
+
+	log.Printf("Setting up output frame size %dx%d, format %s", config.FrameSize.Width, config.FrameSize.Height, FourCC(pixelFormat))
+	format, err := setFrameSize(d.file.Fd(), d.bufType, &config.FrameSize, pixelFormat)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if format.PixelFormat != pixelFormat {
+		return nil, fmt.Errorf("Device %s replaced format %s by %s: %w", d.file.Name(), FourCC(pixelFormat), FourCC(format.PixelFormat), ErrInvalidFormat)
+	}
+
+	log.Printf("Output frame size set up: %v", format)
+
+	if config.FrameRate > 0 {
+		log.Printf("Setting up output frame rate %d fps", config.FrameRate)
+		if err := setFrameRate(d.file.Fd(), d.bufType, config.FrameRate); err != nil {
+			return nil, err
+		}
+	}
+
+	interval, err := getFrameInterval(d.file.Fd(), d.bufType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bufferCount := config.Buffers
+
+	if bufferCount == 0 {
+		bufferCount = DEFAULT_BUFFER_COUNT
+	}
+
+	ring, err := newMmapRing(d.file, d.bufType, bufferCount, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ring.start(); err != nil {
+		return nil, err
+	}
+
+	writer := &frameWriter{file: d.file, format: format, interval: interval, ring: ring, frameTimeout: config.FrameTimeout, done: make(chan struct{})}
+
+	for _, b := range ring.buffers {
+		writer.free = append(writer.free, b.index)
+	}
+
+	return writer, nil
+}
+
+func (d *outputDevice) Stream(ctx context.Context, config OutputConfig, frames <-chan []byte) <-chan error {
+	errs := make(chan error, 1)
+	writer, err := d.NewWriter(config)
+
+	if err != nil {
+		errs <- err
+		close(errs)
+		return errs
+	}
+
+	go writeFrames(ctx, writer, frames, errs)
+	return errs
+}
+
+/*
+* Writes the frames of the channel like stream.run delivers captured ones, the writer
+* is closed when the channel is closed, the context ends or writing fails
+ */
+func writeFrames(ctx context.Context, writer FrameWriter, frames <-chan []byte, errs chan<- error) {
+
+	defer close(errs)
+
+	defer func() {
+		if err := writer.Close(); err != nil {
+			select {
+			case errs <- err:
+			default:
+				log.Printf("Cannot close output stream: %v\n", err)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+
+			if err := writer.WriteContext(ctx, frame); err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *outputDevice) Close() error {
+	log.Printf("Closing video output device.\n")
+	return d.file.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
+//WRITER
+//--------------------------------------------------------------------------------------------------
+
+type frameWriter struct {
+	file     *ioctl.File
+	format   Format
+	interval Fraction
+	ring     *bufferRing
+	/* maximum wait for a free buffer, negative waits forever */
+	frameTimeout time.Duration
+	/* closed by Close to interrupt a write waiting for a buffer */
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mutex sync.Mutex
+	/* buffers not queued in the driver, they can be filled right away */
+	free []uint32
+	/* a failed queueing leaves the stream unusable, later writes report it */
+	err    error
+	closed bool
+}
+
+func (w *frameWriter) Format() Format {
+	return w.format
+}
+
+func (w *frameWriter) FrameInterval() Fraction {
+	return w.interval
+}
+
+func (w *frameWriter) Write(frame []byte) (int, error) {
+	if err := w.WriteContext(context.Background(), frame); err != nil {
+		return 0, err
+	}
+	return len(frame), nil
+}
+
+func (w *frameWriter) WriteContext(ctx context.Context, frame []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return w.closedError()
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+
+	index, err := w.take(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := fillPlanes(&w.ring.buffers[index], w.format, frame); err != nil {
+		w.free = append(w.free, index)
+		return err
+	}
+
+	if err := w.ring.giveBack(index); err != nil {
+		w.err = err
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Returns a buffer which can be filled, waits for the driver to finish one if all are queued.
+* The wait ends with the frame timeout, the context or Close.
+ */
+func (w *frameWriter) take(ctx context.Context) (uint32, error) {
+	if n := len(w.free); n > 0 {
+		index := w.free[n-1]
+		w.free = w.free[:n-1]
+		return index, nil
+	}
+
+	timeout := w.frameTimeout
+
+	if timeout == 0 {
+		timeout = DEFAULT_FRAME_TIMEOUT
+	}
+
+	var waitCtx context.Context
+	var cancel context.CancelFunc
+
+	if timeout > 0 {
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		waitCtx, cancel = context.WithCancel(ctx)
+	}
+
+	defer cancel()
+
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	buf, _, err := w.ring.dequeue(waitCtx)
+
+	if err == nil {
+		return buf.index, nil
+	}
+
+	select {
+	case <-w.done:
+		return 0, w.closedError()
+	default:
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return 0, fmt.Errorf("Device %s released no output buffer within %v: %w", w.file.Name(), timeout, ErrTimeout)
+	}
+
+	return 0, err
+}
+
+func (w *frameWriter) closedError() error {
+	return errors.New(fmt.Sprintf("Output stream of device %s is closed", w.file.Name()))
+}
+
+/*
+* Copies a frame into the planes of a buffer, every plane but the last one receives the
+* image size of its plane format
+ */
+func fillPlanes(buf *frameBuffer, format Format, frame []byte) error {
+	rest := frame
+
+	for i := range buf.planes {
+		plane := &buf.planes[i]
+		length := len(rest)
+
+		if i < len(buf.planes)-1 && i < len(format.Planes) && length > int(format.Planes[i].SizeImage) {
+			length = int(format.Planes[i].SizeImage)
+		}
+
+		if length > len(plane.data) {
+			return errors.New(fmt.Sprintf("Frame of %d bytes does not fit into buffer %d of format %v", len(frame), buf.index, format))
+		}
+
+		plane.bytesused = uint32(copy(plane.data, rest[:length]))
+		rest = rest[length:]
+	}
+
+	return nil
+}
+
+/*
+* Stops streaming, the buffers are freed once the ones held by the writer are given back.
+* A write waiting for a buffer holds the mutex, it is interrupted first.
+ */
+func (w *frameWriter) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	/* buffers of unplugged devices have to be given back as well to free the memory */
+	result := w.ring.stop()
+
+	for _, index := range w.free {
+		if err := w.ring.giveBack(index); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	w.free = nil
+	return result
+}
@@ -0,0 +1,152 @@
+package webcam
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+	"v4l2"
+	"v4l2/fake"
+	"v4l2/ioctl"
+)
+
+func TestFrameWriter(t *testing.T) {
+	driver, device := openFakeOutput(t, fake.LoopbackConfig())
+
+	writer, err := device.NewWriter(OutputConfig{FrameSize: DiscreteFrameSize{640, 480}, Buffers: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		frame := bytes.Repeat([]byte{byte(i)}, 640*480*2)
+
+		if _, err := writer.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(driver.LastFrame(fakePath), frame) {
+			t.Fatalf("Frame %d was not passed on", i)
+		}
+	}
+
+	if _, err := writer.Write(make([]byte, 640*480*3)); err == nil {
+		t.Error("Frame larger than the buffers was written")
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte{0}); err == nil {
+		t.Error("Closed writer accepted a frame")
+	}
+}
+
+/*
+* Fake driver whose output buffers stay queued, a consumer stopped reading frames
+ */
+type stalledOutput struct {
+	*fake.Driver
+	stalled atomic.Bool
+}
+
+func (s *stalledOutput) Ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if request == ioctl.VIDIOC_DQBUF && s.stalled.Load() {
+		return syscall.EAGAIN
+	}
+	return s.Driver.Ioctl(fd, request, arg)
+}
+
+func (s *stalledOutput) Poll(ctx context.Context, fd uintptr, events int16) (int16, error) {
+	if s.stalled.Load() {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return s.Driver.Poll(ctx, fd, events)
+}
+
+func TestFrameWriterStalled(t *testing.T) {
+	driver, device := openFakeOutput(t, fake.LoopbackConfig())
+
+	backend := &stalledOutput{Driver: driver}
+	ioctl.SetBackend(backend)
+
+	frame := make([]byte, 640*480*2)
+
+	/* writes fill the free buffers first, then wait for one */
+	stall := func(config OutputConfig) FrameWriter {
+		backend.stalled.Store(false)
+		writer, err := device.NewWriter(config)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backend.stalled.Store(true)
+
+		for i := uint32(0); i < config.Buffers; i++ {
+			if _, err := writer.Write(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return writer
+	}
+
+	writer := stall(OutputConfig{FrameSize: DiscreteFrameSize{640, 480}, Buffers: 2, FrameTimeout: 50 * time.Millisecond})
+
+	if _, err := writer.Write(frame); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+
+	writer.Close()
+
+	writer = stall(OutputConfig{FrameSize: DiscreteFrameSize{640, 480}, Buffers: 2, FrameTimeout: -1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := writer.WriteContext(ctx, frame); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline of the context, got %v", err)
+	}
+
+	written := make(chan error)
+
+	go func() {
+		_, err := writer.Write(frame)
+		written <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() { closed <- writer.Close() }()
+
+	for _, ch := range []chan error{written, closed} {
+		select {
+		case err := <-ch:
+			if ch == written && err == nil {
+				t.Error("Write waiting for a buffer succeeded on a stalled device")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close did not interrupt the waiting write")
+		}
+	}
+
+	if !bytes.Equal(driver.LastFrame(fakePath), frame) {
+		t.Error("Frames written before stalling were not passed on")
+	}
+
+	backend.stalled.Store(false)
+	ioctl.SetBackend(driver)
+
+	if _, err := device.TryFormat(&DiscreteFrameSize{640, 480}, v4l2.V4L2_PIX_FMT_YUYV); err != nil {
+		t.Errorf("Device unusable after closing a stalled writer: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/*
+* Quality of V4L2_CID_JPEG_COMPRESSION_QUALITY, drivers without the control are asked by
+* the deprecated VIDIOC_G_JPEGCOMP and VIDIOC_S_JPEGCOMP
+ */
+type jpegQuality struct {
+	file *ioctl.File
+}
+
+func (q *jpegQuality) Get() (int, error) {
+
+	_, ok, err := q.control()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if ok {
+		value, err := (&controls{q.file}).Get(v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return int(value), nil
+	}
+
+	var compression v4l2.V4l2Jpegcompression
+
+	if err := ioctl.GetJpegCompression(q.file.Fd(), &compression); err != nil {
+		return 0, q.wrap(err)
+	}
+
+	return int(compression.Quality), nil
+}
+
+/*
+* Drivers clamp the quality to their range and steps, it is read back to learn the result
+ */
+func (q *jpegQuality) Set(quality int) (int, error) {
+
+	if quality < 1 || quality > 100 {
+		return 0, errors.New(fmt.Sprintf("JPEG quality %d is out of range 1 to 100", quality))
+	}
+
+	query, ok, err := q.control()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if ok {
+		/* Set rejects values out of range, clamp them like drivers do */
+		value := int64(quality)
+
+		if value < int64(query.Minimum) {
+			value = int64(query.Minimum)
+		}
+
+		if value > int64(query.Maximum) {
+			value = int64(query.Maximum)
+		}
+
+		err = (&controls{q.file}).Set(v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY, value)
+	} else {
+		err = q.setCompression(quality)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := q.Get()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if result != quality {
+		log.Printf("Device %s adjusted JPEG quality %d to %d", q.file.Name(), quality, result)
+	}
+
+	return result, nil
+}
+
+/*
+* Only the quality is changed, the markers and segments are kept
+ */
+func (q *jpegQuality) setCompression(quality int) error {
+
+	var compression v4l2.V4l2Jpegcompression
+
+	if err := ioctl.GetJpegCompression(q.file.Fd(), &compression); err != nil {
+		return q.wrap(err)
+	}
+
+	compression.Quality = int32(quality)
+	return q.wrap(ioctl.SetJpegCompression(q.file.Fd(), &compression))
+}
+
+func (q *jpegQuality) control() (v4l2.V4l2Queryctrl, bool, error) {
+
+	query := v4l2.V4l2Queryctrl{Id: v4l2.V4L2_CID_JPEG_COMPRESSION_QUALITY}
+	ok, err := ioctl.QueryControl(q.file.Fd(), &query)
+
+	/* drivers without any controls */
+	if errors.Is(err, ErrUnsupported) {
+		return query, false, nil
+	}
+
+	if err != nil {
+		return query, false, err
+	}
+
+	return query, ok && query.Flags&v4l2.V4L2_CTRL_FLAG_DISABLED == 0, nil
+}
+
+/*
+* Drivers without the JPEGCOMP ioctls report ENOTTY, some EINVAL
+ */
+func (q *jpegQuality) wrap(err error) error {
+	if errors.Is(err, ErrUnsupported) || errors.Is(err, syscall.EINVAL) {
+		return fmt.Errorf("Device %s has no JPEG quality setting: %w", q.file.Name(), ErrUnsupported)
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package webcam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+/*
+* Frames captured by read() into memory of the process, used by devices without
+* streaming I/O. Drivers end read capture only when the file is closed, therefore
+* the reader reads from its own handle of the device and closes it when stopped.
+ */
+type frameReader struct {
+	file    *ioctl.File
+	buffers []frameBuffer
+	/* indexes of the buffers not leased to a consumer */
+	free     chan uint32
+	field    uint32
+	sequence uint32
+}
+
+func newFrameReader(device *ioctl.File, format Format, count uint32) (*frameReader, error) {
+
+	if format.SizeImage == 0 {
+		return nil, errors.New(fmt.Sprintf("Device %s reports no image size for %v", device.Name(), format))
+	}
+
+	log.Printf("Opening device %s for read I/O", device.Name())
+	file, err := ioctl.Open(device.Name(), syscall.O_RDWR|syscall.O_NONBLOCK)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &frameReader{file: file, buffers: make([]frameBuffer, 0, count), free: make(chan uint32, count), field: format.Field}
+
+	for index := uint32(0); index < count; index++ {
+		reader.buffers = append(reader.buffers, frameBuffer{index, []bufferPlane{{data: make([]byte, format.SizeImage), fd: -1}}})
+		reader.free <- index
+	}
+
+	return reader, nil
+}
+
+/*
+* The driver starts capturing on the first read
+ */
+func (r *frameReader) start() error {
+	return nil
+}
+
+/*
+* Waits for a buffer not leased to a consumer and reads the next frame into it. The
+* driver attaches no metadata, frames are numbered and stamped when they arrive.
+ */
+func (r *frameReader) dequeue(ctx context.Context) (*frameBuffer, v4l2.V4l2Buffer, error) {
+
+	var buffer v4l2.V4l2Buffer
+	var index uint32
+
+	select {
+	case index = <-r.free:
+	case <-ctx.Done():
+		return nil, buffer, ctx.Err()
+	}
+
+	buf := &r.buffers[index]
+	length, err := r.read(ctx, buf.planes[0].data)
+
+	if err != nil {
+		r.free <- index
+		return nil, buffer, err
+	}
+
+	buf.planes[0].bytesused = uint32(length)
+
+	buffer.Index = index
+	buffer.Type = v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE
+	buffer.Bytesused = uint32(length)
+	buffer.Field = r.field
+	buffer.Sequence = r.sequence
+
+	if stamp, err := monotonicClock(); err == nil {
+		buffer.Flags = v4l2.V4L2_BUF_FLAG_TIMESTAMP_MONOTONIC
+		buffer.SetTimestamp(int64(stamp/time.Second), int64(stamp%time.Second/time.Microsecond))
+	} else {
+		now := time.Now()
+		buffer.SetTimestamp(now.Unix(), int64(now.Nanosecond())/1000)
+	}
+
+	r.sequence++
+	return buf, buffer, nil
+}
+
+func (r *frameReader) read(ctx context.Context, data []byte) (int, error) {
+
+	var length int
+
+	for {
+		n, ok, err := readFrame(r.file.Fd(), data)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			length = n
+			break
+		}
+
+		revents, err := waitForFrame(ctx, r.file.Fd())
+
+		if err != nil {
+			return 0, err
+		}
+
+		if revents&ioctl.POLLIN > 0 {
+			continue
+		}
+
+		/* poll signalled an error, the read reports it precisely unless a frame arrived meanwhile */
+		if n, ok, err = readFrame(r.file.Fd(), data); err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			return 0, fmt.Errorf("Device %s signalled an error while waiting for a frame: %w", r.file.Name(), ErrDisconnected)
+		}
+
+		length = n
+		break
+	}
+
+	if length == 0 {
+		return 0, fmt.Errorf("Device %s ended read I/O: %w", r.file.Name(), ErrDisconnected)
+	}
+
+	return length, nil
+}
+
+/*
+* The buffer is only marked free, it is not shared with the driver
+ */
+func (r *frameReader) giveBack(index uint32) error {
+	r.free <- index
+	return nil
+}
+
+/*
+* Closes the handle which ends capturing, leased buffers stay valid
+ */
+func (r *frameReader) stop() error {
+	log.Printf("Closing read I/O of device %s", r.file.Name())
+	return r.file.Close()
+}
@@ -0,0 +1,96 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"v4l2"
+	"v4l2/ioctl"
+)
+
+type standards struct {
+	file *ioctl.File
+}
+
+func (s *standards) All() ([]Standard, error) {
+
+	result := make([]Standard, 0, 8)
+
+	for index := uint32(0); ; index++ {
+		var standard v4l2.V4l2Standard
+		standard.Index = index
+
+		ok, err := ioctl.QueryStandard(s.file.Fd(), &standard)
+
+		if err != nil {
+			return nil, s.wrap(err)
+		}
+
+		if !ok {
+			return result, nil
+		}
+
+		result = append(result, Standard{standard.Index, standard.Id, cstring(standard.Name[:]), Fraction{standard.Frameperiod.Numerator, standard.Frameperiod.Denominator}, standard.Framelines})
+	}
+}
+
+func (s *standards) Current() (uint64, error) {
+
+	id, err := ioctl.GetStandard(s.file.Fd())
+
+	if err != nil {
+		return 0, s.wrap(err)
+	}
+
+	return id, nil
+}
+
+func (s *standards) Set(id uint64) error {
+	return s.wrap(ioctl.SetStandard(s.file.Fd(), id))
+}
+
+func (s *standards) Detect() (uint64, error) {
+
+	id, err := ioctl.DetectStandard(s.file.Fd())
+
+	if err != nil {
+		return 0, s.wrap(err)
+	}
+
+	if id == v4l2.V4L2_STD_UNKNOWN {
+		return 0, fmt.Errorf("Device %s detects no standard: %w", s.file.Name(), ErrNoSignal)
+	}
+
+	return id, nil
+}
+
+/*
+* Sets up the detected standards, drivers choose among them if the detection is ambiguous
+ */
+func (s *standards) AutoDetect() (uint64, error) {
+
+	detected, err := s.Detect()
+
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("Device %s detected standard 0x%x", s.file.Name(), detected)
+
+	if err := s.Set(detected); err != nil {
+		return 0, err
+	}
+
+	return s.Current()
+}
+
+/*
+* Drivers report ENODATA if the current input has no analog standards
+ */
+func (s *standards) wrap(err error) error {
+	if errors.Is(err, syscall.ENODATA) {
+		return fmt.Errorf("Current input of device %s has no analog standards: %w", s.file.Name(), ErrUnsupported)
+	}
+	return err
+}
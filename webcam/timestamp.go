@@ -0,0 +1,85 @@
+package webcam
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+	"v4l2"
+)
+
+const clock_MONOTONIC = 1
+
+/*
+* Metadata the driver attaches to every filled buffer
+ */
+type frameInfo struct {
+	timestamp time.Time
+	monotonic time.Duration
+	sequence  uint32
+	flags     uint32
+	field     uint32
+	dropped   uint32
+}
+
+func frameInfoOf(buffer *v4l2.V4l2Buffer) frameInfo {
+	sec, usec := buffer.Timestamp()
+	stamp := time.Duration(sec)*time.Second + time.Duration(usec)*time.Microsecond
+
+	info := frameInfo{sequence: buffer.Sequence, flags: buffer.Flags, field: buffer.Field}
+
+	if buffer.Flags&v4l2.V4L2_BUF_FLAG_TIMESTAMP_MASK == v4l2.V4L2_BUF_FLAG_TIMESTAMP_MONOTONIC {
+		info.monotonic = stamp
+		info.timestamp = monotonicToTime(stamp)
+	} else {
+		info.timestamp = time.Unix(sec, usec*int64(time.Microsecond))
+	}
+
+	return info
+}
+
+/*
+* Converts CLOCK_MONOTONIC value to wall clock time by measuring how long ago it was
+ */
+func monotonicToTime(stamp time.Duration) time.Time {
+	now := time.Now()
+	monotonicNow, err := monotonicClock()
+
+	if err != nil {
+		return now
+	}
+
+	return now.Add(stamp - monotonicNow)
+}
+
+func monotonicClock() (time.Duration, error) {
+	var ts syscall.Timespec
+
+	_, _, err := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clock_MONOTONIC, uintptr(unsafe.Pointer(&ts)), 0)
+
+	if err != 0 {
+		return 0, err
+	}
+
+	return time.Duration(ts.Nano()), nil
+}
+
+/*
+* Counts frames the driver skipped between two consecutive sequence numbers
+ */
+type sequenceTracker struct {
+	started bool
+	last    uint32
+}
+
+func (t *sequenceTracker) track(sequence uint32) uint32 {
+	var dropped uint32
+
+	if t.started && sequence > t.last+1 {
+		dropped = sequence - t.last - 1
+	}
+
+	t.started = true
+	t.last = sequence
+
+	return dropped
+}
@@ -0,0 +1,76 @@
+package webcam
+
+import (
+	"errors"
+	"fmt"
+	"v4l2"
+)
+
+var ErrCorruptFrame = errors.New("corrupt frame")
+
+/* number of corrupt frames in a row after which the stream gives up */
+const MAX_CORRUPT_FRAMES = 10
+
+type corruptFrameError struct {
+	reason string
+}
+
+func (e *corruptFrameError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrCorruptFrame, e.reason)
+}
+
+func (e *corruptFrameError) Is(target error) bool {
+	return target == ErrCorruptFrame
+}
+
+func corrupt(format string, args ...interface{}) error {
+	return &corruptFrameError{fmt.Sprintf(format, args...)}
+}
+
+/*
+* Checks the payload of a frame. JPEG frames must start with SOI and end with EOI marker,
+* uncompressed frames must fill the whole image size negotiated with the driver.
+ */
+func ValidateFrame(format Format, flags uint32, data []byte) error {
+
+	if flags&v4l2.V4L2_BUF_FLAG_ERROR > 0 {
+		return corrupt("driver marked the buffer as erroneous")
+	}
+
+	if len(data) == 0 {
+		return corrupt("empty payload")
+	}
+
+	switch format.PixelFormat {
+	case v4l2.V4L2_PIX_FMT_MJPEG, v4l2.V4L2_PIX_FMT_JPEG:
+		return validateJpeg(data)
+
+	case v4l2.V4L2_PIX_FMT_H264, v4l2.V4L2_PIX_FMT_MPEG, v4l2.V4L2_PIX_FMT_MPEG4, v4l2.V4L2_PIX_FMT_VP8:
+		return nil
+	}
+
+	if format.SizeImage > 0 && uint32(len(data)) < format.SizeImage {
+		return corrupt("payload of %d bytes is shorter than image size %d", len(data), format.SizeImage)
+	}
+
+	return nil
+}
+
+func validateJpeg(data []byte) error {
+
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return corrupt("missing JPEG start of image marker")
+	}
+
+	/* some cameras pad the payload with zeros */
+	end := len(data)
+	for end > 2 && data[end-1] == 0 {
+		end--
+	}
+
+	if end < 4 || data[end-2] != 0xff || data[end-1] != 0xd9 {
+		return corrupt("missing JPEG end of image marker, frame is truncated")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"camserver"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -18,6 +19,7 @@ func main() {
 	//printConstants()
 	//streamVideo("/dev/video0")
 	//printAllFrameSizes("/dev/video0")
+	//printFrameIntervals("/dev/video0")
 	//printCapability("/dev/video0")
 	//printFormatSupport("/dev/video0")
 }
@@ -31,15 +33,16 @@ func streamVideo(file string) {
 
 	defer func() {
 		if err2 := device.Close(); err2 != nil {
-			log.Fatalf("%v\n")
+			log.Fatalf("%v\n", err2)
 		}
 	}()
 
-	ticks := make(chan bool, 1)
-	snaps := make(chan webcam.Snapshot)
+	config := webcam.StreamConfig{FrameSize: webcam.DiscreteFrameSize{Width: 1280, Height: 960}, PixelFormat: v4l2.V4L2_PIX_FMT_MJPEG, FrameRate: 30}
 
-	go device.Stream(&webcam.DiscreteFrameSize{1280, 960}, ticks, snaps)
-	go tickDriving(40, ticks)
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
+	defer cancel()
+
+	snaps, errs := device.Stream(ctx, config)
 
 	index := uint(0)
 	for s := range snaps {
@@ -49,27 +52,17 @@ func streamVideo(file string) {
 		}
 
 		file.Write(s.Data())
+		file.Close()
+		s.Release()
 
 		index++
 	}
 
-	fmt.Printf("Konec streamu")
-}
-
-func tickDriving(count int, ticks chan bool) {
-
-	for i := 0; i < count; i++ {
-		time.Sleep(300 * time.Millisecond)
-		ticks <- true
-
-		ok := <-ticks
-
-		if !ok {
-			break
-		}
+	for err := range errs {
+		log.Printf("Stream failed: %v\n", err)
 	}
 
-	close(ticks)
+	fmt.Printf("Konec streamu")
 }
 
 func takeSnapshot(file string) {
@@ -82,7 +75,10 @@ func takeSnapshot(file string) {
 	defer device.Close()
 
 	ch := make(chan webcam.Snapshot)
-	go device.TakeSnapshotChan(&webcam.DiscreteFrameSize{1280, 960}, ch)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- device.TakeSnapshotChan(&webcam.DiscreteFrameSize{Width: 1280, Height: 960}, v4l2.V4L2_PIX_FMT_MJPEG, ch)
+	}()
 
 	for s := range ch {
 		fmt.Printf("Mam obrazek o velikosti %dB\n", s.Length())
@@ -95,6 +91,11 @@ func takeSnapshot(file string) {
 		}
 
 		outfile.Write(s.Data())
+		s.Release()
+	}
+
+	if err := <-errs; err != nil {
+		log.Fatalf("%v\n", err)
 	}
 }
 
@@ -122,6 +123,18 @@ func printAllFrameSizes(file string) {
 
 	fmt.Println("--------------------------")
 
+	ranges, err := sizes.All(v4l2.V4L2_PIX_FMT_MJPEG)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	for _, r := range ranges {
+		fmt.Printf("%v\n", r)
+	}
+
+	fmt.Println("--------------------------")
+
 	supports, err := sizes.SupportsDiscrete(v4l2.V4L2_PIX_FMT_MJPEG, 1184, 656)
 
 	if err != nil {
@@ -131,6 +144,33 @@ func printAllFrameSizes(file string) {
 	fmt.Printf("1184x656 je podporovano: %t\n", supports)
 }
 
+func printFrameIntervals(file string) {
+
+	device, err := webcam.OpenVideoDevice(file)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	defer device.Close()
+
+	discretes, err := device.FrameSizes().AllDiscrete(v4l2.V4L2_PIX_FMT_MJPEG)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	for _, d := range discretes {
+		intervals, err := device.FrameIntervals().All(v4l2.V4L2_PIX_FMT_MJPEG, d.Width, d.Height)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		fmt.Printf("%v: %v\n", d, intervals)
+	}
+}
+
 func printFormatSupport(path string) {
 	device, err := webcam.OpenVideoDevice(path)
 
@@ -140,13 +180,23 @@ func printFormatSupport(path string) {
 
 	defer device.Close()
 
-	supports, err := device.Formats().Supports(v4l2.V4L2_BUF_TYPE_VIDEO_CAPTURE, v4l2.V4L2_PIX_FMT_MJPEG)
+	supports, err := device.Formats().Supports(device.BufferType(), v4l2.V4L2_PIX_FMT_MJPEG)
 
 	if err != nil {
 		log.Fatalf("%v\n", err)
 	}
 
 	fmt.Printf("Device %s supports format %s: %t\n", device.Name(), "V4L2_PIX_FMT_MJPEG", supports)
+
+	formats, err := device.Formats().All(device.BufferType())
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	for _, f := range formats {
+		fmt.Printf("%v\n", f)
+	}
 }
 
 func printCapability(file string) {
@@ -160,4 +210,5 @@ func printCapability(file string) {
 
 	fmt.Printf("Video Device %s\n", device.Name())
 	fmt.Printf("%v\n", device.Capability())
+	fmt.Printf("I/O method %v\n", device.IOMethod())
 }